@@ -20,20 +20,987 @@ import (
 	"reflect"
 
 	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 // CtrlDriftParameters are the configurable fields of a CtrlDrift.
 type CtrlDriftParameters struct {
-	DeployName      string `json:"deploy_name"`
+	// DeployName is used as the prefix of every Deployment and Job generated
+	// for this CtrlDrift.
+	DeployName string `json:"deploy_name"`
+
+	// DeployNamespace is the namespace the generated Deployments and Jobs
+	// are created in.
 	DeployNamespace string `json:"deploy_namespace"`
-	TrainingScript  string `json:"training_script"`
+
+	// TrainingScript is the file name of the training script. It is the
+	// key looked up in a ConfigMap or Secret source, or the path relative
+	// to the data volume for a Volume source. It must neither be absolute
+	// nor contain ".." elements.
+	TrainingScript string `json:"training_script"`
+
+	// TrainingScriptSource is where TrainingScript is loaded from. The
+	// script is mounted into the training container and run as its
+	// entrypoint. When omitted the training image runs its own entrypoint.
+	// +optional
+	TrainingScriptSource *TrainingScriptSource `json:"trainingScriptSource,omitempty"`
+
+	// Detector customises the container of the drift detection Deployment.
+	// +optional
+	Detector *WorkloadSpec `json:"detector,omitempty"`
+
+	// Inference customises the container of the inference Deployment.
+	// +optional
+	Inference *WorkloadSpec `json:"inference,omitempty"`
+
+	// Training customises the container of the training Job.
+	// +optional
+	Training *WorkloadSpec `json:"training,omitempty"`
+
+	// Conversion customises the container of the model conversion Job.
+	// +optional
+	Conversion *WorkloadSpec `json:"conversion,omitempty"`
+
+	// Evaluation runs an evaluation Job between training and conversion.
+	// A trained model is only converted and rolled out when it meets the
+	// thresholds of the evaluation, otherwise the run is rejected. When
+	// omitted every trained model is rolled out.
+	// +optional
+	Evaluation *Evaluation `json:"evaluation,omitempty"`
+
+	// Rollout decides how a new model replaces the model currently served.
+	// When omitted the inference Deployment is recreated with the new model.
+	// +optional
+	Rollout *RolloutPolicy `json:"rollout,omitempty"`
+
+	// RetrainPolicy decides when the drifted samples collected by the
+	// detector are enough to start a new training run. When omitted a run is
+	// started once 3000 drifted samples have been collected.
+	// +optional
+	RetrainPolicy *RetrainPolicy `json:"retrainPolicy,omitempty"`
+
+	// RetrainSchedule starts training runs on a schedule, whether or not
+	// drift was detected and regardless of the cooldown of the
+	// RetrainPolicy. The RetrainPolicy keeps starting runs on drift in
+	// between. When omitted runs are only started on drift.
+	// +optional
+	RetrainSchedule *RetrainSchedule `json:"retrainSchedule,omitempty"`
+
+	// RetryPolicy decides how often a failed training, evaluation or
+	// conversion Job is retried before the pipeline fails. When omitted a
+	// failed Job is retried 3 times.
+	// +optional
+	RetryPolicy *RetryPolicy `json:"retryPolicy,omitempty"`
+
+	// DatasetSnapshots decides how many snapshots of the drift dataset are
+	// kept. A snapshot is taken before every training run, so that every
+	// model can be traced back to the data it was trained on. When omitted
+	// the snapshots of the last 5 runs and of every recorded model are kept.
+	// +optional
+	DatasetSnapshots *DatasetSnapshotPolicy `json:"datasetSnapshots,omitempty"`
+
+	// JobHistory decides how many finished Jobs are kept for inspection.
+	// When omitted the last 3 successful and 3 failed Jobs are kept for at
+	// most a day.
+	// +optional
+	JobHistory *JobHistory `json:"jobHistory,omitempty"`
+
+	// ModelVersion pins the inference Deployment to a recorded model, the
+	// version of one of the ModelVersions of this CtrlDrift. While it is set
+	// new models are still trained and recorded, but not rolled out. Remove
+	// it to resume serving the latest model.
+	// +kubebuilder:validation:Pattern=`^[0-9]{8}-[0-9]{6}$`
+	// +optional
+	ModelVersion *string `json:"modelVersion,omitempty"`
+
+	// DriftSource is where the provider reads the drift data written by the
+	// detector from. When omitted it is read from /var/data/ in the
+	// provider's own pod, which must then mount the detector's volume.
+	// +optional
+	DriftSource *DriftSource `json:"driftSource,omitempty"`
+}
+
+// A DriftSourceType is a kind of DriftSource.
+type DriftSourceType string
+
+// Drift source types.
+const (
+	DriftSourceLocal DriftSourceType = "Local"
+	DriftSourceS3    DriftSourceType = "S3"
+	DriftSourceHTTP  DriftSourceType = "HTTP"
+	DriftSourcePod   DriftSourceType = "Pod"
+	DriftSourceMQTT  DriftSourceType = "MQTT"
+)
+
+// A DriftFileFormat is the format of a drift data file.
+type DriftFileFormat string
+
+// Drift data file formats.
+const (
+	DriftFileCSV       DriftFileFormat = "CSV"
+	DriftFileJSONLines DriftFileFormat = "JSONLines"
+	DriftFileParquet   DriftFileFormat = "Parquet"
+)
+
+// A DriftSource selects where the drift data written by the detector is read
+// from.
+// +kubebuilder:validation:XValidation:rule="self.type != 'S3' || has(self.s3)",message="s3 is required for S3 sources"
+// +kubebuilder:validation:XValidation:rule="self.type != 'HTTP' || has(self.http)",message="http is required for HTTP sources"
+// +kubebuilder:validation:XValidation:rule="self.type != 'MQTT' || has(self.mqtt)",message="mqtt is required for MQTT sources"
+type DriftSource struct {
+	// Type of the source: a directory of the provider's pod, an S3
+	// compatible bucket, a stats endpoint served by the detector, a short
+	// lived pod that mounts the detector's volume, or drift events the
+	// detector publishes to an MQTT broker.
+	// +kubebuilder:validation:Enum=Local;S3;HTTP;Pod;MQTT
+	Type DriftSourceType `json:"type"`
+
+	// File is the name of the drift data file written by the detector,
+	// relative to the data volume. The detector is told to write it under
+	// its name without the extension, and the training Job snapshots and
+	// trains on it. Defaults to "drift_data.csv".
+	// +optional
+	File *string `json:"file,omitempty"`
+
+	// Format of the drift data file: CSV, JSON Lines with one object per
+	// line, or Parquet, whose rows are counted from its footer. Defaults to
+	// the format of the extension of the file: ".jsonl" and ".ndjson" files
+	// are JSON Lines, ".parquet" files are Parquet and any other file is
+	// CSV.
+	// +kubebuilder:validation:Enum=CSV;JSONLines;Parquet
+	// +optional
+	Format *DriftFileFormat `json:"format,omitempty"`
+
+	// Header is true when the first line of a CSV drift data file names its
+	// columns instead of holding a drifted sample. Defaults to true.
+	// +optional
+	Header *bool `json:"header,omitempty"`
+
+	// TimestampColumn is the CSV column or the JSON Lines key holding the
+	// time each sample was collected at, as RFC 3339 or as seconds since
+	// the Unix epoch. The column of a CSV file without a header is its zero
+	// based index. The timestamps of a Parquet file are read from the
+	// statistics of the column, which must be an INT64 timestamp or a
+	// string. When set, maxDriftAge is measured from the oldest sample of
+	// the file instead of from when drift was first observed.
+	// +optional
+	TimestampColumn *string `json:"timestampColumn,omitempty"`
+
+	// Local configures a Local source.
+	// +optional
+	Local *LocalDriftSource `json:"local,omitempty"`
+
+	// S3 configures an S3 source.
+	// +optional
+	S3 *S3DriftSource `json:"s3,omitempty"`
+
+	// HTTP configures an HTTP source.
+	// +optional
+	HTTP *HTTPDriftSource `json:"http,omitempty"`
+
+	// Pod configures a Pod source.
+	// +optional
+	Pod *PodDriftSource `json:"pod,omitempty"`
+
+	// MQTT configures an MQTT source.
+	// +optional
+	MQTT *MQTTDriftSource `json:"mqtt,omitempty"`
+}
+
+// A LocalDriftSource reads the drift data from a directory of the provider's
+// own pod.
+type LocalDriftSource struct {
+	// Path of the directory. Defaults to "/var/data/".
+	// +optional
+	Path *string `json:"path,omitempty"`
+}
+
+// An S3DriftSource reads the drift data from an S3 compatible bucket, such as
+// MinIO, the detector uploads it to.
+type S3DriftSource struct {
+	// Endpoint of the S3 API, e.g. "https://s3.eu-west-1.amazonaws.com" or
+	// "http://minio.minio.svc:9000". Buckets are addressed path style.
+	// +kubebuilder:validation:Pattern=`^https?://`
+	Endpoint string `json:"endpoint"`
+
+	// Bucket holding the drift data.
+	Bucket string `json:"bucket"`
+
+	// Prefix of the drift data file in the bucket, e.g. "regression/".
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+
+	// Region the requests are signed for. Defaults to "us-east-1".
+	// +optional
+	Region *string `json:"region,omitempty"`
+
+	// CredentialsSecretRef references a Secret with the access_key_id and
+	// secret_access_key of the bucket. Requests are anonymous when omitted.
+	// +optional
+	CredentialsSecretRef *xpv1.SecretReference `json:"credentialsSecretRef,omitempty"`
+}
+
+// An HTTPDriftSource reads the drift statistics from an endpoint served by
+// the detector. The endpoint answers a GET with a JSON object such as
+// {"drifted_samples": 120, "total_samples": 4000}.
+type HTTPDriftSource struct {
+	// URL of the endpoint, e.g.
+	// "http://regression-drift.default.svc:8080/stats".
+	// +kubebuilder:validation:Pattern=`^https?://`
+	URL string `json:"url"`
+}
+
+// An MQTTDriftSource receives the drift statistics the detector publishes to
+// a topic of an MQTT broker, as a JSON object such as
+// {"drifted_samples": 120, "total_samples": 4000}. A CtrlDrift is reconciled
+// as soon as new statistics are published. The topic is passed to the
+// detector in the DRIFT_EVENTS_TOPIC variable.
+type MQTTDriftSource struct {
+	// Broker is the URL of an unauthenticated broker, e.g.
+	// "tcp://mosquitto.mqtt.svc:1883". Defaults to the broker of the
+	// ProviderConfig, with its credentials.
+	// +kubebuilder:validation:Pattern=`^(tcp|mqtt|ssl|tls|mqtts|ws|wss)://`
+	// +optional
+	Broker *string `json:"broker,omitempty"`
+
+	// Topic the drift statistics are published to. Defaults to
+	// "driftprovider/<deploy_namespace>/<deploy_name>/drift".
+	// +optional
+	Topic *string `json:"topic,omitempty"`
+}
+
+// A PodDriftSource reads the drift data through a short lived pod that mounts
+// the detector's data volume and prints the drift data file.
+type PodDriftSource struct {
+	// Image of the reader pod. It must provide a POSIX shell. Defaults to
+	// "busybox:1.36".
+	// +optional
+	Image *string `json:"image,omitempty"`
+}
+
+// A ScriptSourceType is a kind of TrainingScriptSource.
+type ScriptSourceType string
+
+// Training script source types.
+const (
+	ScriptSourceConfigMap ScriptSourceType = "ConfigMap"
+	ScriptSourceSecret    ScriptSourceType = "Secret"
+	ScriptSourceVolume    ScriptSourceType = "Volume"
+)
+
+// A TrainingScriptSource selects where the training script is loaded from.
+// +kubebuilder:validation:XValidation:rule="self.type == 'Volume' || has(self.name)",message="name is required for ConfigMap and Secret sources"
+type TrainingScriptSource struct {
+	// Type of the source: a ConfigMap or a Secret in deploy_namespace, or a
+	// file on the data volume.
+	// +kubebuilder:validation:Enum=ConfigMap;Secret;Volume
+	Type ScriptSourceType `json:"type"`
+
+	// Name of the ConfigMap or Secret.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Key of the ConfigMap or Secret holding the script. Defaults to
+	// training_script.
+	// +optional
+	Key string `json:"key,omitempty"`
+
+	// Command the script is passed to. Defaults to ["python"].
+	// +optional
+	Command []string `json:"command,omitempty"`
+}
+
+// A WorkloadSpec customises the container and pod of one of the workloads
+// generated for a CtrlDrift. Every field is optional and falls back to the
+// provider's built-in default for that workload.
+type WorkloadSpec struct {
+	// Image is the container image without tag or digest, e.g.
+	// "lucaserf/drift_detection".
+	// +optional
+	Image *string `json:"image,omitempty"`
+
+	// Tag of the container image. Defaults to "latest".
+	// +optional
+	Tag *string `json:"tag,omitempty"`
+
+	// Digest of the container image, e.g. "sha256:...". Takes precedence
+	// over Tag when set.
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]+:[a-f0-9]{32,}$`
+	// +optional
+	Digest *string `json:"digest,omitempty"`
+
+	// ImagePullPolicy of the container.
+	// +kubebuilder:validation:Enum=Always;Never;IfNotPresent
+	// +optional
+	ImagePullPolicy *corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+
+	// Env variables of the container. Variables with the same name as a
+	// default variable replace it, others are appended.
+	// +optional
+	Env []corev1.EnvVar `json:"env,omitempty"`
+
+	// EnvFrom sources of the container.
+	// +optional
+	EnvFrom []corev1.EnvFromSource `json:"envFrom,omitempty"`
+
+	// Resources of the container.
+	// +optional
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// NodeSelector of the pod.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations of the pod.
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// ImagePullSecrets of the pod.
+	// +optional
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// Volume configures the data volume shared by the workloads.
+	// +optional
+	Volume *DataVolume `json:"volume,omitempty"`
+}
+
+// A DataVolume configures the PersistentVolumeClaim the drift data and the
+// models are stored on.
+type DataVolume struct {
+	// ClaimName of the PersistentVolumeClaim. Defaults to "data-pvc".
+	// +optional
+	ClaimName *string `json:"claimName,omitempty"`
+
+	// MountPath of the volume in the container. Defaults to "/var/data/".
+	// +optional
+	MountPath *string `json:"mountPath,omitempty"`
+
+	// SubPath of the volume to mount.
+	// +optional
+	SubPath *string `json:"subPath,omitempty"`
+}
+
+// A RetrainPolicy configures when drift triggers a retrain. A retrain is
+// started as soon as any of the configured triggers is met, unless the
+// cooldown since the previous run has not elapsed yet. When no trigger is
+// configured MinDriftedSamples defaults to 3000.
+type RetrainPolicy struct {
+	// MinDriftedSamples triggers a retrain once at least this many drifted
+	// samples have been collected.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MinDriftedSamples *int64 `json:"minDriftedSamples,omitempty"`
+
+	// MinDriftRatio triggers a retrain once the ratio of drifted samples to
+	// all inspected samples reaches this value, e.g. "0.2". It is only
+	// evaluated when the total number of inspected samples is known.
+	// +kubebuilder:validation:Pattern=`^(0(\.[0-9]+)?|1(\.0+)?)$`
+	// +optional
+	MinDriftRatio *string `json:"minDriftRatio,omitempty"`
+
+	// MaxDriftAge triggers a retrain once the oldest drifted sample is older
+	// than this duration, e.g. "24h", so that small amounts of drift are not
+	// left waiting forever.
+	// +kubebuilder:validation:XValidation:rule="duration(self) > duration('0s')",message="must be a positive duration"
+	// +optional
+	MaxDriftAge *metav1.Duration `json:"maxDriftAge,omitempty"`
+
+	// Cooldown is the minimum time between the start of two retrains.
+	// +kubebuilder:validation:XValidation:rule="duration(self) > duration('0s')",message="must be a positive duration"
+	// +optional
+	Cooldown *metav1.Duration `json:"cooldown,omitempty"`
+}
+
+// A RetrainSchedule starts training runs at fixed times, or once the last run
+// is too old, so that the model is refreshed even without drift. A scheduled
+// run is skipped while another run is in flight, and the skipped run is
+// recorded in the status.
+// +kubebuilder:validation:XValidation:rule="has(self.cron) || has(self.maxIntervalDays)",message="cron or maxIntervalDays is required"
+type RetrainSchedule struct {
+	// Cron is a schedule in the standard five field cron format, e.g.
+	// "0 3 * * 0" for Sundays at 03:00, or a descriptor such as "@daily".
+	// +optional
+	Cron *string `json:"cron,omitempty"`
+
+	// TimeZone of the cron schedule, a name of the IANA time zone database
+	// such as "Europe/Rome". Defaults to UTC.
+	// +optional
+	TimeZone *string `json:"timeZone,omitempty"`
+
+	// MaxIntervalDays starts a run once the last run was started this many
+	// days ago, e.g. 7 to retrain at least every week.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxIntervalDays *int32 `json:"maxIntervalDays,omitempty"`
+}
+
+// A RetryPolicy configures how the failed Jobs of a run are retried. A failed
+// Job is deleted and started again after a backoff that doubles with every
+// retry. Once the retries of a run are used up the pipeline moves to the
+// Failed phase and keeps the failed Job for inspection. The failed run is
+// retired and a new one started once a retrain is requested or the spec is
+// changed. The cancel-run annotation retires it without starting a new one.
+type RetryPolicy struct {
+	// Limit is the number of retries of the Jobs of a run. Defaults to 3.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Limit *int32 `json:"limit,omitempty"`
+
+	// Backoff is the delay before the first retry. Defaults to 30s.
+	// +kubebuilder:validation:XValidation:rule="duration(self) > duration('0s')",message="must be a positive duration"
+	// +optional
+	Backoff *metav1.Duration `json:"backoff,omitempty"`
+
+	// MaxBackoff caps the delay between two retries. Defaults to 10m.
+	// +kubebuilder:validation:XValidation:rule="duration(self) > duration('0s')",message="must be a positive duration"
+	// +optional
+	MaxBackoff *metav1.Duration `json:"maxBackoff,omitempty"`
+
+	// LogLines is the number of lines at the end of the log of a failed
+	// pod that are captured in the status and in an event. Defaults to 20.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=200
+	// +optional
+	LogLines *int32 `json:"logLines,omitempty"`
+}
+
+// A DatasetSnapshotPolicy configures how long the snapshots of the drift
+// dataset are kept. The training Job copies the drift dataset to
+// datasets/<deployName>/<run>/ on the data volume and makes the copy
+// read-only before it trains on the dataset, which consumes it. Snapshots
+// that are no longer retained are deleted by the next training Job, which
+// leaves the snapshots of other CtrlDrifts sharing the volume alone.
+// A drift dataset that does not exist yet is snapshotted as an empty file.
+type DatasetSnapshotPolicy struct {
+	// Limit is the number of snapshots of the most recent runs that are
+	// kept, the snapshot of the current run included. Defaults to 5.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Limit *int32 `json:"limit,omitempty"`
+
+	// KeepRecordedModels keeps the snapshot of every model recorded in a
+	// ModelVersion beyond the limit, so that every recorded model can be
+	// reproduced. Most runs record a model, so the limit then mostly bounds
+	// the snapshots of runs that were rejected or failed. Defaults to false.
+	// +optional
+	KeepRecordedModels *bool `json:"keepRecordedModels,omitempty"`
+}
+
+// A JobHistory configures how long the finished Jobs of the pipeline are kept.
+// Every Job is named after the run it belongs to, so that the Jobs of earlier
+// runs and of failed attempts stay around next to the Jobs of the current run.
+type JobHistory struct {
+	// SuccessfulJobsHistoryLimit is the number of successful Jobs kept.
+	// Defaults to 3.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	SuccessfulJobsHistoryLimit *int32 `json:"successfulJobsHistoryLimit,omitempty"`
+
+	// FailedJobsHistoryLimit is the number of failed Jobs kept. Defaults to
+	// 3.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	FailedJobsHistoryLimit *int32 `json:"failedJobsHistoryLimit,omitempty"`
+
+	// TTLAfterFinished is how long a finished Job is kept at most, whatever
+	// the limits. It is set as ttlSecondsAfterFinished of a Job once the
+	// pipeline moved past it, so that it counts from the time the Job
+	// finished. Defaults to 24h.
+	// +kubebuilder:validation:XValidation:rule="duration(self) > duration('0s')",message="must be a positive duration"
+	// +optional
+	TTLAfterFinished *metav1.Duration `json:"ttlAfterFinished,omitempty"`
+}
+
+// An Evaluation configures the evaluation Job of a CtrlDrift. The Job runs
+// the trained model against a holdout set and writes its metrics as a flat
+// JSON object, e.g. {"rmse": 0.12}, to the file named by METRICS_PATH.
+// +kubebuilder:validation:XValidation:rule="has(self.image)",message="image is required"
+type Evaluation struct {
+	// WorkloadSpec customises the container of the evaluation Job. Its
+	// image is required.
+	WorkloadSpec `json:",inline"`
+
+	// Command overrides the entrypoint of the evaluation container.
+	// +optional
+	Command []string `json:"command,omitempty"`
+
+	// HoldoutPath is the holdout set, relative to the data volume. Defaults
+	// to "holdout.csv".
+	// +optional
+	HoldoutPath *string `json:"holdoutPath,omitempty"`
+
+	// Thresholds the evaluation metrics must meet for the model to be
+	// rolled out. A model is rolled out when it meets all of them.
+	// +optional
+	Thresholds []MetricThreshold `json:"thresholds,omitempty"`
+}
+
+// A MetricGoal tells whether lower or higher values of a metric are better.
+type MetricGoal string
+
+// Metric goals.
+const (
+	MetricGoalMinimize MetricGoal = "Minimize"
+	MetricGoalMaximize MetricGoal = "Maximize"
+)
+
+// A MetricThreshold is a condition on one evaluation metric.
+// +kubebuilder:validation:XValidation:rule="!has(self.noWorseThanActive) || !self.noWorseThanActive || has(self.goal)",message="goal is required by noWorseThanActive"
+type MetricThreshold struct {
+	// Metric is the name of the metric in the metrics file.
+	// +kubebuilder:validation:MinLength=1
+	Metric string `json:"metric"`
+
+	// Max is the largest acceptable value, e.g. "0.2".
+	// +kubebuilder:validation:Pattern=`^-?[0-9]+(\.[0-9]+)?([eE][-+]?[0-9]+)?$`
+	// +optional
+	Max *string `json:"max,omitempty"`
+
+	// Min is the smallest acceptable value, e.g. "0.8".
+	// +kubebuilder:validation:Pattern=`^-?[0-9]+(\.[0-9]+)?([eE][-+]?[0-9]+)?$`
+	// +optional
+	Min *string `json:"min,omitempty"`
+
+	// Goal tells whether lower or higher values of the metric are better.
+	// +kubebuilder:validation:Enum=Minimize;Maximize
+	// +optional
+	Goal *MetricGoal `json:"goal,omitempty"`
+
+	// NoWorseThanActive requires the metric to be no worse than the same
+	// metric of the evaluation of the model currently served. It is met
+	// when that model was not evaluated.
+	// +optional
+	NoWorseThanActive bool `json:"noWorseThanActive,omitempty"`
+}
+
+// A RolloutStrategy decides how a new model replaces the model currently
+// served.
+type RolloutStrategy string
+
+// Rollout strategies.
+const (
+	// RolloutRecreate recreates the inference Deployment with the new model.
+	RolloutRecreate RolloutStrategy = "Recreate"
+
+	// RolloutCanary runs the new model next to the served one on a share of
+	// the input for the bake time before promoting it.
+	RolloutCanary RolloutStrategy = "Canary"
+
+	// RolloutShadow runs the new model on all of the input next to the
+	// served one for the bake time, without publishing its predictions.
+	RolloutShadow RolloutStrategy = "Shadow"
+)
+
+// A RolloutPolicy decides how a new model replaces the model currently
+// served. With the Canary and Shadow strategies the new model is run by a
+// candidate Deployment next to the inference Deployment. Both are told their
+// role by INFERENCE_ROLE (stable, canary or shadow) and write their running
+// metrics as a flat JSON object to the file named by METRICS_PATH. Once the
+// bake time elapsed the metrics of the candidate are compared with the
+// thresholds: the new model is promoted when it meets all of them, otherwise
+// the candidate is removed and the run is rejected.
+type RolloutPolicy struct {
+	// Strategy of the rollout. Defaults to Recreate.
+	// +kubebuilder:validation:Enum=Recreate;Canary;Shadow
+	// +optional
+	Strategy RolloutStrategy `json:"strategy,omitempty"`
+
+	// CanaryWeight is the percentage of the input scored by the candidate
+	// of a Canary rollout. It is passed to both Deployments as
+	// CANARY_WEIGHT. Defaults to 10.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=99
+	// +optional
+	CanaryWeight *int32 `json:"canaryWeight,omitempty"`
+
+	// BakeTime is how long the candidate runs before its metrics are
+	// compared. Defaults to 1h.
+	// +kubebuilder:validation:XValidation:rule="duration(self) > duration('0s')",message="must be a positive duration"
+	// +optional
+	BakeTime *metav1.Duration `json:"bakeTime,omitempty"`
+
+	// Thresholds the metrics of the candidate must meet to be promoted.
+	// NoWorseThanActive compares with the metrics of the inference
+	// Deployment over the same period.
+	// +optional
+	Thresholds []MetricThreshold `json:"thresholds,omitempty"`
+}
+
+// A Phase is the step of the drift → train → convert → rollout pipeline a
+// CtrlDrift is currently in.
+type Phase string
+
+// Pipeline phases.
+const (
+	// PhaseMonitoring means the detector is running and no drift has been
+	// collected yet.
+	PhaseMonitoring Phase = "Monitoring"
+
+	// PhaseDrifting means drifted samples are being collected, but the
+	// retrain policy has not triggered yet.
+	PhaseDrifting Phase = "Drifting"
+
+	// PhaseTraining means a training Job is running.
+	PhaseTraining Phase = "Training"
+
+	// PhaseEvaluating means an evaluation Job is running, or its metrics are
+	// being checked against the thresholds of the evaluation.
+	PhaseEvaluating Phase = "Evaluating"
+
+	// PhaseConverting means a conversion Job is running.
+	PhaseConverting Phase = "Converting"
+
+	// PhaseBaking means a new model is run by a candidate Deployment next to
+	// the model currently served, before it is promoted or rolled back.
+	PhaseBaking Phase = "Baking"
+
+	// PhaseRollingOut means a new model is being rolled out to the
+	// detector and inference Deployments.
+	PhaseRollingOut Phase = "RollingOut"
+
+	// PhaseRejected means the last trained model did not meet the thresholds
+	// of its evaluation and was discarded.
+	PhaseRejected Phase = "Rejected"
+
+	// PhaseFailed means a step of the pipeline failed.
+	PhaseFailed Phase = "Failed"
+)
+
+// An EvaluationResult is the outcome of the evaluation of a trained model.
+type EvaluationResult struct {
+	// ModelVersion is the version of the evaluated model.
+	ModelVersion string `json:"modelVersion"`
+
+	// Accepted is true when the model met every threshold.
+	Accepted bool `json:"accepted"`
+
+	// Metrics reported by the evaluation Job.
+	// +optional
+	Metrics map[string]string `json:"metrics,omitempty"`
+
+	// Message explains why the model was rejected.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Time is when the metrics were checked.
+	// +optional
+	Time *metav1.Time `json:"time,omitempty"`
+}
+
+// A RolloutOutcome is how a Canary or Shadow rollout ended.
+type RolloutOutcome string
+
+// Rollout outcomes.
+const (
+	RolloutPromoted   RolloutOutcome = "Promoted"
+	RolloutRolledBack RolloutOutcome = "RolledBack"
+)
+
+// A RolloutStatus is the progress of a Canary or Shadow rollout.
+type RolloutStatus struct {
+	// ModelVersion is the version of the candidate model.
+	ModelVersion string `json:"modelVersion"`
+
+	// Strategy of the rollout.
+	Strategy RolloutStrategy `json:"strategy"`
+
+	// StartTime is when the candidate Deployment was created.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// Outcome of the rollout. It is empty while the candidate bakes.
+	// +optional
+	Outcome RolloutOutcome `json:"outcome,omitempty"`
+
+	// CandidateMetrics reported by the candidate Deployment.
+	// +optional
+	CandidateMetrics map[string]string `json:"candidateMetrics,omitempty"`
+
+	// StableMetrics reported by the inference Deployment.
+	// +optional
+	StableMetrics map[string]string `json:"stableMetrics,omitempty"`
+
+	// Message explains the outcome.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// CompletionTime is when the outcome was decided.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// A JobFailure describes a failed Job of the pipeline.
+type JobFailure struct {
+	// Job is the name of the failed Job.
+	Job string `json:"job"`
+
+	// UID of the failed Job.
+	UID string `json:"uid"`
+
+	// Attempt is the number of failures of the run, this one included.
+	Attempt int32 `json:"attempt"`
+
+	// Reason the Job reports for its failure, e.g. BackoffLimitExceeded.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// Log is the end of the log of the failed pod.
+	// +optional
+	Log string `json:"log,omitempty"`
+
+	// Time the failure was observed.
+	// +optional
+	Time *metav1.Time `json:"time,omitempty"`
+}
+
+// Reasons of a SkippedRun.
+const (
+	// SkipOverlap is a scheduled run that was due while another run was in
+	// flight.
+	SkipOverlap = "Overlap"
+
+	// SkipFailed is a scheduled run that was due while the pipeline was
+	// failed.
+	SkipFailed = "Failed"
+
+	// SkipMissed is a scheduled run that was due while the provider did not
+	// reconcile the CtrlDrift. Only the last of several missed runs is
+	// started.
+	SkipMissed = "Missed"
+
+	// SkipPaused is a scheduled run that was due while the pipeline was
+	// paused.
+	SkipPaused = "Paused"
+)
+
+// A SkippedRun is a scheduled run that was not started.
+type SkippedRun struct {
+	// Time the run was scheduled for.
+	Time *metav1.Time `json:"time,omitempty"`
+
+	// Reason the run was skipped.
+	// +kubebuilder:validation:Enum=Overlap;Failed;Missed;Paused
+	Reason string `json:"reason"`
+
+	// Message is a human readable explanation of the skip.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// A DatasetSnapshot is the immutable copy of the drift dataset a run trained
+// on.
+type DatasetSnapshot struct {
+	// Run the snapshot was taken for.
+	Run string `json:"run"`
+
+	// Path of the snapshot, relative to the data volume.
+	Path string `json:"path"`
+
+	// Checksum of the snapshot, e.g. "sha256:...".
+	// +optional
+	Checksum string `json:"checksum,omitempty"`
+
+	// Rows of the snapshot, the header of a CSV file excluded. The rows of a
+	// Parquet file are not counted.
+	// +optional
+	Rows int64 `json:"rows,omitempty"`
+
+	// Bytes is the size of the snapshot.
+	// +optional
+	Bytes int64 `json:"bytes,omitempty"`
+
+	// Time the snapshot was recorded.
+	// +optional
+	Time *metav1.Time `json:"time,omitempty"`
+}
+
+// A RunStatus links a run of the pipeline to its Jobs.
+type RunStatus struct {
+	// ID of the run, the time it was started.
+	ID string `json:"id"`
+
+	// Jobs of the run that currently exist, failed attempts included.
+	// +optional
+	Jobs []string `json:"jobs,omitempty"`
+}
+
+// A WorkloadDiff lists the fields of a generated workload whose live state
+// differs from the state rendered from the spec.
+type WorkloadDiff struct {
+	// Name of the workload.
+	Name string `json:"name"`
+
+	// Fields that differ, for example containers[python-tflite].image.
+	Fields []string `json:"fields"`
 }
 
 // CtrlDriftObservation are the observable fields of a CtrlDrift.
 type CtrlDriftObservation struct {
-	Drift string `json:"drift"`
+	// Phase is the pipeline step the CtrlDrift is currently in.
+	// +kubebuilder:validation:Enum=Monitoring;Drifting;Training;Evaluating;Converting;Baking;RollingOut;Rejected;Failed
+	// +optional
+	Phase Phase `json:"phase,omitempty"`
+
+	// DriftedSamples is the number of drifted samples collected since the
+	// last retrain.
+	// +optional
+	DriftedSamples int64 `json:"driftedSamples,omitempty"`
+
+	// Drift is "true" while drifted samples are collected, and "false"
+	// otherwise.
+	//
+	// Deprecated: Use DriftedSamples and Phase instead. Drift will be removed
+	// in the next release.
+	// +optional
+	Drift string `json:"drift,omitempty"`
+
+	// TotalSamples is the number of samples the detector inspected, when the
+	// drift source reports it.
+	// +optional
+	TotalSamples int64 `json:"totalSamples,omitempty"`
+
+	// DriftDetectedSince is when the currently collected drifted samples
+	// were first observed.
+	// +optional
+	DriftDetectedSince *metav1.Time `json:"driftDetectedSince,omitempty"`
+
+	// LastDriftDetectedTime is when drifted samples were last observed.
+	// +optional
+	LastDriftDetectedTime *metav1.Time `json:"lastDriftDetectedTime,omitempty"`
+
+	// OldestDriftedSampleTime is the timestamp of the oldest drifted sample
+	// of the drift data file, when the drift source has a timestamp column.
+	// +optional
+	OldestDriftedSampleTime *metav1.Time `json:"oldestDriftedSampleTime,omitempty"`
+
+	// LastTrainingStartTime is when the last training run was started.
+	// +optional
+	LastTrainingStartTime *metav1.Time `json:"lastTrainingStartTime,omitempty"`
+
+	// TrainingSamples is the number of drifted samples the last training
+	// run was started with.
+	// +optional
+	TrainingSamples int64 `json:"trainingSamples,omitempty"`
+
+	// TrainingScriptRevision is the revision of the training script used by
+	// the last training run.
+	// +optional
+	TrainingScriptRevision string `json:"trainingScriptRevision,omitempty"`
+
+	// ActiveModelScriptRevision is the revision of the training script that
+	// trained the model currently served.
+	// +optional
+	ActiveModelScriptRevision string `json:"activeModelScriptRevision,omitempty"`
+
+	// LastTrainingCompletionTime is when the last training run finished.
+	// +optional
+	LastTrainingCompletionTime *metav1.Time `json:"lastTrainingCompletionTime,omitempty"`
+
+	// Retries is the number of failed Jobs of the current run.
+	// +optional
+	Retries int32 `json:"retries,omitempty"`
+
+	// LastFailure is the last failed Job of the pipeline.
+	// +optional
+	LastFailure *JobFailure `json:"lastFailure,omitempty"`
+
+	// NextRetryTime is when the last failed Job is started again.
+	// +optional
+	NextRetryTime *metav1.Time `json:"nextRetryTime,omitempty"`
+
+	// LastScheduleTime is when the last run of the retrain schedule was due,
+	// whether it was started or skipped.
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+
+	// NextScheduleTime is when the next run of the retrain schedule is due.
+	// +optional
+	NextScheduleTime *metav1.Time `json:"nextScheduleTime,omitempty"`
+
+	// SkippedRuns are the last scheduled runs that were not started, newest
+	// first.
+	// +optional
+	SkippedRuns []SkippedRun `json:"skippedRuns,omitempty"`
+
+	// Paused is true while the pipeline is paused by the
+	// driftprovider.crossplane.io/paused annotation.
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+
+	// LastRetrainRequest is the value of the
+	// driftprovider.crossplane.io/retrain-requested annotation that last
+	// started a run.
+	// +optional
+	LastRetrainRequest string `json:"lastRetrainRequest,omitempty"`
+
+	// LastCancelRequest is the value of the
+	// driftprovider.crossplane.io/cancel-run annotation that was last
+	// handled.
+	// +optional
+	LastCancelRequest string `json:"lastCancelRequest,omitempty"`
+
+	// LastEvaluation is the outcome of the evaluation of the last trained
+	// model.
+	// +optional
+	LastEvaluation *EvaluationResult `json:"lastEvaluation,omitempty"`
+
+	// Rollout is the progress of the last Canary or Shadow rollout.
+	// +optional
+	Rollout *RolloutStatus `json:"rollout,omitempty"`
+
+	// ActiveModelVersion is the version of the model currently served by
+	// the inference Deployment. It is empty until the first retrain was
+	// rolled out.
+	// +optional
+	ActiveModelVersion string `json:"activeModelVersion,omitempty"`
+
+	// LatestModelVersion is the version of the newest recorded model,
+	// whether it was rolled out or not.
+	// +optional
+	LatestModelVersion string `json:"latestModelVersion,omitempty"`
+
+	// PinnedModelVersion is the version spec.forProvider.modelVersion pins
+	// the inference Deployment to. Automatic promotion of new models is
+	// paused while it is set.
+	// +optional
+	PinnedModelVersion string `json:"pinnedModelVersion,omitempty"`
+
+	// Deployments are the names of the Deployments generated for this
+	// CtrlDrift.
+	// +optional
+	Deployments []string `json:"deployments,omitempty"`
+
+	// OutOfSync lists the generated Deployments whose live state differs
+	// from the state rendered from the spec. They are reconciled back on the
+	// next update.
+	// +optional
+	OutOfSync []WorkloadDiff `json:"outOfSync,omitempty"`
+
+	// Jobs are the names of the Jobs generated for this CtrlDrift that
+	// currently exist.
+	// +optional
+	Jobs []string `json:"jobs,omitempty"`
+
+	// Run is the ID of the current or last run of the pipeline. The Jobs of
+	// a run are named after it.
+	// +optional
+	Run string `json:"run,omitempty"`
+
+	// Runs link the runs whose Jobs currently exist to these Jobs, newest
+	// first.
+	// +optional
+	Runs []RunStatus `json:"runs,omitempty"`
+
+	// Datasets are the snapshots of the drift dataset taken for the most
+	// recent runs, newest first.
+	// +optional
+	Datasets []DatasetSnapshot `json:"datasets,omitempty"`
+
+	// Message is a human readable explanation of the current phase.
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 // A CtrlDriftSpec defines the desired state of a CtrlDrift.
@@ -48,11 +1015,40 @@ type CtrlDriftStatus struct {
 	AtProvider          CtrlDriftObservation `json:"atProvider,omitempty"`
 }
 
+// Annotations an operator sets on a CtrlDrift to control its pipeline. The
+// value of a request annotation is an arbitrary token, such as the current
+// time. Every value is handled once, and the handled value is recorded in the
+// status, so that the same request can be made again with a new value.
+const (
+	// AnnotationRetrainRequested starts a training run, whether or not drift
+	// was detected. A request made while a run is in flight starts a new run
+	// once it is done.
+	AnnotationRetrainRequested = "driftprovider.crossplane.io/retrain-requested"
+
+	// AnnotationPaused set to "true" pauses the pipeline. A paused pipeline
+	// is still observed and finished Jobs are still cleaned up, but no Job
+	// is started, no model is rolled out and the Deployments are not
+	// updated. Remove it to resume the pipeline.
+	AnnotationPaused = "driftprovider.crossplane.io/paused"
+
+	// AnnotationCancelRun aborts the run in flight. Its Jobs and its
+	// candidate Deployment are deleted and the pipeline starts monitoring
+	// again.
+	AnnotationCancelRun = "driftprovider.crossplane.io/cancel-run"
+)
+
 // +kubebuilder:object:root=true
 
 // A CtrlDrift is an example API type.
 // +kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
 // +kubebuilder:printcolumn:name="SYNCED",type="string",JSONPath=".status.conditions[?(@.type=='Synced')].status"
+// +kubebuilder:printcolumn:name="PHASE",type="string",JSONPath=".status.atProvider.phase"
+// +kubebuilder:printcolumn:name="DRIFTED",type="integer",JSONPath=".status.atProvider.driftedSamples"
+// +kubebuilder:printcolumn:name="MODEL",type="string",JSONPath=".status.atProvider.activeModelVersion"
+// +kubebuilder:printcolumn:name="PAUSED",type="boolean",JSONPath=".status.atProvider.paused",priority=1
+// +kubebuilder:printcolumn:name="PINNED",type="string",JSONPath=".status.atProvider.pinnedModelVersion",priority=1
+// +kubebuilder:printcolumn:name="LAST-DRIFT",type="date",JSONPath=".status.atProvider.lastDriftDetectedTime",priority=1
+// +kubebuilder:printcolumn:name="LAST-TRAINING",type="date",JSONPath=".status.atProvider.lastTrainingCompletionTime",priority=1
 // +kubebuilder:printcolumn:name="EXTERNAL-NAME",type="string",JSONPath=".metadata.annotations.crossplane\\.io/external-name"
 // +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
 // +kubebuilder:subresource:status
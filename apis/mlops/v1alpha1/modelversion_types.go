@@ -0,0 +1,169 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ModelVersionParameters record a model produced by a training and conversion
+// run of a CtrlDrift. They are immutable.
+type ModelVersionParameters struct {
+	// CtrlDrift is the name of the CtrlDrift that produced the model.
+	CtrlDrift string `json:"ctrlDrift"`
+
+	// Version of the model, the UTC start time of its training run.
+	Version string `json:"version"`
+
+	// ArtifactPath of the converted model, relative to the data volume.
+	ArtifactPath string `json:"artifactPath"`
+
+	// TrainedModelPath of the model before conversion, relative to the data
+	// volume.
+	// +optional
+	TrainedModelPath string `json:"trainedModelPath,omitempty"`
+
+	// Checksum of the converted model, e.g. "sha256:...". Empty when the
+	// artifact could not be read by the provider.
+	// +optional
+	Checksum string `json:"checksum,omitempty"`
+
+	// Dataset the model was trained on.
+	// +optional
+	Dataset *ModelDataset `json:"dataset,omitempty"`
+
+	// Metrics reported by the training run in the metrics file next to
+	// the model, e.g. {"rmse": "0.12"}.
+	// +optional
+	Metrics map[string]string `json:"metrics,omitempty"`
+
+	// EvaluationMetrics reported by the evaluation Job of the CtrlDrift,
+	// when it configures one.
+	// +optional
+	EvaluationMetrics map[string]string `json:"evaluationMetrics,omitempty"`
+
+	// TrainingScriptRevision is the revision of the training script that
+	// trained the model.
+	// +optional
+	TrainingScriptRevision string `json:"trainingScriptRevision,omitempty"`
+
+	// TrainingStartTime is when the training run was started.
+	// +optional
+	TrainingStartTime *metav1.Time `json:"trainingStartTime,omitempty"`
+
+	// TrainingCompletionTime is when the training run finished.
+	// +optional
+	TrainingCompletionTime *metav1.Time `json:"trainingCompletionTime,omitempty"`
+}
+
+// A ModelDataset describes the data a model was trained on.
+type ModelDataset struct {
+	// Path of the dataset, relative to the data volume. It is the immutable
+	// snapshot of the drift dataset taken for the training run, when one was
+	// recorded.
+	Path string `json:"path"`
+
+	// Samples is the number of drifted samples the dataset held when the
+	// training run was started.
+	// +optional
+	Samples int64 `json:"samples,omitempty"`
+
+	// Checksum of the snapshot, e.g. "sha256:...".
+	// +optional
+	Checksum string `json:"checksum,omitempty"`
+
+	// Rows of the snapshot, its header excluded.
+	// +optional
+	Rows int64 `json:"rows,omitempty"`
+
+	// Bytes is the size of the snapshot.
+	// +optional
+	Bytes int64 `json:"bytes,omitempty"`
+}
+
+// ModelVersionObservation are the observable fields of a ModelVersion.
+type ModelVersionObservation struct {
+	// Active is true while the CtrlDrift serves this model.
+	// +optional
+	Active bool `json:"active,omitempty"`
+
+	// LastActiveTime is when the model was last observed being served. It
+	// is refreshed hourly while the model is served, and once more when it
+	// is observed to no longer be served.
+	// +optional
+	LastActiveTime *metav1.Time `json:"lastActiveTime,omitempty"`
+}
+
+// A ModelVersionSpec defines the desired state of a ModelVersion.
+type ModelVersionSpec struct {
+	xpv1.ResourceSpec `json:",inline"`
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="forProvider is immutable"
+	ForProvider ModelVersionParameters `json:"forProvider"`
+}
+
+// A ModelVersionStatus represents the observed state of a ModelVersion.
+type ModelVersionStatus struct {
+	xpv1.ResourceStatus `json:",inline"`
+	AtProvider          ModelVersionObservation `json:"atProvider,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// A ModelVersion records a model produced by a CtrlDrift. One is created for
+// every completed training and conversion run, so that every model that
+// reached production can be audited. It is named <ctrldrift>-<version>.
+// ModelVersions outlive their CtrlDrift.
+// +kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+// +kubebuilder:printcolumn:name="CTRLDRIFT",type="string",JSONPath=".spec.forProvider.ctrlDrift"
+// +kubebuilder:printcolumn:name="VERSION",type="string",JSONPath=".spec.forProvider.version"
+// +kubebuilder:printcolumn:name="ACTIVE",type="boolean",JSONPath=".status.atProvider.active"
+// +kubebuilder:printcolumn:name="CHECKSUM",type="string",JSONPath=".spec.forProvider.checksum",priority=1
+// +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,categories={crossplane,managed,driftprovider}
+type ModelVersion struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ModelVersionSpec   `json:"spec"`
+	Status ModelVersionStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ModelVersionList contains a list of ModelVersion
+type ModelVersionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ModelVersion `json:"items"`
+}
+
+// ModelVersion type metadata.
+var (
+	ModelVersionKind             = reflect.TypeOf(ModelVersion{}).Name()
+	ModelVersionGroupKind        = schema.GroupKind{Group: Group, Kind: ModelVersionKind}.String()
+	ModelVersionKindAPIVersion   = ModelVersionKind + "." + SchemeGroupVersion.String()
+	ModelVersionGroupVersionKind = SchemeGroupVersion.WithKind(ModelVersionKind)
+)
+
+func init() {
+	SchemeBuilder.Register(&ModelVersion{}, &ModelVersionList{})
+}
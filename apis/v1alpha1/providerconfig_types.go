@@ -27,8 +27,66 @@ import (
 
 // A ProviderConfigSpec defines the desired state of a ProviderConfig.
 type ProviderConfigSpec struct {
-	// Credentials required to authenticate to this provider.
+	// Credentials required to authenticate to this provider. When Broker
+	// sets a username but no passwordSecretRef they are used as the
+	// broker password.
 	Credentials ProviderCredentials `json:"credentials"`
+
+	// Broker is the MQTT broker the generated workloads and the provider
+	// connect to. Every CtrlDrift using this ProviderConfig shares it.
+	// Defaults to lserf-tinyml.cloudmmwunibo.it:1883 without
+	// authentication.
+	// +optional
+	Broker *BrokerConfig `json:"broker,omitempty"`
+}
+
+// A BrokerConfig configures the connection to an MQTT broker.
+type BrokerConfig struct {
+	// Host of the broker, e.g. "mosquitto.mqtt.svc".
+	// +kubebuilder:validation:MinLength=1
+	Host string `json:"host"`
+
+	// Port of the broker. Defaults to 8883 with TLS and to 1883 otherwise.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port *int32 `json:"port,omitempty"`
+
+	// Username to authenticate with.
+	// +optional
+	Username *string `json:"username,omitempty"`
+
+	// PasswordSecretRef selects the password to authenticate with.
+	// +optional
+	PasswordSecretRef *xpv1.SecretKeySelector `json:"passwordSecretRef,omitempty"`
+
+	// TLS enables TLS, and mutual TLS when a client certificate is set.
+	// +optional
+	TLS *BrokerTLS `json:"tls,omitempty"`
+}
+
+// A BrokerTLS configures TLS for the connection to an MQTT broker.
+type BrokerTLS struct {
+	// CASecretRef selects the PEM encoded CA certificates the broker's
+	// certificate is verified with. The system roots are used when
+	// omitted.
+	// +optional
+	CASecretRef *xpv1.SecretKeySelector `json:"caSecretRef,omitempty"`
+
+	// ClientCertificateSecretRef references a kubernetes.io/tls Secret
+	// with the client certificate and key used for mutual TLS.
+	// +optional
+	ClientCertificateSecretRef *xpv1.SecretReference `json:"clientCertificateSecretRef,omitempty"`
+
+	// ServerName overrides the name the broker's certificate is verified
+	// against. Defaults to Host.
+	// +optional
+	ServerName *string `json:"serverName,omitempty"`
+
+	// InsecureSkipVerify disables the verification of the broker's
+	// certificate. Only use it for testing.
+	// +optional
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
 }
 
 // ProviderCredentials required to authenticate.
@@ -51,6 +109,7 @@ type ProviderConfigStatus struct {
 // +kubebuilder:subresource:status
 // +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
 // +kubebuilder:printcolumn:name="SECRET-NAME",type="string",JSONPath=".spec.credentials.secretRef.name",priority=1
+// +kubebuilder:printcolumn:name="BROKER",type="string",JSONPath=".spec.broker.host"
 // +kubebuilder:resource:scope=Cluster
 type ProviderConfig struct {
 	metav1.TypeMeta   `json:",inline"`
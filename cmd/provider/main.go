@@ -40,6 +40,7 @@ import (
 	"github.com/crossplane/provider-driftprovider/apis"
 	"github.com/crossplane/provider-driftprovider/apis/v1alpha1"
 	driftprovider "github.com/crossplane/provider-driftprovider/internal/controller"
+	"github.com/crossplane/provider-driftprovider/internal/controller/ctrldrift"
 	"github.com/crossplane/provider-driftprovider/internal/features"
 )
 
@@ -76,6 +77,10 @@ func main() {
 		// The recommended way is to move it to cache.Options instead
 		Cache: cache.Options{
 			SyncPeriod: syncInterval,
+
+			// The CtrlDrift controller reads its Deployments and Jobs
+			// from the cache. Only cache the ones it generated.
+			ByObject: ctrldrift.CacheByObject(),
 		},
 
 		// controller-runtime uses both ConfigMaps and Leases for leader
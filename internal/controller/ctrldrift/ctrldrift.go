@@ -19,28 +19,42 @@ package ctrldrift
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	cevent "sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/crossplane/crossplane-runtime/pkg/connection"
 	"github.com/crossplane/crossplane-runtime/pkg/controller"
 	"github.com/crossplane/crossplane-runtime/pkg/event"
 	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
 	"github.com/crossplane/crossplane-runtime/pkg/ratelimiter"
 	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
 	"github.com/crossplane/crossplane-runtime/pkg/resource"
 
 	"github.com/crossplane/provider-driftprovider/apis/mlops/v1alpha1"
 	apisv1alpha1 "github.com/crossplane/provider-driftprovider/apis/v1alpha1"
+	"github.com/crossplane/provider-driftprovider/internal/driftevents"
+	"github.com/crossplane/provider-driftprovider/internal/driftsource"
 	"github.com/crossplane/provider-driftprovider/internal/features"
-
-	"os"
-	"strings"
-
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"github.com/crossplane/provider-driftprovider/internal/pipeline"
+	"github.com/crossplane/provider-driftprovider/internal/retrain"
 )
 
 const (
@@ -49,14 +63,23 @@ const (
 	errGetPC        = "cannot get ProviderConfig"
 	errGetCreds     = "cannot get credentials"
 
-	errNewClient = "cannot create new Service"
-)
-
-// A NoOpService does nothing.
-type NoOpService struct{}
-
-var (
-	newNoOpService = func(_ []byte) (interface{}, error) { return &NoOpService{}, nil }
+	errNewClient = "cannot configure MQTT broker"
+
+	errListDeployments  = "cannot list deployments"
+	errCreateDeployment = "cannot create deployment"
+	errGetDeployment    = "cannot get deployment"
+	errPatchDeployment  = "cannot patch deployment"
+	errDeleteDeployment = "cannot delete deployment"
+	errListJobs         = "cannot list jobs"
+	errCreateJob        = "cannot create job"
+	errDeleteJob        = "cannot delete job"
+	errGetVolume        = "cannot get data volume claim"
+	errVolumeMissing    = "data volume claim %s/%s does not exist"
+	errDriftSource      = "cannot configure drift source"
+	errReadDriftData    = "cannot read drift data"
+	errNewClientset     = "cannot create Kubernetes clientset"
+	errAddHub           = "cannot add drift events hub to manager"
+	errEvaluatePolicy   = "cannot evaluate retrain policy"
 )
 
 // Setup adds a controller that reconciles CtrlDrift managed resources.
@@ -68,23 +91,57 @@ func Setup(mgr ctrl.Manager, o controller.Options) error {
 		cps = append(cps, connection.NewDetailsManager(mgr.GetClient(), apisv1alpha1.StoreConfigGroupVersionKind))
 	}
 
+	recorder := event.NewAPIRecorder(mgr.GetEventRecorderFor(name))
+
+	clientset, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return errors.Wrap(err, errNewClientset)
+	}
+
+	// Drift events received over MQTT and due scheduled runs enqueue the
+	// CtrlDrifts they are about. An event that does not fit in the channel
+	// is dropped, the next poll picks the drift or the run up anyway.
+	drift_events := make(chan cevent.GenericEvent, 1024)
+	enqueue := func(owner string) {
+		select {
+		case drift_events <- cevent.GenericEvent{Object: &v1alpha1.CtrlDrift{ObjectMeta: metav1.ObjectMeta{Name: owner}}}:
+		default:
+		}
+	}
+	hub := driftevents.NewHub(mqtt_client_id(), enqueue, o.Logger.WithValues("controller", name))
+	if err := mgr.Add(hub); err != nil {
+		return errors.Wrap(err, errAddHub)
+	}
+
 	r := managed.NewReconciler(mgr,
 		resource.ManagedKind(v1alpha1.CtrlDriftGroupVersionKind),
 		managed.WithExternalConnecter(&connector{
 			kube:         mgr.GetClient(),
+			reader:       mgr.GetAPIReader(),
+			clientset:    clientset,
+			events:       hub,
+			wakeups:      new_wakeups(enqueue),
+			cursors:      new_cursors(),
 			usage:        resource.NewProviderConfigUsageTracker(mgr.GetClient(), &apisv1alpha1.ProviderConfigUsage{}),
 			logger:       o.Logger,
-			newServiceFn: newNoOpService}),
+			recorder:     recorder,
+			newServiceFn: new_broker_service}),
+		managed.WithFinalizer(&orphaningFinalizer{
+			Finalizer: resource.NewAPIFinalizer(mgr.GetClient(), managed.FinalizerName),
+			kube:      mgr.GetClient(),
+			logger:    o.Logger.WithValues("controller", name)}),
 		managed.WithLogger(o.Logger.WithValues("controller", name)),
 		managed.WithPollInterval(o.PollInterval),
-		managed.WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
+		managed.WithRecorder(recorder),
 		managed.WithConnectionPublishers(cps...))
 
 	return ctrl.NewControllerManagedBy(mgr).
 		Named(name).
 		WithOptions(o.ForControllerRuntime()).
-		WithEventFilter(resource.DesiredStateChanged()).
-		For(&v1alpha1.CtrlDrift{}).
+		For(&v1alpha1.CtrlDrift{}, builder.WithPredicates(resource.DesiredStateChanged())).
+		Watches(&batchv1.Job{}, enqueue_ctrldrift()).
+		Watches(&appsv1.Deployment{}, enqueue_ctrldrift()).
+		WatchesRawSource(&source.Channel{Source: drift_events}, &handler.EnqueueRequestForObject{}).
 		Complete(ratelimiter.NewReconciler(name, r, o.GlobalRateLimiter))
 }
 
@@ -92,16 +149,22 @@ func Setup(mgr ctrl.Manager, o controller.Options) error {
 // is called.
 type connector struct {
 	kube         client.Client
+	reader       client.Reader
+	clientset    kubernetes.Interface
+	events       *driftevents.Hub
+	wakeups      *wakeups
+	cursors      *cursors
 	usage        resource.Tracker
 	logger       logging.Logger
-	newServiceFn func(creds []byte) (interface{}, error)
+	recorder     event.Recorder
+	newServiceFn func(ctx context.Context, reader client.Reader, pc *apisv1alpha1.ProviderConfig, creds []byte) (*brokerService, error)
 }
 
 // Connect typically produces an ExternalClient by:
 // 1. Tracking that the managed resource is using a ProviderConfig.
 // 2. Getting the managed resource's ProviderConfig.
 // 3. Getting the credentials specified by the ProviderConfig.
-// 4. Using the credentials to form a client.
+// 4. Resolving the MQTT broker of the ProviderConfig with its credentials.
 func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.ExternalClient, error) {
 	cr, ok := mg.(*v1alpha1.CtrlDrift)
 	if !ok {
@@ -123,21 +186,46 @@ func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.E
 		return nil, errors.Wrap(err, errGetCreds)
 	}
 
-	svc, err := c.newServiceFn(data)
+	//the broker Secrets are read from the manager's cache, like the
+	//credentials, rather than from the API server on every reconcile
+	svc, err := c.newServiceFn(ctx, c.kube, pc, data)
 	if err != nil {
 		return nil, errors.Wrap(err, errNewClient)
 	}
 
-	return &external{service: svc, logger: c.logger}, nil
+	return &external{service: svc, kube: c.kube, reader: c.reader, clientset: c.clientset, events: c.events, wakeups: c.wakeups, cursors: c.cursors, logger: c.logger, recorder: c.recorder}, nil
 }
 
 // An ExternalClient observes, then either creates, updates, or deletes an
 // external resource to ensure it reflects the managed resource's desired state.
 type external struct {
-	// A 'client' used to connect to the external resource API. In practice this
-	// would be something like an AWS SDK client.
-	service interface{}
-	logger  logging.Logger
+	// service is the MQTT broker of the ProviderConfig. The generated
+	// workloads are connected to it.
+	service *brokerService
+
+	// kube manages the workloads of the pipeline. Its reads are served
+	// from the manager's cache.
+	kube client.Client
+
+	// reader reads objects the manager does not cache, such as Secrets,
+	// straight from the API server.
+	reader client.Reader
+
+	// clientset runs the pods of a Pod drift source and streams their
+	// logs, which the controller-runtime client cannot do.
+	clientset kubernetes.Interface
+
+	// events receives the drift statistics of MQTT drift sources.
+	events *driftevents.Hub
+
+	// wakeups enqueue the CtrlDrift when its next scheduled run is due.
+	wakeups *wakeups
+
+	// cursors remember how far the drift data file was read.
+	cursors *cursors
+
+	logger   logging.Logger
+	recorder event.Recorder
 }
 
 func (c *external) Observe(ctx context.Context, mg resource.Managed) (managed.ExternalObservation, error) {
@@ -147,168 +235,299 @@ func (c *external) Observe(ctx context.Context, mg resource.Managed) (managed.Ex
 	}
 	c.logger.Debug(fmt.Sprintf("Observing: %+v", cr))
 
-	//connect to kubernetes
-	clientset, err := c.connect_kube_client()
-
-	folder_path := "/var/data/"
-	drift_data := "drift_data.csv"
-	drifting := false
+	//a deleted CtrlDrift only waits for its workloads to be gone
+	if meta.WasDeleted(cr) {
+		return c.observe_deletion(ctx, cr)
+	}
 
 	resource_exists := false
 	resource_uptodate := true
 
-	if err != nil {
-		c.logger.Debug("Error in connecting to kubernetes")
-		c.logger.Debug(err.Error())
+	//serve the pinned model, or the newest one again once unpinned
+	if err := c.apply_model_pin(ctx, cr); err != nil {
+		reason := reasonKubernetesAPIError
+		if kerrors.IsNotFound(errors.Cause(err)) {
+			reason = reasonModelVersionMissing
+		}
+		return managed.ExternalObservation{}, c.fail(cr, reason, err)
 	}
 
-	//check if drifting deployment is running
-	deployments, err := clientset.AppsV1().Deployments("default").List(ctx, metav1.ListOptions{})
-	if err != nil {
-		c.logger.Debug("Error in listing deployments")
-		c.logger.Debug(err.Error())
+	//check if drifting deployment is running and serves the last trained model
+	deployments := &appsv1.DeploymentList{}
+	if err := c.kube.List(ctx, deployments, workload_list_options(cr)...); err != nil {
+		return managed.ExternalObservation{}, c.fail(cr, reasonKubernetesAPIError, errors.Wrap(err, errListDeployments))
 	}
 
-	for _, deployment := range deployments.Items {
-		if deployment.Name == "drift-deploy" {
+	rolled_out := true
+	var candidate *appsv1.Deployment
+	cr.Status.AtProvider.Deployments = nil
+	for i, deployment := range deployments.Items {
+		cr.Status.AtProvider.Deployments = append(cr.Status.AtProvider.Deployments, deployment.Name)
+		if deployment.Name == drift_deployment_name(cr) {
 			c.logger.Debug("Drift detection deployment already running")
 			resource_exists = true
 		}
+		if deployment.Name == candidate_deployment_name(cr) {
+			//the candidate is managed by the pipeline, not by Create and Update
+			candidate = &deployments.Items[i]
+			continue
+		}
+		if deployment.Spec.Template.Annotations[annotationModelVersion] != target_model_version(cr) {
+			rolled_out = false
+		}
 	}
 
-	//get all files in folder
-	files, err := os.ReadDir(folder_path)
+	//compare the deployments with the state rendered from the spec, Create
+	//renders them all when they do not exist
+	cr.Status.AtProvider.OutOfSync = nil
+	if resource_exists {
+		cr.Status.AtProvider.OutOfSync = out_of_sync(c.deployments(cr), deployments.Items)
+	}
+	for _, diff := range cr.Status.AtProvider.OutOfSync {
+		c.logger.Debug(fmt.Sprintf("Deployment %s is out of sync: %v", diff.Name, diff.Fields))
+		resource_uptodate = false
+	}
 
-	if err != nil {
-		c.logger.Debug("Error in reading directory")
-		c.logger.Debug(err.Error())
+	//keep the broker credentials of the workloads up to date
+	if c.service != nil {
+		if err := c.apply_broker_secret(ctx, cr); err != nil {
+			return managed.ExternalObservation{}, c.fail(cr, reasonKubernetesAPIError, err)
+		}
 	}
 
-	for _, file := range files {
-		if file.Name() == drift_data {
-			c.logger.Debug("drift data file found")
-			drifting = true
+	//count drifted samples
+	src, err := c.drift_source(ctx, cr)
+	if err != nil {
+		return managed.ExternalObservation{}, c.fail(cr, reasonDriftDataUnreadable, errors.Wrap(err, errDriftSource))
+	}
+	stats, err := src.Stats(ctx)
+	if driftsource.IsPending(err) {
+		//keep the last known statistics until the source can be read
+		c.logger.Debug(fmt.Sprintf("Drift data not available yet: %s", err))
+		stats = driftsource.Stats{DriftedSamples: cr.Status.AtProvider.DriftedSamples, TotalSamples: cr.Status.AtProvider.TotalSamples}
+		if t := cr.Status.AtProvider.OldestDriftedSampleTime; t != nil {
+			stats.OldestSample = t.Time
 		}
+	} else if err != nil {
+		return managed.ExternalObservation{}, c.fail(cr, reasonDriftDataUnreadable, errors.Wrap(err, errReadDriftData))
 	}
-	if drifting {
-		//check data drift length as parameter for retraining
-		content, err := os.ReadFile(folder_path + drift_data)
-		if err != nil {
-			c.logger.Debug("Error in reading drifted data")
-			c.logger.Debug(err.Error())
+	samples := stats.DriftedSamples
+	c.logger.Debug(fmt.Sprintf("Number of drifted samples in %s: %d of %d", drift_file(cr), samples, stats.TotalSamples))
+
+	//remember when the current drift was first and last seen
+	now := metav1.Now()
+	if samples == 0 {
+		cr.Status.AtProvider.DriftDetectedSince = nil
+	} else {
+		if cr.Status.AtProvider.DriftDetectedSince == nil {
+			cr.Status.AtProvider.DriftDetectedSince = &now
 		}
-		//count /n
-		lines := strings.Split(string(content), "\n")
-		c.logger.Debug(fmt.Sprintf("Number of lines in %s: %d", drift_data, len(lines)))
+		if samples > cr.Status.AtProvider.DriftedSamples {
+			cr.Status.AtProvider.LastDriftDetectedTime = &now
+		}
+	}
+	cr.Status.AtProvider.DriftedSamples = samples
+	//kept for clients of the deprecated field until it is removed
+	cr.Status.AtProvider.Drift = strconv.FormatBool(samples > 0)
+	cr.Status.AtProvider.TotalSamples = stats.TotalSamples
+	cr.Status.AtProvider.OldestDriftedSampleTime = nil
+	if !stats.OldestSample.IsZero() {
+		oldest := metav1.NewTime(stats.OldestSample)
+		cr.Status.AtProvider.OldestDriftedSampleTime = &oldest
+	}
 
-		if len(lines) > 3000 {
-			//check if the new nodel has been trained on the new data
+	observation := retrain.Observation{DriftedSamples: samples, TotalSamples: stats.TotalSamples}
+	//the timestamps of the samples are more accurate than when drift was first seen
+	if t := cr.Status.AtProvider.OldestDriftedSampleTime; t != nil && samples > 0 {
+		observation.OldestDriftedSample = t.Time
+	} else if cr.Status.AtProvider.DriftDetectedSince != nil {
+		observation.OldestDriftedSample = cr.Status.AtProvider.DriftDetectedSince.Time
+	}
+	if cr.Status.AtProvider.LastTrainingStartTime != nil {
+		observation.LastRetrain = cr.Status.AtProvider.LastTrainingStartTime.Time
+	}
 
-			c.logger.Debug("Data drift detected, retraining needed")
+	decision, err := retrain.Evaluate(cr.Spec.ForProvider.RetrainPolicy, observation, now.Time)
+	if err != nil {
+		return managed.ExternalObservation{}, c.fail(cr, reasonInvalidRetrainPolicy, errors.Wrap(err, errEvaluatePolicy))
+	}
+	c.logger.Debug(fmt.Sprintf("Retrain policy: %s (%s)", decision.Reason, decision.Message))
 
-			//check if training job is running
-			jobs, err := clientset.BatchV1().Jobs("default").List(ctx, metav1.ListOptions{})
-			if err != nil {
-				c.logger.Debug("Error in listing jobs")
-				c.logger.Debug(err.Error())
-			}
-			//if no jobs are running, start training job
-			if len(jobs.Items) == 0 {
-				c.logger.Debug("Start training job")
-				//create job
-				training_job := get_training_job()
-
-				_, err = clientset.BatchV1().Jobs("default").Create(ctx, training_job, metav1.CreateOptions{})
-				if err != nil {
-					c.logger.Debug("Error in creating training job")
-					c.logger.Debug(err.Error())
-				} else {
-					c.logger.Debug("training job created")
-				}
-			}
+	//honor the control annotations of the operator
+	c.observe_pause(cr)
+	requested := retrain_requested(cr)
 
-			for _, job := range jobs.Items {
-				if job.Name == "training-job" {
-					c.logger.Debug("Training job already running")
-				} else {
-					c.logger.Debug("Start training job")
-					//create job
-					training_job := get_training_job()
-
-					_, err = clientset.BatchV1().Jobs("default").Create(ctx, training_job, metav1.CreateOptions{})
-					if err != nil {
-						c.logger.Debug("Error in creating job")
-						c.logger.Debug(err.Error())
-					} else {
-						c.logger.Debug("Job created")
-					}
-
-					//delete resource
-
-				}
-			}
+	//start a scheduled run whether or not drift was detected
+	scheduled, err := c.scheduled_run(cr, now)
+	if err != nil {
+		return managed.ExternalObservation{}, c.fail(cr, reasonInvalidRetrainPolicy, err)
+	}
+	c.logger.Debug(fmt.Sprintf("Retrain schedule: %s (%s)", scheduled.Reason, scheduled.Message))
+
+	//find the jobs of the current run
+	jobs := &batchv1.JobList{}
+	if err := c.kube.List(ctx, jobs, workload_list_options(cr)...); err != nil {
+		return managed.ExternalObservation{}, c.fail(cr, reasonKubernetesAPIError, errors.Wrap(err, errListJobs))
+	}
 
+	training_job, evaluation_job, converting_job := run_jobs(jobs.Items)
+
+	//a cancel request without a run in flight has nothing to abort
+	cancel := cancel_requested(cr)
+	if cancel != "" && idle_phase(cr.Status.AtProvider.Phase) && training_job == nil && evaluation_job == nil && converting_job == nil && candidate == nil {
+		c.acknowledge_cancel(cr, cancel, "no run in flight")
+		cancel = ""
+	}
+
+	//record failed jobs with the end of their log and schedule their retry
+	for _, job := range []*batchv1.Job{training_job, evaluation_job, converting_job} {
+		if pipeline.JobStateOf(job) == pipeline.JobFailed {
+			c.record_job_failure(ctx, cr, job, now)
 		}
 	}
 
-	//check if conversion job is running
-	jobs, err := clientset.BatchV1().Jobs("default").List(ctx, metav1.ListOptions{})
-	if err != nil {
-		c.logger.Debug("Error in listing jobs")
-		c.logger.Debug(err.Error())
-	}
-
-	for _, job := range jobs.Items {
-		if job.Name == "converting-job" {
-			//check if job is completed
-			if job.Status.Succeeded == 1 {
-				c.logger.Debug("Conversion job completed")
-				//delete job
-				delete_options := metav1.DeleteOptions{PropagationPolicy: &[]metav1.DeletionPropagation{"Background"}[0]}
-				err = clientset.BatchV1().Jobs("default").Delete(ctx, job.Name, delete_options)
-				if err != nil {
-					c.logger.Debug("Error in deleting job")
-					c.logger.Debug(err.Error())
-				}
-				//change model in deployment
-
-				//reload drift and inference deployment
-				resource_uptodate = false
-			} else {
-				c.logger.Debug("Conversion job still running")
+	//check the metrics of a finished evaluation
+	verdict := pipeline.VerdictUnknown
+	if cr.Spec.ForProvider.Evaluation != nil && pipeline.JobStateOf(evaluation_job) == pipeline.JobSucceeded {
+		verdict, err = c.evaluate(ctx, cr)
+		if err != nil {
+			reason := reasonEvaluationInvalid
+			if _, ok := errors.Cause(err).(kerrors.APIStatus); ok {
+				reason = reasonKubernetesAPIError
 			}
+			return managed.ExternalObservation{}, c.fail(cr, reason, err)
+		}
+	}
 
+	//judge a candidate that finished baking
+	bakeoff := pipeline.VerdictUnknown
+	if rollout_strategy(cr) != v1alpha1.RolloutRecreate && cr.Status.AtProvider.Phase == v1alpha1.PhaseBaking {
+		bakeoff, err = c.judge_rollout(ctx, cr, candidate, now)
+		if err != nil {
+			return managed.ExternalObservation{}, c.fail(cr, reasonEvaluationInvalid, err)
 		}
-		//check if job is completed
-		if job.Name == "training-job" {
-			if job.Status.Succeeded == 1 {
-				c.logger.Debug("Training job completed")
-
-				//delete job and pod
-				delete_options := metav1.DeleteOptions{PropagationPolicy: &[]metav1.DeletionPropagation{"Background"}[0]}
-				err = clientset.BatchV1().Jobs("default").Delete(ctx, job.Name, delete_options)
-				if err != nil {
-					c.logger.Debug("Error in deleting job")
-					c.logger.Debug(err.Error())
-				}
-
-				//convert model to tflite running convert
-				convert_job := get_converting_job()
-
-				_, err = clientset.BatchV1().Jobs("default").Create(ctx, convert_job, metav1.CreateOptions{})
-				if err != nil {
-					c.logger.Debug("Error in creating job")
-					c.logger.Debug(err.Error())
-				} else {
-					c.logger.Debug("conversion job created")
-				}
-			} else {
-				c.logger.Debug("Training job still running")
-			}
+	}
+
+	//record the dataset snapshot the training of the current run took
+	if trained(jobs.Items, run_id(cr)) {
+		err := c.record_dataset_snapshot(ctx, cr, run_id(cr), now)
+		if driftsource.IsPending(err) {
+			c.logger.Debug(fmt.Sprintf("Dataset snapshot of run %s not recorded yet: %s", run_id(cr), err))
+		} else if err != nil {
+			return managed.ExternalObservation{}, c.fail(cr, reasonKubernetesAPIError, err)
+		}
+	}
+
+	transition := pipeline.Next(pipeline.Observation{
+		Phase:          cr.Status.AtProvider.Phase,
+		DriftedSamples: samples,
+		Retrain:        decision.Retrain || scheduled.Due || requested != "",
+		Training:       pipeline.JobStateOf(training_job),
+		Evaluate:       cr.Spec.ForProvider.Evaluation != nil,
+		Evaluating:     pipeline.JobStateOf(evaluation_job),
+		Verdict:        verdict,
+		Converting:     pipeline.JobStateOf(converting_job),
+		RetriesLeft:    retries_left(cr),
+		BackingOff:     backing_off(cr, now.Time),
+		Bake:           rollout_strategy(cr) != v1alpha1.RolloutRecreate,
+		Candidate:      candidate != nil,
+		Bakeoff:        bakeoff,
+		RolledOut:      rolled_out,
+		Pinned:         pinned_model_version(cr) != "",
+		Paused:         cr.Status.AtProvider.Paused,
+		Cancel:         cancel != "",
+		Restart:        requested != "" || spec_changed(cr, training_job, evaluation_job, converting_job),
+	})
+	c.logger.Debug(fmt.Sprintf("Pipeline: %s -> %s %v (%s)", cr.Status.AtProvider.Phase, transition.Phase, transition.Actions, transition.Message))
+
+	run := &pipelineRun{
+		now:        now,
+		samples:    samples,
+		decision:   decision,
+		scheduled:  scheduled,
+		requested:  requested,
+		cancel:     cancel,
+		training:   training_job,
+		evaluation: evaluation_job,
+		converting: converting_job,
+		candidate:  candidate,
+		transition: &transition,
+	}
+	action_err := c.run_actions(ctx, cr, run)
+	requested = run.requested
+	if run.outdated {
+		resource_uptodate = false
+	}
+
+	if transition.Phase != cr.Status.AtProvider.Phase {
+		c.recorder.Event(cr, event.Normal(reasonPhaseChanged, fmt.Sprintf("%s: %s", transition.Phase, transition.Message)))
+	}
+	cr.Status.AtProvider.Phase = transition.Phase
+	cr.Status.AtProvider.Message = transition.Message
+	if transition.Phase == v1alpha1.PhaseRejected {
+		if r := cr.Status.AtProvider.Rollout; r != nil && r.ModelVersion == model_version(cr) && r.Outcome == v1alpha1.RolloutRolledBack {
+			cr.Status.AtProvider.Message += ": rolled back, " + r.Message
+		} else if cr.Status.AtProvider.LastEvaluation != nil {
+			cr.Status.AtProvider.Message += ": " + cr.Status.AtProvider.LastEvaluation.Message
+		}
+	}
+	switch transition.Phase {
+	case v1alpha1.PhaseFailed:
+		if msg := failure_message(cr); msg != "" {
+			cr.Status.AtProvider.Message += ": " + msg
+		}
+	case v1alpha1.PhaseMonitoring, v1alpha1.PhaseDrifting:
+		cr.Status.AtProvider.Retries = 0
+		cr.Status.AtProvider.NextRetryTime = nil
+	}
+	if next := cr.Status.AtProvider.NextRetryTime; next != nil && transition.Phase != v1alpha1.PhaseFailed {
+		cr.Status.AtProvider.Message += fmt.Sprintf(" (retry %d of %d at %s)", cr.Status.AtProvider.Retries, retry_limit(cr), next.UTC().Format(time.RFC3339))
+	}
+	if pin := pinned_model_version(cr); pin != "" {
+		cr.Status.AtProvider.Message += fmt.Sprintf(", model %s is pinned and promotion is paused", pin)
+	}
+	if requested != "" {
+		cr.Status.AtProvider.Message += fmt.Sprintf(", retrain %q requested", requested)
+	}
+	if cr.Status.AtProvider.Paused {
+		//a paused pipeline leaves the deployments as they are
+		resource_uptodate = true
+		if !strings.HasPrefix(cr.Status.AtProvider.Message, "pipeline paused") {
+			cr.Status.AtProvider.Message += ", pipeline paused"
+		}
+	}
+
+	//keep a bounded history of the retired jobs
+	history := jobs.Items
+	if action_err == nil {
+		if history, err = c.prune_history(ctx, cr, jobs.Items); err != nil {
+			action_err = c.fail(cr, reasonKubernetesAPIError, err)
+			history = jobs.Items
+		}
+	}
+	listed := map[string]bool{}
+	for _, job := range history {
+		listed[job.Name] = true
+	}
+	for _, job := range run.created {
+		if !listed[job.Name] {
+			history = append(history, job)
 		}
 	}
+	cr.Status.AtProvider.Jobs = nil
+	for i := range history {
+		if !retired(&history[i]) {
+			cr.Status.AtProvider.Jobs = append(cr.Status.AtProvider.Jobs, history[i].Name)
+		}
+	}
+	sort.Strings(cr.Status.AtProvider.Jobs)
+	cr.Status.AtProvider.Runs = run_history(history)
 
-	c.logger.Debug(fmt.Sprintf("Drifting: %t", drifting))
+	if action_err != nil {
+		cr.Status.AtProvider.Message = action_err.Error()
+		return managed.ExternalObservation{}, action_err
+	}
+	cr.SetConditions(ready_condition(cr, deployments.Items))
 
 	return managed.ExternalObservation{
 		// Return false when the external resource does not exist. This lets
@@ -335,32 +554,29 @@ func (c *external) Create(ctx context.Context, mg resource.Managed) (managed.Ext
 
 	c.logger.Debug(fmt.Sprintf("Creating: %+v", cr))
 
-	//connect to kubernetes
-	clientset, err := c.connect_kube_client()
-
-	if err != nil {
-		c.logger.Debug("Error in connecting to kubernetes")
-		c.logger.Debug(err.Error())
+	//the deployments cannot start without their data volume
+	for _, spec := range []*v1alpha1.WorkloadSpec{cr.Spec.ForProvider.Detector, cr.Spec.ForProvider.Inference} {
+		err := c.reader.Get(ctx, types.NamespacedName{Namespace: deploy_namespace(cr), Name: data_claim_name(spec)}, &corev1.PersistentVolumeClaim{})
+		if kerrors.IsNotFound(err) {
+			return managed.ExternalCreation{}, c.fail(cr, reasonVolumeMissing, errors.Errorf(errVolumeMissing, deploy_namespace(cr), data_claim_name(spec)))
+		}
+		if err != nil {
+			return managed.ExternalCreation{}, c.fail(cr, reasonKubernetesAPIError, errors.Wrap(err, errGetVolume))
+		}
 	}
 
-	//create drift deployment
-
-	deployment := get_drift_detection_deployment()
-
-	_, err = clientset.AppsV1().Deployments("default").Create(ctx, deployment, metav1.CreateOptions{})
-	if err != nil {
-		c.logger.Debug("Error in creating drift deployment")
-		c.logger.Debug(err.Error())
+	if c.service != nil {
+		if err := c.apply_broker_secret(ctx, cr); err != nil {
+			return managed.ExternalCreation{}, c.fail(cr, reasonKubernetesAPIError, err)
+		}
 	}
 
-	//create inference deployment
-
-	deployment = get_tflite_deployment()
-
-	_, err = clientset.AppsV1().Deployments("default").Create(ctx, deployment, metav1.CreateOptions{})
-	if err != nil {
-		c.logger.Debug("Error in creating tflite deployment")
-		c.logger.Debug(err.Error())
+	//create drift and inference deployment
+	for _, deployment := range c.deployments(cr) {
+		err := c.kube.Create(ctx, deployment)
+		if err != nil && !kerrors.IsAlreadyExists(err) {
+			return managed.ExternalCreation{}, c.fail(cr, reasonKubernetesAPIError, errors.Wrap(err, errCreateDeployment))
+		}
 	}
 
 	return managed.ExternalCreation{
@@ -377,52 +593,43 @@ func (c *external) Update(ctx context.Context, mg resource.Managed) (managed.Ext
 	}
 	c.logger.Debug(fmt.Sprintf("Updating: %+v", cr))
 
-	//connect to kubernetes
-	clientset, err := c.connect_kube_client()
-
-	if err != nil {
-		c.logger.Debug("Error in connecting to kubernetes")
-		c.logger.Debug(err.Error())
-	}
-
-	//restart deployment drift detection
-
-	err = clientset.AppsV1().Deployments("default").Delete(ctx, "drift-deploy", metav1.DeleteOptions{})
-	if err != nil {
-		c.logger.Debug("Error in deleting drift deployment")
-		c.logger.Debug(err.Error())
-	}
-
-	deployment := get_drift_detection_deployment()
-
-	_, err = clientset.AppsV1().Deployments("default").Create(ctx, deployment, metav1.CreateOptions{})
-	if err != nil {
-		c.logger.Debug("Error in creating drift deployment")
-		c.logger.Debug(err.Error())
-	}
-
-	c.logger.Debug("Deployment drift restarted")
-
-	//restart deployment inference
-
-	err = clientset.AppsV1().Deployments("default").Delete(ctx, "python-tflite-deploy", metav1.DeleteOptions{})
-
-	if err != nil {
-		c.logger.Debug("Error in deleting tflite deployment")
-		c.logger.Debug(err.Error())
-	}
-
-	deployment = get_tflite_deployment()
+	//roll drift and inference deployment over to their new pod template
+	for _, deployment := range c.deployments(cr) {
+		live := &appsv1.Deployment{}
+		err := c.kube.Get(ctx, types.NamespacedName{Namespace: deployment.Namespace, Name: deployment.Name}, live)
+		if kerrors.IsNotFound(err) {
+			if err := c.kube.Create(ctx, deployment); err != nil {
+				return managed.ExternalUpdate{}, c.fail(cr, reasonKubernetesAPIError, errors.Wrap(err, errCreateDeployment))
+			}
+			c.logger.Debug(fmt.Sprintf("Deployment %s created", deployment.Name))
+			continue
+		}
+		if err != nil {
+			return managed.ExternalUpdate{}, c.fail(cr, reasonKubernetesAPIError, errors.Wrap(err, errGetDeployment))
+		}
+		diff := deployment_diff(deployment, live)
+		if len(diff) == 0 {
+			continue
+		}
 
-	_, err = clientset.AppsV1().Deployments("default").Create(ctx, deployment, metav1.CreateOptions{})
+		//a patched template is rolled out honoring the deployment's strategy
+		patch := client.MergeFrom(live.DeepCopy())
+		if live.Annotations == nil {
+			live.Annotations = map[string]string{}
+		}
+		live.Annotations[annotationTemplateHash] = deployment.Annotations[annotationTemplateHash]
+		for _, ref := range deployment.OwnerReferences {
+			meta.AddOwnerReference(live, ref)
+		}
+		live.Spec.Replicas = deployment.Spec.Replicas
+		live.Spec.Template = deployment.Spec.Template
+		if err := c.kube.Patch(ctx, live, patch); err != nil {
+			return managed.ExternalUpdate{}, c.fail(cr, reasonKubernetesAPIError, errors.Wrap(err, errPatchDeployment))
+		}
 
-	if err != nil {
-		c.logger.Debug("Error in creating tflite deployment")
-		c.logger.Debug(err.Error())
+		c.logger.Debug(fmt.Sprintf("Deployment %s patched: %v", deployment.Name, diff))
 	}
 
-	c.logger.Debug("Deployment tflite restarted")
-
 	return managed.ExternalUpdate{
 		// Optionally return any details that may be required to connect to the
 		// external resource. These will be stored as the connection secret.
@@ -438,28 +645,47 @@ func (c *external) Delete(ctx context.Context, mg resource.Managed) error {
 
 	c.logger.Debug(fmt.Sprintf("Deleting: %+v", cr))
 
-	//connect to kubernetes
-	clientset, err := c.connect_kube_client()
-
-	if err != nil {
-		c.logger.Debug("Error in connecting to kubernetes")
-		c.logger.Debug(err.Error())
+	//stop receiving drift events and scheduled runs
+	if c.events != nil {
+		c.events.Unsubscribe(cr.GetName())
 	}
+	c.wakeups.cancel(cr.GetName())
+	c.cursors.forget(cr.GetName())
 
-	//delete deployment drift detection
+	delete_options := client.PropagationPolicy(metav1.DeletePropagationBackground)
 
-	err = clientset.AppsV1().Deployments("default").Delete(ctx, "drift-deploy", metav1.DeleteOptions{})
-	if err != nil {
-		c.logger.Debug("Error in deleting drift deployment")
-		c.logger.Debug(err.Error())
+	//delete drift, inference and candidate deployment
+	for _, name := range []string{drift_deployment_name(cr), tflite_deployment_name(cr), candidate_deployment_name(cr)} {
+		err := c.kube.Delete(ctx, &appsv1.Deployment{ObjectMeta: workload_meta(cr, name)}, delete_options)
+		if err != nil && !kerrors.IsNotFound(err) {
+			return c.fail(cr, reasonKubernetesAPIError, errors.Wrap(err, errDeleteDeployment))
+		}
+	}
+
+	//cancel the jobs of a run in flight and delete the history, together with their pods
+	jobs := &batchv1.JobList{}
+	if err := c.kube.List(ctx, jobs, workload_list_options(cr)...); err != nil {
+		return c.fail(cr, reasonKubernetesAPIError, errors.Wrap(err, errListJobs))
+	}
+	for i := range jobs.Items {
+		err := c.kube.Delete(ctx, &jobs.Items[i], delete_options)
+		if err != nil && !kerrors.IsNotFound(err) {
+			return c.fail(cr, reasonKubernetesAPIError, errors.Wrap(err, errDeleteJob))
+		}
 	}
 
-	//delete deployment inference
+	//delete reader pods left behind by an interrupted read
+	for _, name := range reader_pod_names(cr) {
+		err := c.kube.Delete(ctx, &corev1.Pod{ObjectMeta: workload_meta(cr, name)})
+		if err != nil && !kerrors.IsNotFound(err) {
+			return c.fail(cr, reasonKubernetesAPIError, errors.Wrap(err, errDeletePod))
+		}
+	}
 
-	err = clientset.AppsV1().Deployments("default").Delete(ctx, "python-tflite-deploy", metav1.DeleteOptions{})
-	if err != nil {
-		c.logger.Debug("Error in deleting tflite deployment")
-		c.logger.Debug(err.Error())
+	//delete the copy of the broker credentials
+	err := c.kube.Delete(ctx, &corev1.Secret{ObjectMeta: workload_meta(cr, broker_secret_name(cr))})
+	if err != nil && !kerrors.IsNotFound(err) {
+		return c.fail(cr, reasonKubernetesAPIError, errors.Wrap(err, errDeleteBrokerSecret))
 	}
 
 	return nil
@@ -0,0 +1,271 @@
+package ctrldrift
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/provider-driftprovider/apis/mlops/v1alpha1"
+	"github.com/crossplane/provider-driftprovider/internal/driftsource"
+	"github.com/crossplane/provider-driftprovider/internal/pipeline"
+	"github.com/crossplane/provider-driftprovider/internal/retrain"
+)
+
+// A pipelineRun is what Observe learned about the current run of a CtrlDrift,
+// for the actions of the transition it computed.
+type pipelineRun struct {
+	now       metav1.Time
+	samples   int64
+	decision  retrain.Decision
+	scheduled retrain.ScheduleDecision
+
+	// requested is the retrain request that was not handled yet, and cancel
+	// the cancel request.
+	requested string
+	cancel    string
+
+	training   *batchv1.Job
+	evaluation *batchv1.Job
+	converting *batchv1.Job
+	candidate  *appsv1.Deployment
+
+	// transition is the transition being run. Its phase and message are
+	// changed by an action that has to wait.
+	transition *pipeline.Transition
+
+	// created are the Jobs created by the actions, which join the history.
+	created []batchv1.Job
+
+	// outdated is true once an action requires the deployments to be
+	// updated.
+	outdated bool
+}
+
+// run_actions runs the actions of the transition of the supplied run in
+// order. It stops at the first action that failed, or that has to wait for
+// the next reconcile.
+func (c *external) run_actions(ctx context.Context, cr *v1alpha1.CtrlDrift, r *pipelineRun) error {
+	for _, action := range r.transition.Actions {
+		next, err := c.run_action(ctx, cr, r, action)
+		if err != nil {
+			return err
+		}
+		if !next {
+			return nil
+		}
+	}
+	return nil
+}
+
+// run_action runs an action of the transition of the supplied run. It
+// returns false when the actions that follow have to wait.
+func (c *external) run_action(ctx context.Context, cr *v1alpha1.CtrlDrift, r *pipelineRun, action pipeline.Action) (bool, error) {
+	switch action {
+	case pipeline.ActionStartTraining:
+		return c.start_training(ctx, cr, r)
+	case pipeline.ActionDeleteTraining:
+		return true, c.retire_training(ctx, cr, r)
+	case pipeline.ActionStartEvaluation:
+		return true, c.start_evaluation(ctx, cr, r)
+	case pipeline.ActionDeleteEvaluation:
+		return true, c.retire_evaluation(ctx, cr, r)
+	case pipeline.ActionStartConversion:
+		return true, c.start_conversion(ctx, cr, r)
+	case pipeline.ActionDeleteConversion:
+		return c.retire_conversion(ctx, cr, r)
+	case pipeline.ActionStartCandidate:
+		return true, c.start_candidate(ctx, cr, r)
+	case pipeline.ActionDeleteCandidate:
+		return true, c.delete_candidate(ctx, cr)
+	case pipeline.ActionCancelRun:
+		return true, c.cancel_current_run(ctx, cr, r)
+	case pipeline.ActionRollout:
+		return c.roll_out(ctx, cr, r)
+	}
+	return true, nil
+}
+
+// start_training creates the training Job of the supplied run. A new run
+// gets its own ID and the full retry budget. It waits, in the phase training
+// was requested in, while the training script is being read.
+func (c *external) start_training(ctx context.Context, cr *v1alpha1.CtrlDrift, r *pipelineRun) (bool, error) {
+	c.logger.Debug("Start training job")
+	revision, err := c.resolve_training_script(ctx, cr)
+	if driftsource.IsPending(err) {
+		c.logger.Debug(fmt.Sprintf("Training script not read yet: %s", err))
+		r.transition.Phase = cr.Status.AtProvider.Phase
+		r.transition.Message = "reading the training script"
+		return false, nil
+	}
+	if err != nil {
+		return false, c.fail(cr, reasonTrainingScriptInvalid, err)
+	}
+	//the start time is the version of the model the job writes
+	at := &cr.Status.AtProvider
+	started, run, retries := at.LastTrainingStartTime, at.Run, at.Retries
+	now := r.now
+	at.LastTrainingStartTime = &now
+	if at.Phase != v1alpha1.PhaseTraining {
+		at.Run = model_version(cr)
+		at.Retries = 0
+	}
+	job := c.training_job(cr)
+	if err := c.snapshot_dataset(ctx, cr, job); err != nil {
+		at.LastTrainingStartTime, at.Run, at.Retries = started, run, retries
+		return false, c.fail(cr, reasonKubernetesAPIError, err)
+	}
+	if err := c.kube.Create(ctx, job); err != nil && !kerrors.IsAlreadyExists(err) {
+		at.LastTrainingStartTime, at.Run, at.Retries = started, run, retries
+		return false, c.fail(cr, reasonKubernetesAPIError, errors.Wrap(err, errCreateJob))
+	}
+	at.TrainingScriptRevision = revision
+	at.TrainingSamples = r.samples
+	at.NextRetryTime = nil
+	r.created = append(r.created, *job)
+	if r.scheduled.Due && !r.decision.Retrain {
+		r.transition.Message = "retrain schedule triggered, starting training: " + r.scheduled.Message
+	}
+	if r.requested != "" {
+		c.acknowledge_retrain(cr, r.requested)
+		r.transition.Message = "retrain requested, starting training"
+		r.requested = ""
+	}
+	if r.scheduled.Due && r.scheduled.Reason == retrain.ReasonScheduled {
+		due := metav1.NewTime(r.scheduled.Time)
+		at.LastScheduleTime = &due
+	}
+	return true, nil
+}
+
+// record_training_completion records when the training Job of the supplied
+// run finished.
+func record_training_completion(cr *v1alpha1.CtrlDrift, r *pipelineRun) {
+	if r.training != nil && r.training.Status.CompletionTime != nil {
+		cr.Status.AtProvider.LastTrainingCompletionTime = r.training.Status.CompletionTime
+	}
+}
+
+func (c *external) retire_training(ctx context.Context, cr *v1alpha1.CtrlDrift, r *pipelineRun) error {
+	c.logger.Debug("Delete training job")
+	record_training_completion(cr, r)
+	if err := c.retire_job(ctx, cr, r.training); err != nil {
+		return c.fail(cr, reasonKubernetesAPIError, err)
+	}
+	return nil
+}
+
+func (c *external) start_evaluation(ctx context.Context, cr *v1alpha1.CtrlDrift, r *pipelineRun) error {
+	c.logger.Debug("Start evaluation job")
+	record_training_completion(cr, r)
+	job := c.evaluation_job(cr)
+	if err := c.kube.Create(ctx, job); err != nil && !kerrors.IsAlreadyExists(err) {
+		return c.fail(cr, reasonKubernetesAPIError, errors.Wrap(err, errCreateJob))
+	}
+	r.created = append(r.created, *job)
+	cr.Status.AtProvider.NextRetryTime = nil
+	return nil
+}
+
+func (c *external) retire_evaluation(ctx context.Context, cr *v1alpha1.CtrlDrift, r *pipelineRun) error {
+	c.logger.Debug("Delete evaluation job")
+	if err := c.retire_job(ctx, cr, r.evaluation); err != nil {
+		return c.fail(cr, reasonKubernetesAPIError, err)
+	}
+	return nil
+}
+
+func (c *external) start_conversion(ctx context.Context, cr *v1alpha1.CtrlDrift, r *pipelineRun) error {
+	c.logger.Debug("Start conversion job")
+	record_training_completion(cr, r)
+	job := c.converting_job(cr)
+	if err := c.kube.Create(ctx, job); err != nil && !kerrors.IsAlreadyExists(err) {
+		return c.fail(cr, reasonKubernetesAPIError, errors.Wrap(err, errCreateJob))
+	}
+	r.created = append(r.created, *job)
+	cr.Status.AtProvider.NextRetryTime = nil
+	return nil
+}
+
+// retire_conversion retires the conversion Job of the supplied run. A model
+// that was not rolled out because of a pin is recorded first, a rejected
+// model is never recorded and a failed conversion produced none. It waits
+// while the model is being recorded.
+func (c *external) retire_conversion(ctx context.Context, cr *v1alpha1.CtrlDrift, r *pipelineRun) (bool, error) {
+	if r.transition.Phase != v1alpha1.PhaseRejected && pipeline.JobStateOf(r.converting) == pipeline.JobSucceeded {
+		err := c.record_model_version(ctx, cr)
+		if driftsource.IsPending(err) {
+			c.logger.Debug(fmt.Sprintf("Model %s not recorded yet: %s", model_version(cr), err))
+			return false, nil
+		}
+		if err != nil {
+			return false, c.fail(cr, reasonKubernetesAPIError, err)
+		}
+	}
+	c.logger.Debug("Delete conversion job")
+	if err := c.retire_job(ctx, cr, r.converting); err != nil {
+		return false, c.fail(cr, reasonKubernetesAPIError, err)
+	}
+	return true, nil
+}
+
+func (c *external) start_candidate(ctx context.Context, cr *v1alpha1.CtrlDrift, r *pipelineRun) error {
+	c.logger.Debug("Start candidate deployment")
+	if err := c.kube.Create(ctx, c.candidate_deployment(cr)); err != nil && !kerrors.IsAlreadyExists(err) {
+		return c.fail(cr, reasonKubernetesAPIError, errors.Wrap(err, errCreateDeployment))
+	}
+	start_rollout(cr, r.now)
+	if stable_canary_weight(cr) > 0 {
+		//the inference deployment leaves its share to the canary
+		r.outdated = true
+	}
+	return nil
+}
+
+func (c *external) delete_candidate(ctx context.Context, cr *v1alpha1.CtrlDrift) error {
+	c.logger.Debug("Delete candidate deployment")
+	err := c.kube.Delete(ctx, &appsv1.Deployment{ObjectMeta: workload_meta(cr, candidate_deployment_name(cr))}, client.PropagationPolicy(metav1.DeletePropagationBackground))
+	if err != nil && !kerrors.IsNotFound(err) {
+		return c.fail(cr, reasonKubernetesAPIError, errors.Wrap(err, errDeleteDeployment))
+	}
+	return nil
+}
+
+func (c *external) cancel_current_run(ctx context.Context, cr *v1alpha1.CtrlDrift, r *pipelineRun) error {
+	c.logger.Debug("Cancel run")
+	if err := c.cancel_run(ctx, cr, []*batchv1.Job{r.training, r.evaluation, r.converting}, r.candidate); err != nil {
+		return c.fail(cr, reasonKubernetesAPIError, err)
+	}
+	c.acknowledge_cancel(cr, r.cancel, fmt.Sprintf("run %s cancelled", run_id(cr)))
+	r.outdated = true
+	return nil
+}
+
+// roll_out reloads the drift and inference deployments with the model of the
+// supplied run, once it was recorded. While a model is pinned the model is
+// only recorded, and the deployments are moved to the pinned model instead.
+func (c *external) roll_out(ctx context.Context, cr *v1alpha1.CtrlDrift, r *pipelineRun) (bool, error) {
+	c.logger.Debug("Roll out new model")
+	err := c.record_model_version(ctx, cr)
+	if driftsource.IsPending(err) {
+		c.logger.Debug(fmt.Sprintf("Model %s not recorded yet: %s", model_version(cr), err))
+		r.transition.Message = "recording the new model"
+		return false, nil
+	}
+	if err != nil {
+		return false, c.fail(cr, reasonKubernetesAPIError, err)
+	}
+	if pin := pinned_model_version(cr); pin != "" {
+		c.logger.Debug(fmt.Sprintf("Model %s not promoted, model %s is pinned", model_version(cr), pin))
+		return true, nil
+	}
+	cr.Status.AtProvider.ActiveModelVersion = model_version(cr)
+	cr.Status.AtProvider.ActiveModelScriptRevision = cr.Status.AtProvider.TrainingScriptRevision
+	r.outdated = true
+	return true, nil
+}
@@ -0,0 +1,409 @@
+package ctrldrift
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"path"
+	"strconv"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+
+	"github.com/crossplane/provider-driftprovider/apis/mlops/v1alpha1"
+	apisv1alpha1 "github.com/crossplane/provider-driftprovider/apis/v1alpha1"
+	"github.com/crossplane/provider-driftprovider/internal/driftevents"
+)
+
+const (
+	default_broker_host     = "lserf-tinyml.cloudmmwunibo.it"
+	default_broker_port     = 1883
+	default_broker_tls_port = 8883
+
+	broker_volume_name = "broker"
+	broker_mount_path  = "/etc/driftprovider/broker/"
+
+	broker_username_key = "username"
+	broker_password_key = "password"
+	broker_ca_key       = "ca.crt"
+
+	// annotationBrokerRevision is set on the pod template of the generated
+	// Deployments to the revision of the broker configuration they use, so
+	// that they are restarted when it changes.
+	annotationBrokerRevision = "driftprovider.crossplane.io/broker-revision"
+
+	errGetBrokerSecret    = "cannot get broker Secret %s/%s"
+	errBrokerSecretKey    = "key %q not found in broker Secret %s/%s"
+	errBrokerCA           = "cannot parse broker CA certificates"
+	errBrokerCertificate  = "cannot parse broker client certificate"
+	errGetWorkloadSecret  = "cannot get workload broker Secret"
+	errApplyBrokerSecret  = "cannot apply workload broker Secret"
+	errDeleteBrokerSecret = "cannot delete workload broker Secret"
+)
+
+// A brokerService is the MQTT broker of a ProviderConfig with its credentials
+// resolved. The generated workloads connect to it through a copy of the
+// credentials in their own namespace, the provider connects to it to receive
+// drift events.
+type brokerService struct {
+	host        string
+	port        int32
+	tls         bool
+	server_name string
+	insecure    bool
+
+	username string
+	password []byte
+	ca       []byte
+	cert     []byte
+	key      []byte
+
+	// revision is a digest of the whole configuration.
+	revision string
+}
+
+// new_broker_service resolves the broker configuration of the supplied
+// ProviderConfig. creds are the ProviderConfig's credentials, the password
+// when the broker configures a username but no password. The Secrets the
+// broker references are read through reader, which should be cached as it is
+// called on every reconcile.
+func new_broker_service(ctx context.Context, reader client.Reader, pc *apisv1alpha1.ProviderConfig, creds []byte) (*brokerService, error) {
+	b := &brokerService{host: default_broker_host, port: default_broker_port}
+	cfg := pc.Spec.Broker
+	if cfg == nil {
+		b.revision = broker_revision(b)
+		return b, nil
+	}
+
+	b.host = cfg.Host
+	if cfg.Username != nil {
+		b.username = *cfg.Username
+		b.password = creds
+	}
+	if cfg.PasswordSecretRef != nil {
+		password, err := secret_key(ctx, reader, cfg.PasswordSecretRef.SecretReference, cfg.PasswordSecretRef.Key)
+		if err != nil {
+			return nil, err
+		}
+		b.password = password
+	}
+
+	if t := cfg.TLS; t != nil {
+		b.tls = true
+		b.port = default_broker_tls_port
+		b.server_name = b.host
+		if t.ServerName != nil {
+			b.server_name = *t.ServerName
+		}
+		b.insecure = t.InsecureSkipVerify
+		if t.CASecretRef != nil {
+			ca, err := secret_key(ctx, reader, t.CASecretRef.SecretReference, t.CASecretRef.Key)
+			if err != nil {
+				return nil, err
+			}
+			if !x509.NewCertPool().AppendCertsFromPEM(ca) {
+				return nil, errors.New(errBrokerCA)
+			}
+			b.ca = ca
+		}
+		if ref := t.ClientCertificateSecretRef; ref != nil {
+			cert, err := secret_key(ctx, reader, *ref, corev1.TLSCertKey)
+			if err != nil {
+				return nil, err
+			}
+			key, err := secret_key(ctx, reader, *ref, corev1.TLSPrivateKeyKey)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := tls.X509KeyPair(cert, key); err != nil {
+				return nil, errors.Wrap(err, errBrokerCertificate)
+			}
+			b.cert, b.key = cert, key
+		}
+	}
+	if cfg.Port != nil {
+		b.port = *cfg.Port
+	}
+
+	b.revision = broker_revision(b)
+	return b, nil
+}
+
+func secret_key(ctx context.Context, reader client.Reader, ref xpv1.SecretReference, key string) ([]byte, error) {
+	s := &corev1.Secret{}
+	if err := reader.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, s); err != nil {
+		return nil, errors.Wrapf(err, errGetBrokerSecret, ref.Namespace, ref.Name)
+	}
+	v, ok := s.Data[key]
+	if !ok {
+		return nil, errors.Errorf(errBrokerSecretKey, key, ref.Namespace, ref.Name)
+	}
+	return v, nil
+}
+
+func broker_revision(b *brokerService) string {
+	h := sha256.New()
+	for _, v := range [][]byte{
+		[]byte(b.host), []byte(strconv.Itoa(int(b.port))), []byte(strconv.FormatBool(b.tls)),
+		[]byte(b.server_name), []byte(strconv.FormatBool(b.insecure)),
+		[]byte(b.username), b.password, b.ca, b.cert, b.key,
+	} {
+		fmt.Fprintf(h, "%d:%s", len(v), v)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))[:16]
+}
+
+func (b *brokerService) url() string {
+	scheme := "tcp"
+	if b.tls {
+		scheme = "ssl"
+	}
+	return fmt.Sprintf("%s://%s:%d", scheme, b.host, b.port)
+}
+
+// broker returns the broker the provider receives drift events from.
+func (b *brokerService) broker() (driftevents.Broker, error) {
+	broker := driftevents.Broker{
+		URL:      b.url(),
+		Username: b.username,
+		Password: string(b.password),
+		Revision: b.revision,
+	}
+	if !b.tls {
+		return broker, nil
+	}
+
+	broker.TLS = &tls.Config{
+		ServerName:         b.server_name,
+		InsecureSkipVerify: b.insecure, //nolint:gosec // Explicitly requested by the ProviderConfig.
+		MinVersion:         tls.VersionTLS12,
+	}
+	if len(b.ca) > 0 {
+		broker.TLS.RootCAs = x509.NewCertPool()
+		broker.TLS.RootCAs.AppendCertsFromPEM(b.ca)
+	}
+	if len(b.cert) > 0 {
+		cert, err := tls.X509KeyPair(b.cert, b.key)
+		if err != nil {
+			return driftevents.Broker{}, errors.Wrap(err, errBrokerCertificate)
+		}
+		broker.TLS.Certificates = []tls.Certificate{cert}
+	}
+	return broker, nil
+}
+
+func broker_secret_name(cr *v1alpha1.CtrlDrift) string {
+	return cr.Spec.ForProvider.DeployName + "-broker"
+}
+
+// secret_data is the content of the Secret the workloads read the broker
+// credentials from.
+func (b *brokerService) secret_data() map[string][]byte {
+	data := map[string][]byte{}
+	for key, v := range map[string][]byte{
+		broker_username_key:     []byte(b.username),
+		broker_password_key:     b.password,
+		broker_ca_key:           b.ca,
+		corev1.TLSCertKey:       b.cert,
+		corev1.TLSPrivateKeyKey: b.key,
+	} {
+		if len(v) > 0 {
+			data[key] = v
+		}
+	}
+	return data
+}
+
+// inject connects the first container of the supplied pod to the broker.
+// Variables set explicitly by the workload spec are left alone.
+func (b *brokerService) inject(cr *v1alpha1.CtrlDrift, spec *v1alpha1.WorkloadSpec, pod *corev1.PodSpec) {
+	if len(pod.Containers) == 0 {
+		return
+	}
+	container := &pod.Containers[0]
+	data := b.secret_data()
+	secret_ref := func(key string) *corev1.EnvVarSource {
+		return &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: broker_secret_name(cr)},
+			Key:                  key,
+		}}
+	}
+
+	env := []corev1.EnvVar{
+		{Name: "BROKER_ADDRESS", Value: b.host},
+		{Name: "BROKER_PORT", Value: strconv.Itoa(int(b.port))},
+		{Name: "BROKER_URL", Value: b.url()},
+		{Name: "BROKER_TLS", Value: strconv.FormatBool(b.tls)},
+	}
+	if _, ok := data[broker_username_key]; ok {
+		env = append(env, corev1.EnvVar{Name: "BROKER_USERNAME", ValueFrom: secret_ref(broker_username_key)})
+	}
+	if _, ok := data[broker_password_key]; ok {
+		env = append(env, corev1.EnvVar{Name: "BROKER_PASSWORD", ValueFrom: secret_ref(broker_password_key)})
+	}
+	if b.insecure {
+		env = append(env, corev1.EnvVar{Name: "BROKER_TLS_INSECURE", Value: "true"})
+	}
+
+	files := []corev1.KeyToPath{}
+	for _, f := range []struct{ key, env string }{
+		{broker_ca_key, "BROKER_CA_FILE"},
+		{corev1.TLSCertKey, "BROKER_CERT_FILE"},
+		{corev1.TLSPrivateKeyKey, "BROKER_KEY_FILE"},
+	} {
+		if _, ok := data[f.key]; ok {
+			files = append(files, corev1.KeyToPath{Key: f.key, Path: f.key})
+			env = append(env, corev1.EnvVar{Name: f.env, Value: path.Join(broker_mount_path, f.key)})
+		}
+	}
+	if len(files) > 0 {
+		pod.Volumes = append(pod.Volumes, corev1.Volume{
+			Name: broker_volume_name,
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+				SecretName: broker_secret_name(cr),
+				Items:      files,
+			}},
+		})
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      broker_volume_name,
+			MountPath: broker_mount_path,
+			ReadOnly:  true,
+		})
+	}
+
+	explicit := map[string]bool{}
+	if spec != nil {
+		for _, e := range spec.Env {
+			explicit[e.Name] = true
+		}
+	}
+	for _, e := range env {
+		if !explicit[e.Name] {
+			container.Env = merge_env(container.Env, []corev1.EnvVar{e})
+		}
+	}
+}
+
+// apply_broker_secret copies the broker credentials into the namespace of the
+// workloads of the supplied CtrlDrift, or removes the copy when the broker
+// needs none. The copy is read from the cache of the manager, which already
+// holds the Secrets of the ProviderConfigs, and only written when it changed.
+func (c *external) apply_broker_secret(ctx context.Context, cr *v1alpha1.CtrlDrift) error {
+	data := c.service.secret_data()
+	existing := &corev1.Secret{}
+	err := c.kube.Get(ctx, types.NamespacedName{Namespace: deploy_namespace(cr), Name: broker_secret_name(cr)}, existing)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return errors.Wrap(err, errGetWorkloadSecret)
+	}
+	found := err == nil
+
+	if len(data) == 0 {
+		if found {
+			return errors.Wrap(client.IgnoreNotFound(c.kube.Delete(ctx, existing)), errDeleteBrokerSecret)
+		}
+		return nil
+	}
+
+	desired := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            broker_secret_name(cr),
+			Namespace:       deploy_namespace(cr),
+			Labels:          object_labels(cr, "broker"),
+			OwnerReferences: owner_references(cr),
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	}
+	if !found {
+		//the cache may not hold the copy created by the previous reconcile yet
+		if err := c.kube.Create(ctx, desired); err != nil && !kerrors.IsAlreadyExists(err) {
+			return errors.Wrap(err, errApplyBrokerSecret)
+		}
+		return nil
+	}
+	if secret_data_equal(existing.Data, data) && has_owner_references(desired, existing) {
+		return nil
+	}
+	existing.Data = data
+	meta.AddOwnerReference(existing, owner_reference(cr))
+	return errors.Wrap(c.kube.Update(ctx, existing), errApplyBrokerSecret)
+}
+
+func secret_data_equal(a, b map[string][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || string(v) != string(w) {
+			return false
+		}
+	}
+	return true
+}
+
+// deployments returns the Deployments of the supplied CtrlDrift, connected to
+// the broker and annotated with the digest of their pod template.
+func (c *external) deployments(cr *v1alpha1.CtrlDrift) []*appsv1.Deployment {
+	deployments := []*appsv1.Deployment{get_drift_detection_deployment(cr), get_tflite_deployment(cr)}
+	for i, spec := range []*v1alpha1.WorkloadSpec{cr.Spec.ForProvider.Detector, cr.Spec.ForProvider.Inference} {
+		template := &deployments[i].Spec.Template
+		if c.service != nil {
+			c.service.inject(cr, spec, &template.Spec)
+			template.Annotations[annotationBrokerRevision] = c.service.revision
+		}
+		deployments[i].Annotations = map[string]string{annotationTemplateHash: template_hash(template)}
+	}
+	return deployments
+}
+
+// training_job returns the training Job of the supplied CtrlDrift, connected
+// to the broker.
+func (c *external) training_job(cr *v1alpha1.CtrlDrift) *batchv1.Job {
+	job := get_training_job(cr)
+	if c.service != nil {
+		c.service.inject(cr, cr.Spec.ForProvider.Training, &job.Spec.Template.Spec)
+	}
+	return job
+}
+
+// converting_job returns the conversion Job of the supplied CtrlDrift,
+// connected to the broker.
+func (c *external) converting_job(cr *v1alpha1.CtrlDrift) *batchv1.Job {
+	job := get_converting_job(cr)
+	if c.service != nil {
+		c.service.inject(cr, cr.Spec.ForProvider.Conversion, &job.Spec.Template.Spec)
+	}
+	return job
+}
+
+// evaluation_job returns the evaluation Job of the supplied CtrlDrift,
+// connected to the broker.
+func (c *external) evaluation_job(cr *v1alpha1.CtrlDrift) *batchv1.Job {
+	job := get_evaluation_job(cr)
+	if c.service != nil {
+		c.service.inject(cr, evaluation_spec(cr), &job.Spec.Template.Spec)
+	}
+	return job
+}
+
+// candidate_deployment returns the candidate Deployment of a Canary or Shadow
+// rollout of the supplied CtrlDrift, connected to the broker.
+func (c *external) candidate_deployment(cr *v1alpha1.CtrlDrift) *appsv1.Deployment {
+	d := get_candidate_deployment(cr)
+	if c.service != nil {
+		c.service.inject(cr, cr.Spec.ForProvider.Inference, &d.Spec.Template.Spec)
+		d.Spec.Template.Annotations[annotationBrokerRevision] = c.service.revision
+	}
+	return d
+}
@@ -0,0 +1,170 @@
+package ctrldrift
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	"github.com/crossplane/provider-driftprovider/apis/mlops/v1alpha1"
+)
+
+const (
+	errDeletePod       = "cannot delete reader pod"
+	errReleaseWorkload = "cannot release workload %s/%s"
+)
+
+// owner_reference makes the CtrlDrift the owner of a generated workload, so
+// that the garbage collector removes whatever Delete missed.
+func owner_reference(cr *v1alpha1.CtrlDrift) metav1.OwnerReference {
+	return meta.AsOwner(meta.TypedReferenceTo(cr, v1alpha1.CtrlDriftGroupVersionKind))
+}
+
+func owner_references(cr *v1alpha1.CtrlDrift) []metav1.OwnerReference {
+	return []metav1.OwnerReference{owner_reference(cr)}
+}
+
+// has_owner_references returns true when the live object has every owner
+// reference of the desired one.
+func has_owner_references(desired, live metav1.Object) bool {
+	for _, want := range desired.GetOwnerReferences() {
+		found := false
+		for _, got := range live.GetOwnerReferences() {
+			if got.UID == want.UID {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// release removes the owner reference to the supplied CtrlDrift from an
+// object. It returns false when the object had none.
+func release(cr *v1alpha1.CtrlDrift, obj metav1.Object) bool {
+	refs := obj.GetOwnerReferences()
+	kept := make([]metav1.OwnerReference, 0, len(refs))
+	for _, ref := range refs {
+		if ref.UID != cr.GetUID() {
+			kept = append(kept, ref)
+		}
+	}
+	obj.SetOwnerReferences(kept)
+	return len(kept) != len(refs)
+}
+
+func reader_pod_names(cr *v1alpha1.CtrlDrift) []string {
+	return []string{
+		drift_reader_pod_name(cr),
+		cr.Spec.ForProvider.DeployName + "-model-reader",
+		cr.Spec.ForProvider.DeployName + "-evaluation-reader",
+		cr.Spec.ForProvider.DeployName + "-rollout-reader",
+		cr.Spec.ForProvider.DeployName + "-dataset-reader",
+		cr.Spec.ForProvider.DeployName + "-script-reader",
+	}
+}
+
+// release_workloads removes the owner references to the supplied CtrlDrift
+// from all its workloads, so that they keep running once it is gone.
+func release_workloads(ctx context.Context, kube client.Client, cr *v1alpha1.CtrlDrift) error {
+	deployments := &appsv1.DeploymentList{}
+	if err := kube.List(ctx, deployments, workload_list_options(cr)...); err != nil {
+		return errors.Wrap(err, errListDeployments)
+	}
+	jobs := &batchv1.JobList{}
+	if err := kube.List(ctx, jobs, workload_list_options(cr)...); err != nil {
+		return errors.Wrap(err, errListJobs)
+	}
+	objs := []client.Object{}
+	for i := range deployments.Items {
+		objs = append(objs, &deployments.Items[i])
+	}
+	for i := range jobs.Items {
+		objs = append(objs, &jobs.Items[i])
+	}
+	secret := &corev1.Secret{}
+	err := kube.Get(ctx, types.NamespacedName{Namespace: deploy_namespace(cr), Name: broker_secret_name(cr)}, secret)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return errors.Wrap(err, errGetWorkloadSecret)
+	}
+	if err == nil {
+		objs = append(objs, secret)
+	}
+
+	for _, obj := range objs {
+		patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+		if !release(cr, obj) {
+			continue
+		}
+		if err := kube.Patch(ctx, obj, patch); client.IgnoreNotFound(err) != nil {
+			return errors.Wrapf(err, errReleaseWorkload, obj.GetNamespace(), obj.GetName())
+		}
+	}
+	return nil
+}
+
+// An orphaningFinalizer releases the workloads of a CtrlDrift with the Orphan
+// deletion policy before its finalizer is removed. The managed reconciler
+// neither observes nor deletes such a CtrlDrift, and the garbage collector
+// would otherwise remove its workloads.
+type orphaningFinalizer struct {
+	resource.Finalizer
+	kube   client.Client
+	logger logging.Logger
+}
+
+func (f *orphaningFinalizer) RemoveFinalizer(ctx context.Context, obj resource.Object) error {
+	if cr, ok := obj.(*v1alpha1.CtrlDrift); ok && meta.WasDeleted(cr) && cr.GetDeletionPolicy() == xpv1.DeletionOrphan {
+		if err := release_workloads(ctx, f.kube, cr); err != nil {
+			return err
+		}
+		f.logger.Debug(fmt.Sprintf("Workloads of %s orphaned", cr.GetName()))
+	}
+	return f.Finalizer.RemoveFinalizer(ctx, obj)
+}
+
+// observe_deletion reports a CtrlDrift that is being deleted as existing until
+// all its Deployments and Jobs are gone.
+func (c *external) observe_deletion(ctx context.Context, cr *v1alpha1.CtrlDrift) (managed.ExternalObservation, error) {
+	deployments := &appsv1.DeploymentList{}
+	if err := c.kube.List(ctx, deployments, workload_list_options(cr)...); err != nil {
+		return managed.ExternalObservation{}, c.fail(cr, reasonKubernetesAPIError, errors.Wrap(err, errListDeployments))
+	}
+	jobs := &batchv1.JobList{}
+	if err := c.kube.List(ctx, jobs, workload_list_options(cr)...); err != nil {
+		return managed.ExternalObservation{}, c.fail(cr, reasonKubernetesAPIError, errors.Wrap(err, errListJobs))
+	}
+
+	cr.Status.AtProvider.Deployments = nil
+	for _, d := range deployments.Items {
+		cr.Status.AtProvider.Deployments = append(cr.Status.AtProvider.Deployments, d.Name)
+	}
+	cr.Status.AtProvider.Jobs = nil
+	for _, j := range jobs.Items {
+		cr.Status.AtProvider.Jobs = append(cr.Status.AtProvider.Jobs, j.Name)
+	}
+	cr.Status.AtProvider.Runs = run_history(jobs.Items)
+	remaining := len(deployments.Items) + len(jobs.Items)
+	cr.Status.AtProvider.Message = fmt.Sprintf("deleting, %d workloads remaining", remaining)
+
+	return managed.ExternalObservation{
+		ResourceExists:    remaining > 0,
+		ResourceUpToDate:  true,
+		ConnectionDetails: managed.ConnectionDetails{},
+	}, nil
+}
@@ -0,0 +1,83 @@
+package ctrldrift
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+
+	"github.com/crossplane/provider-driftprovider/apis/mlops/v1alpha1"
+)
+
+// Reasons of the Ready condition of a CtrlDrift that is not available. They
+// are also used as the reason of the warning event emitted for the failure.
+const (
+	reasonKubernetesAPIError    xpv1.ConditionReason = "KubernetesAPIError"
+	reasonForbidden             xpv1.ConditionReason = "Forbidden"
+	reasonVolumeMissing         xpv1.ConditionReason = "VolumeMissing"
+	reasonDriftDataUnreadable   xpv1.ConditionReason = "DriftDataUnreadable"
+	reasonInvalidRetrainPolicy  xpv1.ConditionReason = "InvalidRetrainPolicy"
+	reasonTrainingScriptInvalid xpv1.ConditionReason = "TrainingScriptInvalid"
+	reasonModelVersionMissing   xpv1.ConditionReason = "ModelVersionMissing"
+	reasonEvaluationInvalid     xpv1.ConditionReason = "EvaluationInvalid"
+	reasonPipelineFailed        xpv1.ConditionReason = "PipelineFailed"
+	reasonWorkloadsUnavailable  xpv1.ConditionReason = "WorkloadsUnavailable"
+)
+
+// Reason of the normal event emitted when the pipeline changes phase.
+const reasonPhaseChanged event.Reason = "PhaseChanged"
+
+// unavailable returns a Ready condition that is false for the supplied reason.
+func unavailable(reason xpv1.ConditionReason, message string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               xpv1.TypeReady,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	}
+}
+
+// fail records err on the Ready condition of the supplied CtrlDrift and emits
+// a warning event. Errors returned by the API server are classified so that
+// RBAC problems are easy to tell apart. It returns err, which the caller
+// should return so that the Synced condition reports it too.
+func (c *external) fail(cr *v1alpha1.CtrlDrift, reason xpv1.ConditionReason, err error) error {
+	if kerrors.IsForbidden(errors.Cause(err)) || kerrors.IsUnauthorized(errors.Cause(err)) {
+		reason = reasonForbidden
+	}
+	cr.SetConditions(unavailable(reason, err.Error()))
+	c.recorder.Event(cr, event.Warning(event.Reason(reason), err))
+	return err
+}
+
+// ready_condition derives the Ready condition of a CtrlDrift from its
+// Deployments and from the phase of its pipeline.
+func ready_condition(cr *v1alpha1.CtrlDrift, deployments []appsv1.Deployment) xpv1.Condition {
+	if cr.Status.AtProvider.Phase == v1alpha1.PhaseFailed {
+		return unavailable(reasonPipelineFailed, cr.Status.AtProvider.Message)
+	}
+
+	waiting := []string{}
+	for _, name := range []string{drift_deployment_name(cr), tflite_deployment_name(cr)} {
+		available := false
+		for _, deployment := range deployments {
+			if deployment.Name == name && deployment.Status.AvailableReplicas > 0 {
+				available = true
+			}
+		}
+		if !available {
+			waiting = append(waiting, name)
+		}
+	}
+	if len(waiting) > 0 {
+		return unavailable(reasonWorkloadsUnavailable, fmt.Sprintf("waiting for deployments %v to become available", waiting))
+	}
+	return xpv1.Available()
+}
@@ -0,0 +1,110 @@
+package ctrldrift
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+
+	"github.com/crossplane/provider-driftprovider/apis/mlops/v1alpha1"
+)
+
+// Reasons of the events emitted when a control annotation is handled.
+const (
+	reasonRetrainRequested event.Reason = "RetrainRequested"
+	reasonPipelinePaused   event.Reason = "PipelinePaused"
+	reasonPipelineResumed  event.Reason = "PipelineResumed"
+	reasonRunCancelled     event.Reason = "RunCancelled"
+)
+
+const errCancelRun = "cannot cancel run"
+
+// paused returns true while the pipeline is paused by the paused annotation.
+func paused(cr *v1alpha1.CtrlDrift) bool {
+	return cr.GetAnnotations()[v1alpha1.AnnotationPaused] == "true"
+}
+
+// pending_request returns the value of a request annotation that was not
+// handled yet, or "".
+func pending_request(cr *v1alpha1.CtrlDrift, annotation string, handled string) string {
+	if v := cr.GetAnnotations()[annotation]; v != handled {
+		return v
+	}
+	return ""
+}
+
+func retrain_requested(cr *v1alpha1.CtrlDrift) string {
+	return pending_request(cr, v1alpha1.AnnotationRetrainRequested, cr.Status.AtProvider.LastRetrainRequest)
+}
+
+func cancel_requested(cr *v1alpha1.CtrlDrift) string {
+	return pending_request(cr, v1alpha1.AnnotationCancelRun, cr.Status.AtProvider.LastCancelRequest)
+}
+
+// observe_pause records whether the pipeline is paused and announces pausing
+// and resuming it.
+func (c *external) observe_pause(cr *v1alpha1.CtrlDrift) {
+	p := paused(cr)
+	if p == cr.Status.AtProvider.Paused {
+		return
+	}
+	cr.Status.AtProvider.Paused = p
+	if p {
+		c.recorder.Event(cr, event.Normal(reasonPipelinePaused, "pipeline paused, no job is started and no model is rolled out"))
+		return
+	}
+	c.recorder.Event(cr, event.Normal(reasonPipelineResumed, "pipeline resumed"))
+}
+
+// acknowledge_retrain records that the pending retrain request started a run.
+func (c *external) acknowledge_retrain(cr *v1alpha1.CtrlDrift, request string) {
+	cr.Status.AtProvider.LastRetrainRequest = request
+	c.recorder.Event(cr, event.Normal(reasonRetrainRequested, fmt.Sprintf("retrain %q requested, starting training", request)))
+}
+
+// acknowledge_cancel records that the pending cancel request was handled.
+func (c *external) acknowledge_cancel(cr *v1alpha1.CtrlDrift, request string, message string) {
+	cr.Status.AtProvider.LastCancelRequest = request
+	c.recorder.Event(cr, event.Normal(reasonRunCancelled, fmt.Sprintf("cancel %q: %s", request, message)))
+}
+
+// cancel_run deletes the Jobs of the run in flight together with their pods,
+// and its candidate Deployment. The Jobs are retired first, so that a stale
+// read of them does not resume the run.
+func (c *external) cancel_run(ctx context.Context, cr *v1alpha1.CtrlDrift, jobs []*batchv1.Job, candidate *appsv1.Deployment) error {
+	delete_options := client.PropagationPolicy(metav1.DeletePropagationBackground)
+	for _, job := range jobs {
+		if job == nil {
+			continue
+		}
+		if err := c.retire_job(ctx, cr, job); err != nil {
+			return errors.Wrap(err, errCancelRun)
+		}
+		c.logger.Debug(fmt.Sprintf("Delete job %s of the cancelled run", job.Name))
+		if err := c.kube.Delete(ctx, job, delete_options); client.IgnoreNotFound(err) != nil {
+			return errors.Wrap(errors.Wrap(err, errDeleteJob), errCancelRun)
+		}
+	}
+	if candidate != nil {
+		if err := c.kube.Delete(ctx, candidate, delete_options); client.IgnoreNotFound(err) != nil {
+			return errors.Wrap(errors.Wrap(err, errDeleteDeployment), errCancelRun)
+		}
+	}
+
+	//a baking candidate is rolled back, so the inference deployment serves all input again
+	if r := baking_rollout(cr); r != nil {
+		now := metav1.Now()
+		r.Outcome = v1alpha1.RolloutRolledBack
+		r.Message = "run cancelled"
+		r.CompletionTime = &now
+	}
+	cr.Status.AtProvider.Retries = 0
+	cr.Status.AtProvider.NextRetryTime = nil
+	return nil
+}
@@ -0,0 +1,302 @@
+package ctrldrift
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+
+	"github.com/crossplane/provider-driftprovider/apis/mlops/v1alpha1"
+	"github.com/crossplane/provider-driftprovider/internal/driftsource"
+	"github.com/crossplane/provider-driftprovider/internal/pipeline"
+)
+
+const (
+	// datasets_dir holds one directory per CtrlDrift on the data volume,
+	// which may be shared, with one directory per run with the snapshot of
+	// the drift dataset the run trained on.
+	datasets_dir = "datasets"
+
+	// dataset_manifest_name is written next to a snapshot once it is
+	// complete.
+	dataset_manifest_name = "manifest.json"
+
+	default_snapshot_limit int32 = 5
+
+	errReadDatasetManifest  = "cannot read dataset snapshot manifest"
+	errParseDatasetManifest = "cannot parse dataset snapshot manifest"
+)
+
+// Reasons of the events emitted when the snapshot of a run is recorded.
+const (
+	reasonDatasetSnapshotted event.Reason = "DatasetSnapshotted"
+	reasonDatasetUnreadable  event.Reason = "DatasetUnreadable"
+)
+
+// snapshot_script copies the drift dataset into the snapshot directory of the
+// run, unless an earlier attempt of the run already did, and deletes the
+// snapshots that are not kept. A snapshot is complete once its manifest was
+// written. A drift dataset that does not exist, e.g. when a scheduled or
+// requested run starts without drift, is snapshotted as an empty file instead
+// of failing the training Job.
+const snapshot_script = `set -e
+cd "$DATA_DIR"
+mkdir -p "$DATASETS_DIR"
+for d in "$DATASETS_DIR"/*/; do
+  test -d "$d" || continue
+  case " $KEEP " in *" $(basename "$d") "*) ;; *) chmod -R u+w "$d"; rm -rf "$d" ;; esac
+done
+dir="$DATASETS_DIR/$SNAPSHOT"
+test -f "$dir/` + dataset_manifest_name + `" && exit 0
+mkdir -p "$dir"
+if test -f "$DATA_FILE"; then cp "$DATA_FILE" "$dir/.partial"; else : > "$dir/.partial"; fi
+mv "$dir/.partial" "$dir/$(basename "$DATA_FILE")"
+f="$dir/$(basename "$DATA_FILE")"
+printf '{"sha256":"%s","lines":%s,"records":%s,"bytes":%s}\n' "$(sha256sum "$f" | cut -d' ' -f1)" "$(wc -l < "$f" | tr -d ' ')" "$(grep -c '[^[:space:]]' "$f" || true)" "$(wc -c < "$f" | tr -d ' ')" > "$dir/` + dataset_manifest_name + `"
+chmod -R a-w "$dir"
+`
+
+// dataset_manifest_script prints the manifest of the snapshot in the
+// directory "$0".
+const dataset_manifest_script = `test -f "$0/` + dataset_manifest_name + `" || exit 3; cat "$0/` + dataset_manifest_name + `"`
+
+// snapshots_dir is the directory of the snapshots of the supplied CtrlDrift,
+// relative to the data volume. Only the training Jobs of the CtrlDrift delete
+// snapshots in it.
+func snapshots_dir(cr *v1alpha1.CtrlDrift) string {
+	return path.Join(datasets_dir, cr.Spec.ForProvider.DeployName)
+}
+
+// snapshot_dir is the directory of the snapshot of the supplied run, relative
+// to the data volume.
+func snapshot_dir(cr *v1alpha1.CtrlDrift, run string) string {
+	return path.Join(snapshots_dir(cr), run)
+}
+
+func snapshot_path(cr *v1alpha1.CtrlDrift, run string) string {
+	return path.Join(snapshot_dir(cr, run), path.Base(drift_file(cr)))
+}
+
+// snapshot_run returns the run of a snapshot path of the supplied CtrlDrift,
+// or "" for a path that is not one.
+func snapshot_run(cr *v1alpha1.CtrlDrift, p string) string {
+	dir := path.Dir(p)
+	if path.Dir(dir) != snapshots_dir(cr) {
+		return ""
+	}
+	return path.Base(dir)
+}
+
+func snapshot_limit(cr *v1alpha1.CtrlDrift) int {
+	if p := cr.Spec.ForProvider.DatasetSnapshots; p != nil && p.Limit != nil {
+		return int(*p.Limit)
+	}
+	return int(default_snapshot_limit)
+}
+
+func keep_recorded_models(cr *v1alpha1.CtrlDrift) bool {
+	if p := cr.Spec.ForProvider.DatasetSnapshots; p != nil && p.KeepRecordedModels != nil {
+		return *p.KeepRecordedModels
+	}
+	return false
+}
+
+// dataset_snapshot returns the recorded snapshot of the supplied run, or nil.
+func dataset_snapshot(cr *v1alpha1.CtrlDrift, run string) *v1alpha1.DatasetSnapshot {
+	for i := range cr.Status.AtProvider.Datasets {
+		if s := &cr.Status.AtProvider.Datasets[i]; s.Run == run {
+			return s
+		}
+	}
+	return nil
+}
+
+// get_snapshot_container snapshots the drift dataset for the current run
+// before the training container consumes it, and deletes the snapshots of
+// the runs that are not kept.
+func get_snapshot_container(cr *v1alpha1.CtrlDrift, spec *v1alpha1.WorkloadSpec, keep []string) corev1.Container {
+	return corev1.Container{
+		Name:    "dataset-snapshot",
+		Image:   default_reader_image,
+		Command: []string{"sh", "-c", snapshot_script},
+		Env: []corev1.EnvVar{
+			{Name: "DATA_DIR", Value: data_mount_path(spec)},
+			{Name: "DATASETS_DIR", Value: snapshots_dir(cr)},
+			{Name: "DATA_FILE", Value: drift_file(cr)},
+			{Name: "SNAPSHOT", Value: run_id(cr)},
+			{Name: "KEEP", Value: strings.Join(keep, " ")},
+		},
+		VolumeMounts: []corev1.VolumeMount{data_volume_mount(spec)},
+	}
+}
+
+// retained_snapshots returns the runs whose snapshots are kept: the current
+// run, the most recent recorded runs up to the limit and, when enabled, the
+// runs of every recorded model.
+func (c *external) retained_snapshots(ctx context.Context, cr *v1alpha1.CtrlDrift) ([]string, error) {
+	keep := map[string]bool{run_id(cr): true}
+	for _, s := range cr.Status.AtProvider.Datasets {
+		if len(keep) >= snapshot_limit(cr) {
+			break
+		}
+		keep[s.Run] = true
+	}
+	if keep_recorded_models(cr) {
+		mvs := &v1alpha1.ModelVersionList{}
+		if err := c.kube.List(ctx, mvs, client.MatchingLabels{labelCtrlDrift: cr.GetName()}); err != nil {
+			return nil, errors.Wrap(err, errListModelVersions)
+		}
+		for _, mv := range mvs.Items {
+			if d := mv.Spec.ForProvider.Dataset; mv.Spec.ForProvider.CtrlDrift == cr.GetName() && d != nil {
+				if run := snapshot_run(cr, d.Path); run != "" {
+					keep[run] = true
+				}
+			}
+		}
+	}
+	runs := make([]string, 0, len(keep))
+	for run := range keep {
+		runs = append(runs, run)
+	}
+	sort.Strings(runs)
+	return runs, nil
+}
+
+// snapshot_dataset adds the container taking the snapshot of the drift
+// dataset to a training Job.
+func (c *external) snapshot_dataset(ctx context.Context, cr *v1alpha1.CtrlDrift, job *batchv1.Job) error {
+	keep, err := c.retained_snapshots(ctx, cr)
+	if err != nil {
+		return err
+	}
+	pod := &job.Spec.Template.Spec
+	pod.InitContainers = append([]corev1.Container{get_snapshot_container(cr, cr.Spec.ForProvider.Training, keep)}, pod.InitContainers...)
+	return nil
+}
+
+// A datasetManifest is written by snapshot_script.
+type datasetManifest struct {
+	SHA256 string `json:"sha256"`
+	Lines  int64  `json:"lines"`
+	Bytes  int64  `json:"bytes"`
+
+	// Records is the number of lines that are not blank, a last line
+	// without a newline included. Manifests of earlier snapshots lack it.
+	Records *int64 `json:"records,omitempty"`
+}
+
+// dataset_rows returns the number of samples of a snapshot: its records, the
+// header of a CSV file excluded. The rows of a Parquet file are not counted.
+func dataset_rows(cr *v1alpha1.CtrlDrift, m datasetManifest) int64 {
+	records := m.Lines
+	if m.Records != nil {
+		records = *m.Records
+	}
+	switch drift_format(cr) {
+	case driftsource.FormatParquet:
+		return 0
+	case driftsource.FormatCSV:
+		if drift_header(cr) && records > 0 {
+			records--
+		}
+	case driftsource.FormatJSONLines:
+	}
+	return records
+}
+
+func parse_dataset_manifest(r io.Reader) (datasetManifest, error) {
+	m := datasetManifest{}
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return datasetManifest{}, errors.Wrap(err, errParseDatasetManifest)
+	}
+	return m, nil
+}
+
+// read_dataset_manifest reads the manifest of the snapshot of the supplied run
+// from the data volume of the training Job, the way read_model_manifest reads
+// the manifest of a model.
+func (c *external) read_dataset_manifest(ctx context.Context, cr *v1alpha1.CtrlDrift, run string) (datasetManifest, error) {
+	var f io.ReadCloser
+	var err error
+	if src := cr.Spec.ForProvider.DriftSource; src == nil || src.Type == v1alpha1.DriftSourceLocal {
+		reader := &driftsource.LocalReader{Dir: local_drift_path(cr)}
+		f, err = reader.Open(ctx, path.Join(snapshot_dir(cr, run), dataset_manifest_name))
+	} else {
+		spec := cr.Spec.ForProvider.Training
+		reader := &driftsource.PodReader{
+			Client:    c.clientset,
+			Pod:       get_reader_pod(cr, cr.Spec.ForProvider.DeployName+"-dataset-reader", "dataset-reader", spec),
+			MountPath: data_mount_path(spec),
+			Script:    dataset_manifest_script,
+		}
+		f, err = reader.Open(ctx, snapshot_dir(cr, run))
+	}
+	if err != nil {
+		return datasetManifest{}, err
+	}
+	defer f.Close() //nolint:errcheck // Only read from.
+	return parse_dataset_manifest(f)
+}
+
+// trained returns true when a training Job of the supplied run succeeded,
+// whether or not it was retired since.
+func trained(jobs []batchv1.Job, run string) bool {
+	for i := range jobs {
+		job := &jobs[i]
+		if job.Labels[labelStep] == step_training && job.Labels[labelRun] == run && pipeline.JobStateOf(job) == pipeline.JobSucceeded {
+			return true
+		}
+	}
+	return false
+}
+
+// record_dataset_snapshot records the snapshot of the dataset the supplied
+// run trained on in status.atProvider.datasets, newest first. It returns a
+// pending error while the manifest of the snapshot is being read, and an
+// error when the reader pod cannot be run. A snapshot whose manifest is
+// missing or broken is recorded without its checksum and size.
+func (c *external) record_dataset_snapshot(ctx context.Context, cr *v1alpha1.CtrlDrift, run string, now metav1.Time) error {
+	if dataset_snapshot(cr, run) != nil {
+		return nil
+	}
+	m, err := c.read_dataset_manifest(ctx, cr, run)
+	if _, api := errors.Cause(err).(kerrors.APIStatus); api || driftsource.IsPending(err) {
+		return errors.Wrap(err, errReadDatasetManifest)
+	}
+	if err != nil {
+		c.recorder.Event(cr, event.Warning(reasonDatasetUnreadable, errors.Wrap(err, errReadDatasetManifest)))
+	}
+
+	snapshot := v1alpha1.DatasetSnapshot{
+		Run:   run,
+		Path:  snapshot_path(cr, run),
+		Rows:  dataset_rows(cr, m),
+		Bytes: m.Bytes,
+		Time:  &now,
+	}
+	if m.SHA256 != "" {
+		snapshot.Checksum = "sha256:" + m.SHA256
+	}
+	datasets := append([]v1alpha1.DatasetSnapshot{snapshot}, cr.Status.AtProvider.Datasets...)
+	if len(datasets) > snapshot_limit(cr) {
+		datasets = datasets[:snapshot_limit(cr)]
+	}
+	cr.Status.AtProvider.Datasets = datasets
+	if err != nil {
+		return nil
+	}
+	c.recorder.Event(cr, event.Normal(reasonDatasetSnapshotted, fmt.Sprintf("dataset of run %s snapshotted to %s: %d rows, %d bytes, %s", run, snapshot.Path, snapshot.Rows, snapshot.Bytes, snapshot.Checksum)))
+	return nil
+}
@@ -1,23 +1,162 @@
 package ctrldrift
 
 import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/provider-driftprovider/apis/mlops/v1alpha1"
+	"github.com/crossplane/provider-driftprovider/internal/metrics"
+)
+
+const (
+	// labelDeployName is set on every workload generated for a CtrlDrift so
+	// that the selectors of two pipelines sharing a namespace never overlap.
+	labelDeployName = "driftprovider.crossplane.io/deploy-name"
+
+	// labelCtrlDrift is set on every workload generated for a CtrlDrift to the
+	// name of that CtrlDrift, so that changes of a workload can be mapped back
+	// to the CtrlDrift that owns it. It is not part of any selector.
+	labelCtrlDrift = "driftprovider.crossplane.io/ctrldrift"
+
+	// annotationModelVersion is set on the pod template of the generated
+	// Deployments to the model version they serve.
+	annotationModelVersion = "driftprovider.crossplane.io/model-version"
+
+	// annotationTemplateHash is set on the generated Deployments to a digest
+	// of the pod template they were last created or patched with, so that
+	// Update only touches the Deployments whose template changed.
+	annotationTemplateHash = "driftprovider.crossplane.io/template-hash"
+)
+
+// Defaults of the generated workloads, used for every field a WorkloadSpec
+// leaves unset.
+const (
+	default_detector_image   = "lucaserf/drift_detection"
+	default_inference_image  = "lucaserf/python_tflite"
+	default_training_image   = "lucaserf/training-regression"
+	default_conversion_image = "lucaserf/converting-lite"
+	default_image_tag        = "latest"
+
+	data_volume_name   = "data-volume"
+	default_claim_name = "data-pvc"
+	default_mount_path = "/var/data/"
 )
 
 func int32Ptr(i int32) *int32 {
 	return &i
 }
 
-func get_converting_job() *batchv1.Job {
+// Names of the workloads generated for a CtrlDrift. They are all derived from
+// spec.forProvider.deploy_name so that several pipelines can live side by side.
+func drift_deployment_name(cr *v1alpha1.CtrlDrift) string {
+	return cr.Spec.ForProvider.DeployName + "-drift-deploy"
+}
+
+func tflite_deployment_name(cr *v1alpha1.CtrlDrift) string {
+	return cr.Spec.ForProvider.DeployName + "-python-tflite-deploy"
+}
+
+func training_job_name(cr *v1alpha1.CtrlDrift) string {
+	return run_job_name(cr, step_training)
+}
+
+func converting_job_name(cr *v1alpha1.CtrlDrift) string {
+	return run_job_name(cr, step_conversion)
+}
+
+// model_version identifies the model produced by the last training run.
+func model_version(cr *v1alpha1.CtrlDrift) string {
+	if cr.Status.AtProvider.LastTrainingStartTime == nil {
+		return ""
+	}
+	return cr.Status.AtProvider.LastTrainingStartTime.UTC().Format("20060102-150405")
+}
+
+func model_annotations(cr *v1alpha1.CtrlDrift) map[string]string {
+	return map[string]string{
+		annotationModelVersion: cr.Status.AtProvider.ActiveModelVersion,
+	}
+}
+
+// template_hash returns a digest of a pod template.
+func template_hash(template *corev1.PodTemplateSpec) string {
+	raw, err := json.Marshal(template)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(raw))[:16]
+}
+
+// deploy_namespace returns the namespace all workloads of a CtrlDrift live in.
+func deploy_namespace(cr *v1alpha1.CtrlDrift) string {
+	return cr.Spec.ForProvider.DeployNamespace
+}
+
+// CacheByObject restricts the manager's cache to the Deployments and Jobs
+// generated for a CtrlDrift, so that the provider does not have to cache every
+// workload of the cluster to serve its label scoped reads.
+func CacheByObject() map[client.Object]cache.ByObject {
+	generated := labels.NewSelector()
+	if r, err := labels.NewRequirement(labelDeployName, selection.Exists, nil); err == nil {
+		generated = generated.Add(*r)
+	}
+	return map[client.Object]cache.ByObject{
+		&appsv1.Deployment{}: {Label: generated},
+		&batchv1.Job{}:       {Label: generated},
+	}
+}
+
+// workload_list_options selects only the workloads generated for the supplied
+// CtrlDrift, leaving other pipelines in the same namespace alone.
+func workload_list_options(cr *v1alpha1.CtrlDrift) []client.ListOption {
+	return []client.ListOption{
+		client.InNamespace(deploy_namespace(cr)),
+		client.MatchingLabels{labelDeployName: cr.Spec.ForProvider.DeployName},
+	}
+}
+
+// workload_meta identifies a workload of the supplied CtrlDrift by name.
+func workload_meta(cr *v1alpha1.CtrlDrift, name string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{Name: name, Namespace: deploy_namespace(cr)}
+}
+
+func workload_labels(cr *v1alpha1.CtrlDrift, app string) map[string]string {
+	return map[string]string{
+		"app":           app,
+		labelDeployName: cr.Spec.ForProvider.DeployName,
+	}
+}
+
+// object_labels are the labels of a generated workload itself. Unlike
+// workload_labels they are never used to select pods.
+func object_labels(cr *v1alpha1.CtrlDrift, app string) map[string]string {
+	l := workload_labels(cr, app)
+	l[labelCtrlDrift] = cr.GetName()
+	return l
+}
+
+func get_converting_job(cr *v1alpha1.CtrlDrift) *batchv1.Job {
+	spec := cr.Spec.ForProvider.Conversion
+
 	converting_job := &batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: "converting-job",
-			Labels: map[string]string{
-				"app": "converting-lite",
-			},
+			Name:            converting_job_name(cr),
+			Namespace:       deploy_namespace(cr),
+			Labels:          run_job_labels(cr, "converting-lite", step_conversion),
+			Annotations:     run_job_annotations(cr),
+			OwnerReferences: owner_references(cr),
 		},
 		Spec: batchv1.JobSpec{
 			BackoffLimit: int32Ptr(0),
@@ -28,55 +167,49 @@ func get_converting_job() *batchv1.Job {
 					Containers: []corev1.Container{
 						{
 							Name:            "converting-lite",
-							Image:           "lucaserf/converting-lite:latest",
+							Image:           container_image(spec, default_conversion_image),
 							ImagePullPolicy: corev1.PullAlways,
 							VolumeMounts: []corev1.VolumeMount{
-								{
-									Name:      "data-volume",
-									MountPath: "/var/data/",
-								},
+								data_volume_mount(spec),
 							},
 							Env: []corev1.EnvVar{
 								{
 									Name:  "FOLDER_PATH",
-									Value: "/var/data/",
+									Value: data_mount_path(spec),
 								},
 								{
 									Name:  "MODEL_PATH",
-									Value: "regression_model_tf.keras",
+									Value: trained_model_path(model_version(cr)),
 								},
 								{
 									Name:  "OUTPUT_PATH",
-									Value: "model_regression",
+									Value: model_output_path(model_version(cr)),
 								},
 							},
 						},
 					},
 					RestartPolicy: corev1.RestartPolicyNever,
 					Volumes: []corev1.Volume{
-						{
-							Name: "data-volume",
-							VolumeSource: corev1.VolumeSource{
-								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-									ClaimName: "data-pvc",
-								},
-							},
-						},
+						data_volume(spec),
 					},
 				},
 			},
 		},
 	}
+	customise_pod(spec, &converting_job.Spec.Template.Spec)
 	return converting_job
 }
 
-func get_training_job() *batchv1.Job {
+func get_training_job(cr *v1alpha1.CtrlDrift) *batchv1.Job {
+	spec := cr.Spec.ForProvider.Training
+
 	training_job := &batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: "training-job",
-			Labels: map[string]string{
-				"app": "training-regression",
-			},
+			Name:            training_job_name(cr),
+			Namespace:       deploy_namespace(cr),
+			Labels:          run_job_labels(cr, "training-regression", step_training),
+			Annotations:     run_job_annotations(cr),
+			OwnerReferences: owner_references(cr),
 		},
 		Spec: batchv1.JobSpec{
 			BackoffLimit: int32Ptr(0),
@@ -84,28 +217,32 @@ func get_training_job() *batchv1.Job {
 			Parallelism:  int32Ptr(1),
 			Template: corev1.PodTemplateSpec{
 				Spec: corev1.PodSpec{
+					InitContainers: []corev1.Container{
+						get_model_dir_container(cr, spec),
+					},
 					Containers: []corev1.Container{
 						{
 							Name:  "training-regression",
-							Image: "lucaserf/training-regression:latest",
+							Image: container_image(spec, default_training_image),
 							VolumeMounts: []corev1.VolumeMount{
-								{
-									Name:      "data-volume",
-									MountPath: "/var/data/",
-								},
+								data_volume_mount(spec),
 							},
 							Env: []corev1.EnvVar{
 								{
 									Name:  "FOLDER_PATH",
-									Value: "/var/data/",
+									Value: data_mount_path(spec),
 								},
 								{
 									Name:  "OUTPUT_PATH",
-									Value: "regression_model_tf",
+									Value: path.Join(model_dir(model_version(cr)), trained_model_name),
+								},
+								{
+									Name:  "METRICS_PATH",
+									Value: path.Join(model_dir(model_version(cr)), metrics.File),
 								},
 								{
 									Name:  "DATA_PATH",
-									Value: "drift_data.csv",
+									Value: drift_file(cr),
 								},
 								{
 									Name:  "LOGGING_LEVEL",
@@ -120,57 +257,51 @@ func get_training_job() *batchv1.Job {
 					},
 					RestartPolicy: "Never",
 					Volumes: []corev1.Volume{
-						{
-							Name: "data-volume",
-							VolumeSource: corev1.VolumeSource{
-								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-									ClaimName: "data-pvc",
-								},
-							},
-						},
+						data_volume(spec),
 					},
 				},
 			},
 		},
 	}
+	mount_training_script(cr, &training_job.Spec.Template.Spec)
+	customise_pod(spec, &training_job.Spec.Template.Spec)
 	return training_job
 }
 
-func get_drift_detection_deployment() *appsv1.Deployment {
+func get_drift_detection_deployment(cr *v1alpha1.CtrlDrift) *appsv1.Deployment {
+	spec := cr.Spec.ForProvider.Detector
+
 	drift_detection_deployment := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: "drift-deploy",
-			Labels: map[string]string{
-				"app": "drift-detection",
-			},
+			Name:            drift_deployment_name(cr),
+			Namespace:       deploy_namespace(cr),
+			Labels:          object_labels(cr, "drift-detection"),
+			OwnerReferences: owner_references(cr),
 		},
 		Spec: appsv1.DeploymentSpec{
 			Replicas: int32Ptr(1),
 			Selector: &metav1.LabelSelector{
-				MatchLabels: map[string]string{
-					"app": "drift-detection",
-				},
+				MatchLabels: workload_labels(cr, "drift-detection"),
 			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: map[string]string{
-						"app": "drift-detection",
-					},
+					Labels:      workload_labels(cr, "drift-detection"),
+					Annotations: model_annotations(cr),
 				},
 				Spec: corev1.PodSpec{
 					Containers: []corev1.Container{
 						{
 							Name:            "drift-detection",
-							Image:           "lucaserf/drift_detection:latest",
+							Image:           container_image(spec, default_detector_image),
 							ImagePullPolicy: corev1.PullAlways,
-							Env: []corev1.EnvVar{
+							Env: append([]corev1.EnvVar{
 								{
 									Name:  "FOLDER_PATH",
-									Value: "/var/data/",
+									Value: data_mount_path(spec),
 								},
 								{
 									Name:  "BROKER_ADDRESS",
-									Value: "lserf-tinyml.cloudmmwunibo.it",
+									Value: default_broker_host,
 								},
 								{
 									Name:  "TOPIC_NAME",
@@ -186,69 +317,59 @@ func get_drift_detection_deployment() *appsv1.Deployment {
 								},
 								{
 									Name:  "OUTPUT_NAME",
-									Value: "drift_data",
+									Value: drift_output_name(cr),
 								},
-							},
+							}, drift_events_env(cr)...),
 							VolumeMounts: []corev1.VolumeMount{
-								{
-									Name:      "data-volume",
-									MountPath: "/var/data/",
-								},
+								data_volume_mount(spec),
 							},
 						},
 					},
 					Volumes: []corev1.Volume{
-						{
-							Name: "data-volume",
-							VolumeSource: corev1.VolumeSource{
-								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-									ClaimName: "data-pvc",
-								},
-							},
-						},
+						data_volume(spec),
 					},
 				},
 			},
 		},
 	}
+	customise_pod(spec, &drift_detection_deployment.Spec.Template.Spec)
 	return drift_detection_deployment
 }
 
-func get_tflite_deployment() *appsv1.Deployment {
+func get_tflite_deployment(cr *v1alpha1.CtrlDrift) *appsv1.Deployment {
+	spec := cr.Spec.ForProvider.Inference
+
 	tflite_deployment := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: "python-tflite-deploy",
-			Labels: map[string]string{
-				"app": "python-tflite",
-			},
+			Name:            tflite_deployment_name(cr),
+			Namespace:       deploy_namespace(cr),
+			Labels:          object_labels(cr, "python-tflite"),
+			OwnerReferences: owner_references(cr),
 		},
 		Spec: appsv1.DeploymentSpec{
 			Replicas: int32Ptr(1),
 			Selector: &metav1.LabelSelector{
-				MatchLabels: map[string]string{
-					"app": "python-tflite",
-				},
+				MatchLabels: workload_labels(cr, "python-tflite"),
 			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: map[string]string{
-						"app": "python-tflite",
-					},
+					Labels:      workload_labels(cr, "python-tflite"),
+					Annotations: model_annotations(cr),
 				},
 				Spec: corev1.PodSpec{
 					Containers: []corev1.Container{
 						{
 							Name:            "python-tflite",
-							Image:           "lucaserf/python_tflite:latest",
+							Image:           container_image(spec, default_inference_image),
 							ImagePullPolicy: corev1.PullAlways,
 							Env: []corev1.EnvVar{
 								{
 									Name:  "MODEL_NAME",
-									Value: "model_regression.tflite",
+									Value: model_artifact_path(cr.Status.AtProvider.ActiveModelVersion),
 								},
 								{
 									Name:  "DATA_FOLDER",
-									Value: "/var/data/",
+									Value: data_mount_path(spec),
 								},
 								{
 									Name:  "BATCH_SIZE",
@@ -260,30 +381,26 @@ func get_tflite_deployment() *appsv1.Deployment {
 								},
 								{
 									Name:  "BROKER_ADDRESS",
-									Value: "lserf-tinyml.cloudmmwunibo.it",
+									Value: default_broker_host,
 								},
 							},
 							VolumeMounts: []corev1.VolumeMount{
-								{
-									Name:      "data-volume",
-									MountPath: "/var/data/",
-								},
+								data_volume_mount(spec),
 							},
 						},
 					},
 					Volumes: []corev1.Volume{
-						{
-							Name: "data-volume",
-							VolumeSource: corev1.VolumeSource{
-								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-									ClaimName: "data-pvc",
-								},
-							},
-						},
+						data_volume(spec),
 					},
 				},
 			},
 		},
 	}
+	if env := rollout_env(cr, role_stable, cr.Status.AtProvider.ActiveModelVersion, stable_canary_weight(cr)); env != nil {
+		container := &tflite_deployment.Spec.Template.Spec.Containers[0]
+		container.Env = append(container.Env, env...)
+		tflite_deployment.Spec.Template.Annotations[annotationCanaryWeight] = strconv.Itoa(int(stable_canary_weight(cr)))
+	}
+	customise_pod(spec, &tflite_deployment.Spec.Template.Spec)
 	return tflite_deployment
 }
@@ -0,0 +1,208 @@
+package ctrldrift
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+
+	"github.com/crossplane/provider-driftprovider/apis/mlops/v1alpha1"
+	"github.com/crossplane/provider-driftprovider/internal/driftsource"
+	"github.com/crossplane/provider-driftprovider/internal/metrics"
+	"github.com/crossplane/provider-driftprovider/internal/pipeline"
+)
+
+const (
+	default_holdout_file    = "holdout.csv"
+	evaluation_metrics_name = "evaluation.json"
+
+	errReadEvaluation  = "cannot read evaluation metrics"
+	errCheckEvaluation = "cannot check evaluation metrics"
+)
+
+// Reasons of the events emitted when a trained model was evaluated.
+const (
+	reasonModelAccepted event.Reason = "ModelAccepted"
+	reasonModelRejected event.Reason = "ModelRejected"
+)
+
+func evaluation_job_name(cr *v1alpha1.CtrlDrift) string {
+	return run_job_name(cr, step_evaluation)
+}
+
+// evaluation_spec returns the workload spec of the evaluation Job, or nil
+// when no evaluation is configured.
+func evaluation_spec(cr *v1alpha1.CtrlDrift) *v1alpha1.WorkloadSpec {
+	if cr.Spec.ForProvider.Evaluation == nil {
+		return nil
+	}
+	return &cr.Spec.ForProvider.Evaluation.WorkloadSpec
+}
+
+func holdout_path(cr *v1alpha1.CtrlDrift) string {
+	if ev := cr.Spec.ForProvider.Evaluation; ev != nil && ev.HoldoutPath != nil && *ev.HoldoutPath != "" {
+		return *ev.HoldoutPath
+	}
+	return default_holdout_file
+}
+
+// evaluation_metrics_path is the metrics file written by the evaluation of the
+// supplied model version, relative to the data volume.
+func evaluation_metrics_path(version string) string {
+	return path.Join(model_dir(version), evaluation_metrics_name)
+}
+
+func get_evaluation_job(cr *v1alpha1.CtrlDrift) *batchv1.Job {
+	spec := evaluation_spec(cr)
+
+	evaluation_job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            evaluation_job_name(cr),
+			Namespace:       deploy_namespace(cr),
+			Labels:          run_job_labels(cr, "evaluation", step_evaluation),
+			Annotations:     run_job_annotations(cr),
+			OwnerReferences: owner_references(cr),
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: int32Ptr(0),
+			Completions:  int32Ptr(1),
+			Parallelism:  int32Ptr(1),
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  "evaluation",
+							Image: container_image(spec, ""),
+							VolumeMounts: []corev1.VolumeMount{
+								data_volume_mount(spec),
+							},
+							Env: []corev1.EnvVar{
+								{
+									Name:  "FOLDER_PATH",
+									Value: data_mount_path(spec),
+								},
+								{
+									Name:  "MODEL_PATH",
+									Value: trained_model_path(model_version(cr)),
+								},
+								{
+									Name:  "HOLDOUT_PATH",
+									Value: holdout_path(cr),
+								},
+								{
+									Name:  "METRICS_PATH",
+									Value: evaluation_metrics_path(model_version(cr)),
+								},
+								{
+									Name:  "LOGGING_LEVEL",
+									Value: "INFO",
+								},
+							},
+						},
+					},
+					RestartPolicy: corev1.RestartPolicyNever,
+					Volumes: []corev1.Volume{
+						data_volume(spec),
+					},
+				},
+			},
+		},
+	}
+	if ev := cr.Spec.ForProvider.Evaluation; ev != nil && len(ev.Command) > 0 {
+		evaluation_job.Spec.Template.Spec.Containers[0].Command = ev.Command
+	}
+	customise_pod(spec, &evaluation_job.Spec.Template.Spec)
+	return evaluation_job
+}
+
+// read_evaluation_metrics reads the metrics written by the evaluation of the
+// supplied model version, like read_model_manifest reads the model.
+func (c *external) read_evaluation_metrics(ctx context.Context, cr *v1alpha1.CtrlDrift, version string) (map[string]string, error) {
+	var reader driftsource.Reader = &driftsource.LocalReader{Dir: local_drift_path(cr)}
+	if src := cr.Spec.ForProvider.DriftSource; src != nil && src.Type != v1alpha1.DriftSourceLocal {
+		spec := evaluation_spec(cr)
+		reader = &driftsource.PodReader{
+			Client:    c.clientset,
+			Pod:       get_reader_pod(cr, cr.Spec.ForProvider.DeployName+"-evaluation-reader", "evaluation-reader", spec),
+			MountPath: data_mount_path(spec),
+		}
+	}
+	f, err := reader.Open(ctx, evaluation_metrics_path(version))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck // Only read from.
+	return metrics.Parse(f)
+}
+
+// active_evaluation_metrics returns the evaluation metrics of the model
+// currently served, or nil when it was not evaluated.
+func (c *external) active_evaluation_metrics(ctx context.Context, cr *v1alpha1.CtrlDrift) (map[string]string, error) {
+	version := cr.Status.AtProvider.ActiveModelVersion
+	if version == "" {
+		return nil, nil
+	}
+	mv, err := c.get_recorded_model_version(ctx, cr, version)
+	if kerrors.IsNotFound(errors.Cause(err)) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mv.Spec.ForProvider.EvaluationMetrics, nil
+}
+
+// evaluate checks the metrics of the evaluation of the last trained model
+// against the thresholds of the evaluation and records the outcome in
+// status.atProvider.lastEvaluation. A model is only checked once. The verdict
+// is unknown while the metrics are still being read, and a model whose
+// metrics cannot be read is rejected.
+func (c *external) evaluate(ctx context.Context, cr *v1alpha1.CtrlDrift) (pipeline.Verdict, error) {
+	version := model_version(cr)
+	if last := cr.Status.AtProvider.LastEvaluation; last != nil && last.ModelVersion == version {
+		if last.Accepted {
+			return pipeline.VerdictAccepted, nil
+		}
+		return pipeline.VerdictRejected, nil
+	}
+
+	now := metav1.Now()
+	result := &v1alpha1.EvaluationResult{ModelVersion: version, Time: &now}
+
+	values, err := c.read_evaluation_metrics(ctx, cr, version)
+	switch {
+	case driftsource.IsPending(err):
+		c.logger.Debug(fmt.Sprintf("Evaluation metrics of model %s not available yet: %s", version, err))
+		return pipeline.VerdictUnknown, nil
+	case err != nil:
+		result.Message = errors.Wrap(err, errReadEvaluation).Error()
+	default:
+		active, err := c.active_evaluation_metrics(ctx, cr)
+		if err != nil {
+			return pipeline.VerdictUnknown, err
+		}
+		verdict, err := metrics.Check(cr.Spec.ForProvider.Evaluation.Thresholds, values, active)
+		if err != nil {
+			return pipeline.VerdictUnknown, errors.Wrap(err, errCheckEvaluation)
+		}
+		result.Metrics = values
+		result.Accepted = verdict.Accepted
+		result.Message = strings.Join(verdict.Unmet, ", ")
+	}
+	cr.Status.AtProvider.LastEvaluation = result
+
+	if !result.Accepted {
+		c.recorder.Event(cr, event.Warning(reasonModelRejected, errors.Errorf("model %s rejected: %s", version, result.Message)))
+		return pipeline.VerdictRejected, nil
+	}
+	c.recorder.Event(cr, event.Normal(reasonModelAccepted, fmt.Sprintf("model %s accepted by its evaluation", version)))
+	return pipeline.VerdictAccepted, nil
+}
@@ -0,0 +1,185 @@
+package ctrldrift
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+
+	"github.com/crossplane/provider-driftprovider/apis/mlops/v1alpha1"
+)
+
+const (
+	default_retry_limit       int32 = 3
+	default_retry_backoff           = 30 * time.Second
+	default_max_retry_backoff       = 10 * time.Minute
+	default_log_lines         int32 = 20
+
+	// label_job_name is set by the Job controller on the pods of a Job.
+	label_job_name = "job-name"
+
+	errListJobPods = "cannot list pods of job %s"
+	errJobLog      = "cannot read log of pod %s"
+)
+
+// reasonJobFailed is the reason of the event emitted for a failed Job.
+const reasonJobFailed event.Reason = "JobFailed"
+
+func retry_limit(cr *v1alpha1.CtrlDrift) int32 {
+	if p := cr.Spec.ForProvider.RetryPolicy; p != nil && p.Limit != nil {
+		return *p.Limit
+	}
+	return default_retry_limit
+}
+
+// retry_backoff is the delay before the supplied retry. It doubles with
+// every retry up to the maximum backoff.
+func retry_backoff(cr *v1alpha1.CtrlDrift, retry int32) time.Duration {
+	backoff, limit := default_retry_backoff, default_max_retry_backoff
+	if p := cr.Spec.ForProvider.RetryPolicy; p != nil {
+		if p.Backoff != nil {
+			backoff = p.Backoff.Duration
+		}
+		if p.MaxBackoff != nil {
+			limit = p.MaxBackoff.Duration
+		}
+	}
+	for i := int32(1); i < retry && backoff < limit; i++ {
+		backoff *= 2
+	}
+	if backoff > limit {
+		return limit
+	}
+	return backoff
+}
+
+func log_lines(cr *v1alpha1.CtrlDrift) int64 {
+	if p := cr.Spec.ForProvider.RetryPolicy; p != nil && p.LogLines != nil {
+		return int64(*p.LogLines)
+	}
+	return int64(default_log_lines)
+}
+
+// retries_left returns true when the last failed Job of the run may be
+// retried.
+func retries_left(cr *v1alpha1.CtrlDrift) bool {
+	return cr.Status.AtProvider.Retries <= retry_limit(cr)
+}
+
+// backing_off returns true until the last failed Job may be started again.
+func backing_off(cr *v1alpha1.CtrlDrift, now time.Time) bool {
+	next := cr.Status.AtProvider.NextRetryTime
+	return next != nil && now.Before(next.Time)
+}
+
+// job_failure_reason returns the reason and message of the Failed condition
+// of a Job.
+func job_failure_reason(job *batchv1.Job) string {
+	for _, c := range job.Status.Conditions {
+		if c.Type == batchv1.JobFailed && c.Status == corev1.ConditionTrue {
+			if c.Message == "" {
+				return c.Reason
+			}
+			return c.Reason + ": " + c.Message
+		}
+	}
+	return ""
+}
+
+// failed_pod_log returns the end of the log of the newest pod of a Job.
+func (c *external) failed_pod_log(ctx context.Context, cr *v1alpha1.CtrlDrift, job *batchv1.Job) (string, error) {
+	if c.clientset == nil {
+		return "", nil
+	}
+	pods, err := c.clientset.CoreV1().Pods(job.Namespace).List(ctx, metav1.ListOptions{LabelSelector: label_job_name + "=" + job.Name})
+	if err != nil {
+		return "", errors.Wrapf(err, errListJobPods, job.Name)
+	}
+	var newest *corev1.Pod
+	for i := range pods.Items {
+		if newest == nil || newest.CreationTimestamp.Before(&pods.Items[i].CreationTimestamp) {
+			newest = &pods.Items[i]
+		}
+	}
+	if newest == nil {
+		return "", nil
+	}
+
+	lines := log_lines(cr)
+	opts := &corev1.PodLogOptions{TailLines: &lines}
+	if containers := job.Spec.Template.Spec.Containers; len(containers) > 0 {
+		opts.Container = containers[0].Name
+	}
+	raw, err := c.clientset.CoreV1().Pods(newest.Namespace).GetLogs(newest.Name, opts).DoRaw(ctx)
+	if err != nil {
+		return "", errors.Wrapf(err, errJobLog, newest.Name)
+	}
+	return strings.TrimRight(string(raw), "\n"), nil
+}
+
+// record_job_failure records a failed Job of the run in
+// status.atProvider.lastFailure together with the end of the log of its pod,
+// and schedules its retry. A Job is only recorded once.
+func (c *external) record_job_failure(ctx context.Context, cr *v1alpha1.CtrlDrift, job *batchv1.Job, now metav1.Time) {
+	if last := cr.Status.AtProvider.LastFailure; last != nil && last.UID == string(job.UID) {
+		return
+	}
+
+	log, err := c.failed_pod_log(ctx, cr, job)
+	if err != nil {
+		//the failure is recorded without its log
+		c.logger.Debug(fmt.Sprintf("Cannot capture the log of failed job %s: %s", job.Name, err))
+	}
+
+	cr.Status.AtProvider.Retries++
+	failure := &v1alpha1.JobFailure{
+		Job:     job.Name,
+		UID:     string(job.UID),
+		Attempt: cr.Status.AtProvider.Retries,
+		Reason:  job_failure_reason(job),
+		Log:     log,
+		Time:    &now,
+	}
+	cr.Status.AtProvider.LastFailure = failure
+	cr.Status.AtProvider.NextRetryTime = nil
+
+	summary := fmt.Sprintf("job %s failed", job.Name)
+	if failure.Reason != "" {
+		summary += ": " + failure.Reason
+	}
+	if retries_left(cr) {
+		next := metav1.NewTime(now.Add(retry_backoff(cr, failure.Attempt)))
+		cr.Status.AtProvider.NextRetryTime = &next
+		summary += fmt.Sprintf(", retry %d of %d at %s", failure.Attempt, retry_limit(cr), next.UTC().Format(time.RFC3339))
+	} else {
+		summary += fmt.Sprintf(", no retries left after %d attempts", failure.Attempt)
+	}
+	if log != "" {
+		summary += "\n" + log
+	}
+	c.recorder.Event(cr, event.Warning(reasonJobFailed, errors.New(summary)))
+}
+
+// failure_message explains the last failed Job for the status message and
+// the Ready condition of a failed pipeline.
+func failure_message(cr *v1alpha1.CtrlDrift) string {
+	last := cr.Status.AtProvider.LastFailure
+	if last == nil {
+		return ""
+	}
+	msg := fmt.Sprintf("job %s failed after %d attempts", last.Job, last.Attempt)
+	if last.Reason != "" {
+		msg += ": " + last.Reason
+	}
+	if last.Log != "" {
+		msg += "\n" + last.Log
+	}
+	return msg
+}
@@ -0,0 +1,216 @@
+package ctrldrift
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/provider-driftprovider/apis/mlops/v1alpha1"
+	"github.com/crossplane/provider-driftprovider/internal/pipeline"
+)
+
+const (
+	// labelRun is set on every Job to the ID of the run it belongs to.
+	labelRun = "driftprovider.crossplane.io/run"
+
+	// labelStep is set on every Job to the step of the run it performs.
+	labelStep = "driftprovider.crossplane.io/step"
+
+	// labelRetired is set on a finished Job once the pipeline moved past
+	// it. A retired Job is only kept as history.
+	labelRetired = "driftprovider.crossplane.io/retired"
+
+	// annotationGeneration is set on every Job to the generation of the
+	// CtrlDrift it was started for.
+	annotationGeneration = "driftprovider.crossplane.io/generation"
+
+	step_training   = "training"
+	step_evaluation = "evaluation"
+	step_conversion = "conversion"
+
+	default_successful_history_limit int32 = 3
+	default_failed_history_limit     int32 = 3
+	default_job_ttl                        = 24 * time.Hour
+
+	errRetireJob = "cannot retire job"
+	errPruneJob  = "cannot delete job from history"
+)
+
+// run_id returns the ID of the current run. It is the time the run was
+// started, in the format of the model versions.
+func run_id(cr *v1alpha1.CtrlDrift) string {
+	if cr.Status.AtProvider.Run != "" {
+		return cr.Status.AtProvider.Run
+	}
+	return model_version(cr)
+}
+
+// run_job_name names the Job of a step of the current run. A Job started
+// again after a failure gets the number of failures of the run as suffix.
+func run_job_name(cr *v1alpha1.CtrlDrift, step string) string {
+	name := fmt.Sprintf("%s-%s-%s", cr.Spec.ForProvider.DeployName, step, run_id(cr))
+	if n := cr.Status.AtProvider.Retries; n > 0 {
+		name += "-" + strconv.Itoa(int(n))
+	}
+	return name
+}
+
+// run_job_labels are the labels of the Job of a step of the current run.
+func run_job_labels(cr *v1alpha1.CtrlDrift, app string, step string) map[string]string {
+	l := object_labels(cr, app)
+	l[labelRun] = run_id(cr)
+	l[labelStep] = step
+	return l
+}
+
+// run_job_annotations are the annotations of the Job of a step of the current
+// run.
+func run_job_annotations(cr *v1alpha1.CtrlDrift) map[string]string {
+	return map[string]string{annotationGeneration: strconv.FormatInt(cr.GetGeneration(), 10)}
+}
+
+// spec_changed returns true when one of the supplied Jobs was started for an
+// earlier generation of the CtrlDrift. Any of them may be nil.
+func spec_changed(cr *v1alpha1.CtrlDrift, jobs ...*batchv1.Job) bool {
+	for _, job := range jobs {
+		if job == nil {
+			continue
+		}
+		if g, ok := job.Annotations[annotationGeneration]; ok && g != strconv.FormatInt(cr.GetGeneration(), 10) {
+			return true
+		}
+	}
+	return false
+}
+
+// job_ttl is the ttlSecondsAfterFinished set on a Job once it is retired, so
+// that a Job of the run in flight is never deleted by the TTL controller.
+func job_ttl(cr *v1alpha1.CtrlDrift) *int32 {
+	ttl := default_job_ttl
+	if h := cr.Spec.ForProvider.JobHistory; h != nil && h.TTLAfterFinished != nil {
+		ttl = h.TTLAfterFinished.Duration
+	}
+	return int32Ptr(int32(ttl.Seconds()))
+}
+
+func history_limits(cr *v1alpha1.CtrlDrift) (successful, failed int) {
+	s, f := default_successful_history_limit, default_failed_history_limit
+	if h := cr.Spec.ForProvider.JobHistory; h != nil {
+		if h.SuccessfulJobsHistoryLimit != nil {
+			s = *h.SuccessfulJobsHistoryLimit
+		}
+		if h.FailedJobsHistoryLimit != nil {
+			f = *h.FailedJobsHistoryLimit
+		}
+	}
+	return int(s), int(f)
+}
+
+func retired(job *batchv1.Job) bool {
+	return job.Labels[labelRetired] == "true"
+}
+
+// newer returns the more recently created of two Jobs. Either may be nil.
+func newer(a, b *batchv1.Job) *batchv1.Job {
+	if a == nil || (b != nil && a.CreationTimestamp.Before(&b.CreationTimestamp)) {
+		return b
+	}
+	return a
+}
+
+// run_jobs returns the newest Job of every step that was not retired yet.
+func run_jobs(jobs []batchv1.Job) (training, evaluation, conversion *batchv1.Job) {
+	for i := range jobs {
+		job := &jobs[i]
+		if retired(job) {
+			continue
+		}
+		switch job.Labels[labelStep] {
+		case step_training:
+			training = newer(training, job)
+		case step_evaluation:
+			evaluation = newer(evaluation, job)
+		case step_conversion:
+			conversion = newer(conversion, job)
+		}
+	}
+	return training, evaluation, conversion
+}
+
+// retire_job takes a Job out of the run while keeping it as history, until
+// it is pruned or its TTL expires.
+func (c *external) retire_job(ctx context.Context, cr *v1alpha1.CtrlDrift, job *batchv1.Job) error {
+	if job == nil || retired(job) {
+		return nil
+	}
+	patch := client.MergeFrom(job.DeepCopy())
+	if job.Labels == nil {
+		job.Labels = map[string]string{}
+	}
+	job.Labels[labelRetired] = "true"
+	job.Spec.TTLSecondsAfterFinished = job_ttl(cr)
+	return errors.Wrap(client.IgnoreNotFound(c.kube.Patch(ctx, job, patch)), errRetireJob)
+}
+
+// prune_history deletes the oldest retired Jobs beyond the history limits and
+// returns the Jobs that are kept.
+func (c *external) prune_history(ctx context.Context, cr *v1alpha1.CtrlDrift, jobs []batchv1.Job) ([]batchv1.Job, error) {
+	successful, failed := history_limits(cr)
+	history := map[pipeline.JobState][]*batchv1.Job{}
+	for i := range jobs {
+		if job := &jobs[i]; retired(job) {
+			state := pipeline.JobStateOf(job)
+			history[state] = append(history[state], job)
+		}
+	}
+
+	pruned := map[string]bool{}
+	for state, limit := range map[pipeline.JobState]int{pipeline.JobSucceeded: successful, pipeline.JobFailed: failed} {
+		h := history[state]
+		sort.Slice(h, func(i, j int) bool { return h[j].CreationTimestamp.Before(&h[i].CreationTimestamp) })
+		for i := limit; i < len(h); i++ {
+			c.logger.Debug(fmt.Sprintf("Delete job %s from history", h[i].Name))
+			err := c.kube.Delete(ctx, h[i], client.PropagationPolicy(metav1.DeletePropagationBackground))
+			if client.IgnoreNotFound(err) != nil {
+				return nil, errors.Wrap(err, errPruneJob)
+			}
+			pruned[h[i].Name] = true
+		}
+	}
+
+	kept := []batchv1.Job{}
+	for _, job := range jobs {
+		if !pruned[job.Name] {
+			kept = append(kept, job)
+		}
+	}
+	return kept, nil
+}
+
+// run_history links the runs of the supplied Jobs to their Jobs, newest
+// first.
+func run_history(jobs []batchv1.Job) []v1alpha1.RunStatus {
+	runs := map[string][]string{}
+	for _, job := range jobs {
+		if id := job.Labels[labelRun]; id != "" {
+			runs[id] = append(runs[id], job.Name)
+		}
+	}
+	history := make([]v1alpha1.RunStatus, 0, len(runs))
+	for id, names := range runs {
+		sort.Strings(names)
+		history = append(history, v1alpha1.RunStatus{ID: id, Jobs: names})
+	}
+	sort.Slice(history, func(i, j int) bool { return history[i].ID > history[j].ID })
+	if len(history) == 0 {
+		return nil
+	}
+	return history
+}
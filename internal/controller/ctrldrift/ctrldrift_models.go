@@ -0,0 +1,330 @@
+package ctrldrift
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+
+	"github.com/crossplane/provider-driftprovider/apis/mlops/v1alpha1"
+	"github.com/crossplane/provider-driftprovider/internal/driftsource"
+	"github.com/crossplane/provider-driftprovider/internal/metrics"
+)
+
+const (
+	// models_dir holds one directory per model version on the data volume,
+	// so that a run never overwrites the model currently served.
+	models_dir = "models"
+
+	// The training image appends .keras to its output path, the conversion
+	// image appends .tflite.
+	trained_model_name   = "regression_model_tf"
+	converted_model_name = "model_regression"
+
+	// initial_model_file is the model served before the first retrain was
+	// rolled out.
+	initial_model_file = converted_model_name + ".tflite"
+
+	errGetModelVersion    = "cannot get ModelVersion"
+	errCreateModelVersion = "cannot create ModelVersion"
+	errReadModel          = "cannot read model"
+	errModelManifest      = "cannot parse model manifest"
+	errChecksumLine       = "unexpected checksum line %q"
+	errListModelVersions  = "cannot list ModelVersions"
+	errModelNotRecorded   = "model version %s of CtrlDrift %s is not recorded"
+)
+
+// Reason of the warning event emitted when a model is recorded without its
+// checksum or metrics.
+const reasonModelUnreadable event.Reason = "ModelUnreadable"
+
+// model_manifest_script prints the checksum of the converted model in the
+// directory "$0" followed by the training metrics, if any.
+const model_manifest_script = `test -f "$0/` + initial_model_file + `" || exit 3; sha256sum "$0/` + initial_model_file + `"; cat "$0/` + metrics.File + `" 2>/dev/null; true`
+
+var sha256sum_line = regexp.MustCompile(`^([0-9a-f]{64})\s`)
+
+// model_dir is the directory of the supplied model version, relative to the
+// data volume.
+func model_dir(version string) string {
+	return path.Join(models_dir, version)
+}
+
+// trained_model_path is the model written by the training run of the supplied
+// version.
+func trained_model_path(version string) string {
+	return path.Join(model_dir(version), trained_model_name+".keras")
+}
+
+// model_output_path is the output path of the conversion run of the supplied
+// version.
+func model_output_path(version string) string {
+	return path.Join(model_dir(version), converted_model_name)
+}
+
+// model_artifact_path is the converted model of the supplied version, the
+// model served by the inference Deployment.
+func model_artifact_path(version string) string {
+	if version == "" {
+		return initial_model_file
+	}
+	return path.Join(model_dir(version), initial_model_file)
+}
+
+// model_version_name is the name of the ModelVersion recording the supplied
+// version of a CtrlDrift. ModelVersions are cluster scoped, so they are named
+// after their CtrlDrift rather than its deploy name, which is only unique
+// within a namespace.
+func model_version_name(cr *v1alpha1.CtrlDrift, version string) string {
+	return cr.GetName() + "-" + version
+}
+
+// get_model_dir_container creates the directory of the model version a
+// training run writes to.
+func get_model_dir_container(cr *v1alpha1.CtrlDrift, spec *v1alpha1.WorkloadSpec) corev1.Container {
+	return corev1.Container{
+		Name:         "model-dir",
+		Image:        default_reader_image,
+		Command:      []string{"mkdir", "-p", path.Join(data_mount_path(spec), model_dir(model_version(cr)))},
+		VolumeMounts: []corev1.VolumeMount{data_volume_mount(spec)},
+	}
+}
+
+// A modelManifest is what the provider could read about a converted model.
+type modelManifest struct {
+	checksum string
+	metrics  map[string]string
+}
+
+// parse_model_manifest parses the output of model_manifest_script.
+func parse_model_manifest(r io.Reader) (modelManifest, error) {
+	br := bufio.NewReader(r)
+	line, err := br.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return modelManifest{}, errors.Wrap(err, errModelManifest)
+	}
+	m := sha256sum_line.FindStringSubmatch(line)
+	if m == nil {
+		return modelManifest{}, errors.Wrap(errors.Errorf(errChecksumLine, strings.TrimSpace(line)), errModelManifest)
+	}
+	manifest := modelManifest{checksum: "sha256:" + m[1]}
+	manifest.metrics, err = metrics.Parse(br)
+	return manifest, err
+}
+
+// read_local_model_manifest reads the manifest of a model through a reader
+// that returns the files as they are.
+func read_local_model_manifest(ctx context.Context, reader driftsource.Reader, dir string) (modelManifest, error) {
+	f, err := reader.Open(ctx, path.Join(dir, initial_model_file))
+	if err != nil {
+		return modelManifest{}, err
+	}
+	defer f.Close() //nolint:errcheck // Only read from.
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return modelManifest{}, err
+	}
+	manifest := modelManifest{checksum: fmt.Sprintf("sha256:%x", h.Sum(nil))}
+
+	mf, err := reader.Open(ctx, path.Join(dir, metrics.File))
+	if driftsource.IsNotFound(err) {
+		return manifest, nil
+	}
+	if err != nil {
+		return manifest, err
+	}
+	defer mf.Close() //nolint:errcheck // Only read from.
+	manifest.metrics, err = metrics.Parse(mf)
+	return manifest, err
+}
+
+// read_model_manifest reads the checksum and the metrics of the supplied
+// model version from the data volume of the inference Deployment. With a
+// Local drift source the volume is expected to be mounted into the provider,
+// as the drift data is. Otherwise it is read by a short lived pod.
+func (c *external) read_model_manifest(ctx context.Context, cr *v1alpha1.CtrlDrift, version string) (modelManifest, error) {
+	if src := cr.Spec.ForProvider.DriftSource; src == nil || src.Type == v1alpha1.DriftSourceLocal {
+		return read_local_model_manifest(ctx, &driftsource.LocalReader{Dir: local_drift_path(cr)}, model_dir(version))
+	}
+
+	spec := cr.Spec.ForProvider.Inference
+	reader := &driftsource.PodReader{
+		Client:    c.clientset,
+		Pod:       get_reader_pod(cr, cr.Spec.ForProvider.DeployName+"-model-reader", "model-reader", spec),
+		MountPath: data_mount_path(spec),
+		Script:    model_manifest_script,
+	}
+	f, err := reader.Open(ctx, model_dir(version))
+	if err != nil {
+		return modelManifest{}, err
+	}
+	defer f.Close() //nolint:errcheck // Only read from.
+	return parse_model_manifest(f)
+}
+
+// get_model_version returns the ModelVersion recording the model produced by
+// the last run of the supplied CtrlDrift.
+func get_model_version(cr *v1alpha1.CtrlDrift, manifest modelManifest) *v1alpha1.ModelVersion {
+	version := model_version(cr)
+	at := cr.Status.AtProvider
+	var evaluation map[string]string
+	if at.LastEvaluation != nil && at.LastEvaluation.ModelVersion == version {
+		evaluation = at.LastEvaluation.Metrics
+	}
+	dataset := &v1alpha1.ModelDataset{Path: drift_file(cr), Samples: at.TrainingSamples}
+	if s := dataset_snapshot(cr, run_id(cr)); s != nil {
+		dataset.Path, dataset.Checksum, dataset.Rows, dataset.Bytes = s.Path, s.Checksum, s.Rows, s.Bytes
+	}
+	return &v1alpha1.ModelVersion{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   model_version_name(cr, version),
+			Labels: object_labels(cr, "model"),
+		},
+		Spec: v1alpha1.ModelVersionSpec{
+			ResourceSpec: xpv1.ResourceSpec{
+				ProviderConfigReference: cr.GetProviderConfigReference(),
+			},
+			ForProvider: v1alpha1.ModelVersionParameters{
+				CtrlDrift:              cr.GetName(),
+				Version:                version,
+				ArtifactPath:           model_artifact_path(version),
+				TrainedModelPath:       trained_model_path(version),
+				Checksum:               manifest.checksum,
+				Dataset:                dataset,
+				Metrics:                manifest.metrics,
+				EvaluationMetrics:      evaluation,
+				TrainingScriptRevision: at.TrainingScriptRevision,
+				TrainingStartTime:      at.LastTrainingStartTime,
+				TrainingCompletionTime: at.LastTrainingCompletionTime,
+			},
+		},
+	}
+}
+
+// record_model_version creates the ModelVersion of the model about to be
+// rolled out, unless it already exists. It returns a pending error while the
+// model is still being read. A model that cannot be read is recorded without
+// whatever could not be read.
+func (c *external) record_model_version(ctx context.Context, cr *v1alpha1.CtrlDrift) error {
+	version := model_version(cr)
+	err := c.kube.Get(ctx, types.NamespacedName{Name: model_version_name(cr, version)}, &v1alpha1.ModelVersion{})
+	if err == nil {
+		return nil
+	}
+	if !kerrors.IsNotFound(err) {
+		return errors.Wrap(err, errGetModelVersion)
+	}
+
+	manifest, err := c.read_model_manifest(ctx, cr, version)
+	if driftsource.IsPending(err) {
+		return err
+	}
+	if err != nil {
+		c.recorder.Event(cr, event.Warning(reasonModelUnreadable, errors.Wrap(err, errReadModel)))
+	}
+
+	err = c.kube.Create(ctx, get_model_version(cr, manifest))
+	if err != nil && !kerrors.IsAlreadyExists(err) {
+		return errors.Wrap(err, errCreateModelVersion)
+	}
+	cr.Status.AtProvider.LatestModelVersion = version
+	return nil
+}
+
+func pinned_model_version(cr *v1alpha1.CtrlDrift) string {
+	if v := cr.Spec.ForProvider.ModelVersion; v != nil {
+		return *v
+	}
+	return ""
+}
+
+// target_model_version is the model the Deployments should serve once the
+// pipeline settled: the pinned model, or the model of the last run.
+func target_model_version(cr *v1alpha1.CtrlDrift) string {
+	if pin := pinned_model_version(cr); pin != "" {
+		return pin
+	}
+	return model_version(cr)
+}
+
+// get_recorded_model_version returns the ModelVersion recording the supplied
+// version of a CtrlDrift. A version that was never recorded is reported as
+// not found.
+func (c *external) get_recorded_model_version(ctx context.Context, cr *v1alpha1.CtrlDrift, version string) (*v1alpha1.ModelVersion, error) {
+	mv := &v1alpha1.ModelVersion{}
+	err := c.kube.Get(ctx, types.NamespacedName{Name: model_version_name(cr, version)}, mv)
+	if err == nil && mv.Spec.ForProvider.CtrlDrift != cr.GetName() {
+		err = kerrors.NewNotFound(v1alpha1.SchemeGroupVersion.WithResource("modelversions").GroupResource(), model_version_name(cr, version))
+	}
+	if kerrors.IsNotFound(err) {
+		return nil, errors.Wrapf(err, errModelNotRecorded, version, cr.GetName())
+	}
+	return mv, errors.Wrap(err, errGetModelVersion)
+}
+
+// newest_model_version returns the newest recorded model of the supplied
+// CtrlDrift, or nil if none was recorded yet.
+func (c *external) newest_model_version(ctx context.Context, cr *v1alpha1.CtrlDrift) (*v1alpha1.ModelVersion, error) {
+	mvs := &v1alpha1.ModelVersionList{}
+	if err := c.kube.List(ctx, mvs, client.MatchingLabels{labelCtrlDrift: cr.GetName()}); err != nil {
+		return nil, errors.Wrap(err, errListModelVersions)
+	}
+	var newest *v1alpha1.ModelVersion
+	for i := range mvs.Items {
+		mv := &mvs.Items[i]
+		// Versions are timestamps, so they sort like strings.
+		if mv.Spec.ForProvider.CtrlDrift == cr.GetName() && (newest == nil || mv.Spec.ForProvider.Version > newest.Spec.ForProvider.Version) {
+			newest = mv
+		}
+	}
+	return newest, nil
+}
+
+// apply_model_pin makes the pinned model the active one. Once the pin is
+// removed the newest recorded model becomes active again.
+func (c *external) apply_model_pin(ctx context.Context, cr *v1alpha1.CtrlDrift) error {
+	at := &cr.Status.AtProvider
+
+	pin := pinned_model_version(cr)
+	if pin == "" {
+		if at.PinnedModelVersion == "" {
+			return nil
+		}
+		newest, err := c.newest_model_version(ctx, cr)
+		if err != nil {
+			return err
+		}
+		at.PinnedModelVersion = ""
+		at.ActiveModelVersion = ""
+		at.ActiveModelScriptRevision = ""
+		if newest != nil {
+			at.ActiveModelVersion = newest.Spec.ForProvider.Version
+			at.ActiveModelScriptRevision = newest.Spec.ForProvider.TrainingScriptRevision
+			at.LatestModelVersion = newest.Spec.ForProvider.Version
+		}
+		return nil
+	}
+
+	mv, err := c.get_recorded_model_version(ctx, cr, pin)
+	if err != nil {
+		return err
+	}
+	at.PinnedModelVersion = pin
+	at.ActiveModelVersion = pin
+	at.ActiveModelScriptRevision = mv.Spec.ForProvider.TrainingScriptRevision
+	return nil
+}
@@ -0,0 +1,272 @@
+package ctrldrift
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+
+	"github.com/crossplane/provider-driftprovider/apis/mlops/v1alpha1"
+	"github.com/crossplane/provider-driftprovider/internal/driftsource"
+	"github.com/crossplane/provider-driftprovider/internal/metrics"
+	"github.com/crossplane/provider-driftprovider/internal/pipeline"
+)
+
+const (
+	// annotationCanaryWeight is set on the pod template of the inference
+	// Deployment to the share of the input it leaves to a canary.
+	annotationCanaryWeight = "driftprovider.crossplane.io/canary-weight"
+
+	default_canary_weight int32 = 10
+	default_bake_time           = time.Hour
+
+	inference_metrics_name = "inference.json"
+
+	role_stable = "stable"
+	role_canary = "canary"
+	role_shadow = "shadow"
+
+	errReadRolloutMetrics  = "cannot read rollout metrics"
+	errCheckRolloutMetrics = "cannot check rollout metrics"
+)
+
+// Reasons of the events emitted when a candidate finished baking.
+const (
+	reasonModelPromoted   event.Reason = "ModelPromoted"
+	reasonModelRolledBack event.Reason = "ModelRolledBack"
+)
+
+func candidate_deployment_name(cr *v1alpha1.CtrlDrift) string {
+	return cr.Spec.ForProvider.DeployName + "-python-tflite-candidate"
+}
+
+func rollout_strategy(cr *v1alpha1.CtrlDrift) v1alpha1.RolloutStrategy {
+	if r := cr.Spec.ForProvider.Rollout; r != nil && r.Strategy != "" {
+		return r.Strategy
+	}
+	return v1alpha1.RolloutRecreate
+}
+
+func canary_weight(cr *v1alpha1.CtrlDrift) int32 {
+	if r := cr.Spec.ForProvider.Rollout; r != nil && r.CanaryWeight != nil {
+		return *r.CanaryWeight
+	}
+	return default_canary_weight
+}
+
+func bake_time(cr *v1alpha1.CtrlDrift) time.Duration {
+	if r := cr.Spec.ForProvider.Rollout; r != nil && r.BakeTime != nil {
+		return r.BakeTime.Duration
+	}
+	return default_bake_time
+}
+
+// baking_rollout returns the rollout of the model produced by the last run
+// while its candidate bakes, or nil.
+func baking_rollout(cr *v1alpha1.CtrlDrift) *v1alpha1.RolloutStatus {
+	r := cr.Status.AtProvider.Rollout
+	if r == nil || r.ModelVersion != model_version(cr) || r.Outcome != "" {
+		return nil
+	}
+	return r
+}
+
+// stable_canary_weight is the share of the input the inference Deployment
+// leaves to the candidate of a Canary rollout.
+func stable_canary_weight(cr *v1alpha1.CtrlDrift) int32 {
+	if r := baking_rollout(cr); r != nil && r.Strategy == v1alpha1.RolloutCanary {
+		return canary_weight(cr)
+	}
+	return 0
+}
+
+// inference_metrics_path is the running metrics file of the inference
+// container serving the supplied model version.
+func inference_metrics_path(version string) string {
+	return path.Join(model_dir(version), inference_metrics_name)
+}
+
+// rollout_env tells an inference container its role in Canary and Shadow
+// rollouts. The inference Deployment gets none with the Recreate strategy.
+func rollout_env(cr *v1alpha1.CtrlDrift, role string, version string, weight int32) []corev1.EnvVar {
+	if rollout_strategy(cr) == v1alpha1.RolloutRecreate {
+		return nil
+	}
+	env := []corev1.EnvVar{
+		{Name: "INFERENCE_ROLE", Value: role},
+		{Name: "METRICS_PATH", Value: inference_metrics_path(version)},
+		{Name: "CANARY_WEIGHT", Value: strconv.Itoa(int(weight))},
+	}
+	if role == role_shadow {
+		env = append(env, corev1.EnvVar{Name: "PUBLISH_PREDICTIONS", Value: "false"})
+	}
+	return env
+}
+
+// get_candidate_deployment returns the Deployment that runs the model
+// produced by the last run next to the inference Deployment.
+func get_candidate_deployment(cr *v1alpha1.CtrlDrift) *appsv1.Deployment {
+	version := model_version(cr)
+	role, weight := role_canary, canary_weight(cr)
+	if rollout_strategy(cr) == v1alpha1.RolloutShadow {
+		role, weight = role_shadow, 100
+	}
+
+	d := get_tflite_deployment(cr)
+	d.Name = candidate_deployment_name(cr)
+	d.Labels = object_labels(cr, "python-tflite-candidate")
+	d.Spec.Replicas = int32Ptr(1)
+	d.Spec.Selector = &metav1.LabelSelector{MatchLabels: workload_labels(cr, "python-tflite-candidate")}
+	d.Spec.Template.Labels = workload_labels(cr, "python-tflite-candidate")
+	d.Spec.Template.Annotations = map[string]string{annotationModelVersion: version}
+
+	container := &d.Spec.Template.Spec.Containers[0]
+	container.Env = merge_env(container.Env, append([]corev1.EnvVar{
+		{Name: "MODEL_NAME", Value: model_artifact_path(version)},
+	}, rollout_env(cr, role, version, weight)...))
+	return d
+}
+
+// rollout_metrics_script prints the metrics files of the candidate and of the
+// inference Deployment relative to "$0", or null for a missing file.
+func rollout_metrics_script(files ...string) string {
+	quoted := make([]string, len(files))
+	for i, f := range files {
+		quoted[i] = strconv.Quote(f)
+	}
+	return fmt.Sprintf(`for f in %s; do if test -f "$0/$f"; then cat "$0/$f"; else echo null; fi; echo; done`, strings.Join(quoted, " "))
+}
+
+// read_rollout_metrics reads the running metrics of the candidate and of the
+// inference Deployment. Either is nil when it reported none yet.
+func (c *external) read_rollout_metrics(ctx context.Context, cr *v1alpha1.CtrlDrift) (candidate, stable map[string]string, err error) {
+	files := []string{inference_metrics_path(model_version(cr)), inference_metrics_path(cr.Status.AtProvider.ActiveModelVersion)}
+
+	if src := cr.Spec.ForProvider.DriftSource; src == nil || src.Type == v1alpha1.DriftSourceLocal {
+		reader := &driftsource.LocalReader{Dir: local_drift_path(cr)}
+		read := func(name string) (map[string]string, error) {
+			f, err := reader.Open(ctx, name)
+			if driftsource.IsNotFound(err) {
+				return nil, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close() //nolint:errcheck // Only read from.
+			return metrics.Parse(f)
+		}
+		if candidate, err = read(files[0]); err != nil {
+			return nil, nil, err
+		}
+		stable, err = read(files[1])
+		return candidate, stable, err
+	}
+
+	spec := cr.Spec.ForProvider.Inference
+	reader := &driftsource.PodReader{
+		Client:    c.clientset,
+		Pod:       get_reader_pod(cr, cr.Spec.ForProvider.DeployName+"-rollout-reader", "rollout-reader", spec),
+		MountPath: data_mount_path(spec),
+		Script:    rollout_metrics_script(files...),
+	}
+	f, err := reader.Open(ctx, ".")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close() //nolint:errcheck // Only read from.
+	all, err := metrics.ParseStream(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(all) != len(files) {
+		return nil, nil, errors.Errorf("expected %d metrics files, read %d", len(files), len(all))
+	}
+	return all[0], all[1], nil
+}
+
+// start_rollout records the start of the bake of the model produced by the
+// last run.
+func start_rollout(cr *v1alpha1.CtrlDrift, now metav1.Time) {
+	if r := cr.Status.AtProvider.Rollout; r != nil && r.ModelVersion == model_version(cr) {
+		return
+	}
+	cr.Status.AtProvider.Rollout = &v1alpha1.RolloutStatus{
+		ModelVersion: model_version(cr),
+		Strategy:     rollout_strategy(cr),
+		StartTime:    &now,
+	}
+}
+
+// judge_rollout compares the candidate with the inference Deployment once the
+// bake time elapsed and records the outcome in status.atProvider.rollout. The
+// verdict is unknown while the candidate bakes or its metrics are read. A
+// candidate that never became available or reported no metrics is rolled
+// back.
+func (c *external) judge_rollout(ctx context.Context, cr *v1alpha1.CtrlDrift, candidate *appsv1.Deployment, now metav1.Time) (pipeline.Verdict, error) {
+	r := cr.Status.AtProvider.Rollout
+	if r == nil || r.ModelVersion != model_version(cr) {
+		return pipeline.VerdictUnknown, nil
+	}
+	switch r.Outcome {
+	case v1alpha1.RolloutPromoted:
+		return pipeline.VerdictAccepted, nil
+	case v1alpha1.RolloutRolledBack:
+		return pipeline.VerdictRejected, nil
+	}
+	if r.StartTime != nil && now.Sub(r.StartTime.Time) < bake_time(cr) {
+		return pipeline.VerdictUnknown, nil
+	}
+
+	outcome, message := v1alpha1.RolloutRolledBack, ""
+	switch {
+	case candidate == nil || candidate.Status.AvailableReplicas == 0:
+		message = "candidate deployment never became available"
+	default:
+		candidate_metrics, stable_metrics, err := c.read_rollout_metrics(ctx, cr)
+		if driftsource.IsPending(err) {
+			c.logger.Debug(fmt.Sprintf("Rollout metrics of model %s not available yet: %s", r.ModelVersion, err))
+			return pipeline.VerdictUnknown, nil
+		}
+		if err != nil {
+			message = errors.Wrap(err, errReadRolloutMetrics).Error()
+			break
+		}
+		r.CandidateMetrics = candidate_metrics
+		r.StableMetrics = stable_metrics
+		if candidate_metrics == nil {
+			message = "candidate deployment reported no metrics"
+			break
+		}
+		var thresholds []v1alpha1.MetricThreshold
+		if p := cr.Spec.ForProvider.Rollout; p != nil {
+			thresholds = p.Thresholds
+		}
+		verdict, err := metrics.Check(thresholds, candidate_metrics, stable_metrics)
+		if err != nil {
+			return pipeline.VerdictUnknown, errors.Wrap(err, errCheckRolloutMetrics)
+		}
+		if verdict.Accepted {
+			outcome = v1alpha1.RolloutPromoted
+		}
+		message = strings.Join(verdict.Unmet, ", ")
+	}
+	r.Outcome = outcome
+	r.Message = message
+	r.CompletionTime = &now
+
+	if outcome == v1alpha1.RolloutRolledBack {
+		c.recorder.Event(cr, event.Warning(reasonModelRolledBack, errors.Errorf("model %s rolled back: %s", r.ModelVersion, message)))
+		return pipeline.VerdictRejected, nil
+	}
+	c.recorder.Event(cr, event.Normal(reasonModelPromoted, fmt.Sprintf("model %s promoted after baking for %s", r.ModelVersion, bake_time(cr))))
+	return pipeline.VerdictAccepted, nil
+}
@@ -0,0 +1,144 @@
+package ctrldrift
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+
+	"github.com/crossplane/provider-driftprovider/apis/mlops/v1alpha1"
+	"github.com/crossplane/provider-driftprovider/internal/retrain"
+)
+
+const (
+	// max_skipped_runs is the number of skipped runs kept in the status.
+	max_skipped_runs = 10
+
+	errEvaluateSchedule = "cannot evaluate retrain schedule"
+)
+
+// reasonScheduledRunSkipped is the reason of the event emitted for a
+// scheduled run that was not started.
+const reasonScheduledRunSkipped event.Reason = "ScheduledRunSkipped"
+
+// wakeups enqueue a CtrlDrift at the time its next scheduled run is due, so
+// that the run does not wait for the next poll.
+type wakeups struct {
+	mu      sync.Mutex
+	timers  map[string]*time.Timer
+	enqueue func(name string)
+}
+
+func new_wakeups(enqueue func(name string)) *wakeups {
+	return &wakeups{timers: map[string]*time.Timer{}, enqueue: enqueue}
+}
+
+// at replaces the wakeup of the named CtrlDrift. The zero time cancels it.
+func (w *wakeups) at(name string, t time.Time) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if timer, ok := w.timers[name]; ok {
+		timer.Stop()
+		delete(w.timers, name)
+	}
+	if t.IsZero() {
+		return
+	}
+	w.timers[name] = time.AfterFunc(time.Until(t), func() { w.enqueue(name) })
+}
+
+func (w *wakeups) cancel(name string) {
+	w.at(name, time.Time{})
+}
+
+// idle_phase returns true when the pipeline has no run in flight.
+func idle_phase(phase v1alpha1.Phase) bool {
+	switch phase {
+	case "", v1alpha1.PhaseMonitoring, v1alpha1.PhaseDrifting, v1alpha1.PhaseRejected:
+		return true
+	}
+	return false
+}
+
+// record_skipped_run adds a skipped run to the status, newest first. A run
+// that was already recorded for the same reason is not added again.
+func (c *external) record_skipped_run(cr *v1alpha1.CtrlDrift, skipped v1alpha1.SkippedRun) {
+	for _, s := range cr.Status.AtProvider.SkippedRuns {
+		if s.Reason == skipped.Reason && s.Time.Equal(skipped.Time) {
+			return
+		}
+	}
+	runs := append([]v1alpha1.SkippedRun{skipped}, cr.Status.AtProvider.SkippedRuns...)
+	if len(runs) > max_skipped_runs {
+		runs = runs[:max_skipped_runs]
+	}
+	cr.Status.AtProvider.SkippedRuns = runs
+	c.recorder.Event(cr, event.Normal(reasonScheduledRunSkipped, fmt.Sprintf("%s: %s", skipped.Reason, skipped.Message)))
+}
+
+// scheduled_run evaluates the retrain schedule and wakes the CtrlDrift up
+// when its next run is due. A due run is only returned while no other run is
+// in flight and the pipeline is not paused; otherwise it is recorded as
+// skipped.
+func (c *external) scheduled_run(cr *v1alpha1.CtrlDrift, now metav1.Time) (retrain.ScheduleDecision, error) {
+	o := retrain.ScheduleObservation{Created: cr.GetCreationTimestamp().Time}
+	if t := cr.Status.AtProvider.LastScheduleTime; t != nil {
+		o.LastSchedule = t.Time
+	}
+	if t := cr.Status.AtProvider.LastTrainingStartTime; t != nil {
+		o.LastRetrain = t.Time
+	}
+	d, err := retrain.EvaluateSchedule(cr.Spec.ForProvider.RetrainSchedule, o, now.Time)
+	if err != nil {
+		return retrain.ScheduleDecision{}, errors.Wrap(err, errEvaluateSchedule)
+	}
+
+	cr.Status.AtProvider.NextScheduleTime = nil
+	if !d.Next.IsZero() {
+		next := metav1.NewTime(d.Next)
+		cr.Status.AtProvider.NextScheduleTime = &next
+	}
+	c.wakeups.at(cr.GetName(), d.Next)
+	if !d.Due {
+		return d, nil
+	}
+
+	due := metav1.NewTime(d.Time)
+	if d.Missed > 0 {
+		c.record_skipped_run(cr, v1alpha1.SkippedRun{
+			Time:    &due,
+			Reason:  v1alpha1.SkipMissed,
+			Message: fmt.Sprintf("%d runs scheduled before %s were missed", d.Missed, d.Time.UTC().Format(time.RFC3339)),
+		})
+	}
+	reason, state := "", string(cr.Status.AtProvider.Phase)
+	switch {
+	case paused(cr):
+		reason, state = v1alpha1.SkipPaused, "paused"
+	case cr.Status.AtProvider.Phase == v1alpha1.PhaseFailed:
+		reason = v1alpha1.SkipFailed
+	case !idle_phase(cr.Status.AtProvider.Phase):
+		reason = v1alpha1.SkipOverlap
+	default:
+		return d, nil
+	}
+
+	//the interval is checked again once the pipeline is idle
+	if d.Reason == retrain.ReasonScheduled {
+		c.record_skipped_run(cr, v1alpha1.SkippedRun{
+			Time:    &due,
+			Reason:  reason,
+			Message: fmt.Sprintf("run scheduled at %s skipped, the pipeline is %s", d.Time.UTC().Format(time.RFC3339), state),
+		})
+		cr.Status.AtProvider.LastScheduleTime = &due
+	}
+	d.Due = false
+	return d, nil
+}
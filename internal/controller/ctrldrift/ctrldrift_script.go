@@ -0,0 +1,182 @@
+package ctrldrift
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplane/provider-driftprovider/apis/mlops/v1alpha1"
+	"github.com/crossplane/provider-driftprovider/internal/driftsource"
+)
+
+const (
+	script_volume_name = "training-script"
+	script_mount_path  = "/opt/driftprovider/script/"
+
+	errGetScriptConfigMap = "cannot get training script ConfigMap"
+	errGetScriptSecret    = "cannot get training script Secret"
+	errReadScriptVolume   = "cannot read training script from the data volume"
+	errScriptKeyMissing   = "training script key %q not found in %s %s/%s"
+	errScriptPath         = "training script %q must be a relative path within the data volume"
+)
+
+// check_training_script rejects a training script that is not a relative path
+// within the data volume.
+func check_training_script(cr *v1alpha1.CtrlDrift) error {
+	name := cr.Spec.ForProvider.TrainingScript
+	if path.IsAbs(name) {
+		return errors.Errorf(errScriptPath, name)
+	}
+	for _, e := range strings.Split(name, "/") {
+		if e == ".." {
+			return errors.Errorf(errScriptPath, name)
+		}
+	}
+	return nil
+}
+
+func training_script_key(cr *v1alpha1.CtrlDrift) string {
+	if src := cr.Spec.ForProvider.TrainingScriptSource; src != nil && src.Key != "" {
+		return src.Key
+	}
+	return cr.Spec.ForProvider.TrainingScript
+}
+
+// training_script_path is where the training container finds the script.
+func training_script_path(cr *v1alpha1.CtrlDrift) string {
+	src := cr.Spec.ForProvider.TrainingScriptSource
+	if src != nil && src.Type == v1alpha1.ScriptSourceVolume {
+		return path.Join(data_mount_path(cr.Spec.ForProvider.Training), cr.Spec.ForProvider.TrainingScript)
+	}
+	return path.Join(script_mount_path, path.Base(cr.Spec.ForProvider.TrainingScript))
+}
+
+// mount_training_script mounts the training script into the training pod and
+// makes it the entrypoint of the training container.
+func mount_training_script(cr *v1alpha1.CtrlDrift, pod *corev1.PodSpec) {
+	src := cr.Spec.ForProvider.TrainingScriptSource
+	if src == nil || len(pod.Containers) == 0 {
+		return
+	}
+	container := &pod.Containers[0]
+
+	items := []corev1.KeyToPath{{Key: training_script_key(cr), Path: path.Base(cr.Spec.ForProvider.TrainingScript)}}
+	switch src.Type {
+	case v1alpha1.ScriptSourceConfigMap:
+		pod.Volumes = append(pod.Volumes, corev1.Volume{
+			Name: script_volume_name,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: src.Name},
+					Items:                items,
+				},
+			},
+		})
+	case v1alpha1.ScriptSourceSecret:
+		pod.Volumes = append(pod.Volumes, corev1.Volume{
+			Name: script_volume_name,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: src.Name,
+					Items:      items,
+				},
+			},
+		})
+	case v1alpha1.ScriptSourceVolume:
+		// The script already lives on the data volume.
+	}
+	if src.Type != v1alpha1.ScriptSourceVolume {
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      script_volume_name,
+			MountPath: script_mount_path,
+			ReadOnly:  true,
+		})
+	}
+
+	command := src.Command
+	if len(command) == 0 {
+		command = []string{"python"}
+	}
+	container.Command = append(append([]string{}, command...), training_script_path(cr))
+}
+
+// resolve_training_script checks that the configured training script exists
+// and returns its revision, a digest of its content. It returns an empty
+// revision when no TrainingScriptSource is configured, and a pending error
+// while a script on the data volume is being read.
+func (c *external) resolve_training_script(ctx context.Context, cr *v1alpha1.CtrlDrift) (string, error) {
+	src := cr.Spec.ForProvider.TrainingScriptSource
+	if src == nil {
+		return "", nil
+	}
+	if err := check_training_script(cr); err != nil {
+		return "", err
+	}
+	key := training_script_key(cr)
+
+	var content []byte
+	switch src.Type {
+	case v1alpha1.ScriptSourceConfigMap:
+		cm := &corev1.ConfigMap{}
+		if err := c.reader.Get(ctx, types.NamespacedName{Namespace: deploy_namespace(cr), Name: src.Name}, cm); err != nil {
+			return "", errors.Wrap(err, errGetScriptConfigMap)
+		}
+		data, ok := cm.Data[key]
+		if !ok {
+			bin, ok := cm.BinaryData[key]
+			if !ok {
+				return "", errors.Errorf(errScriptKeyMissing, key, "ConfigMap", cm.Namespace, cm.Name)
+			}
+			data = string(bin)
+		}
+		content = []byte(data)
+	case v1alpha1.ScriptSourceSecret:
+		s := &corev1.Secret{}
+		if err := c.reader.Get(ctx, types.NamespacedName{Namespace: deploy_namespace(cr), Name: src.Name}, s); err != nil {
+			return "", errors.Wrap(err, errGetScriptSecret)
+		}
+		data, ok := s.Data[key]
+		if !ok {
+			return "", errors.Errorf(errScriptKeyMissing, key, "Secret", s.Namespace, s.Name)
+		}
+		content = data
+	case v1alpha1.ScriptSourceVolume:
+		data, err := c.read_training_script(ctx, cr)
+		if err != nil {
+			return "", errors.Wrap(err, errReadScriptVolume)
+		}
+		content = data
+	}
+
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(content)), nil
+}
+
+// read_training_script reads a training script from the data volume of the
+// training Job, the way read_dataset_manifest reads the manifest of a
+// snapshot.
+func (c *external) read_training_script(ctx context.Context, cr *v1alpha1.CtrlDrift) ([]byte, error) {
+	var reader driftsource.Reader
+	if src := cr.Spec.ForProvider.DriftSource; src == nil || src.Type == v1alpha1.DriftSourceLocal {
+		reader = &driftsource.LocalReader{Dir: local_drift_path(cr)}
+	} else {
+		spec := cr.Spec.ForProvider.Training
+		reader = &driftsource.PodReader{
+			Client:    c.clientset,
+			Pod:       get_reader_pod(cr, cr.Spec.ForProvider.DeployName+"-script-reader", "script-reader", spec),
+			MountPath: data_mount_path(spec),
+		}
+	}
+	f, err := reader.Open(ctx, cr.Spec.ForProvider.TrainingScript)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck // Only read from.
+	return io.ReadAll(f)
+}
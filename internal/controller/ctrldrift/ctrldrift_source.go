@@ -0,0 +1,297 @@
+package ctrldrift
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplane/provider-driftprovider/apis/mlops/v1alpha1"
+	"github.com/crossplane/provider-driftprovider/internal/driftevents"
+	"github.com/crossplane/provider-driftprovider/internal/driftsource"
+)
+
+const (
+	default_drift_file   = "drift_data.csv"
+	default_drift_path   = "/var/data/"
+	default_reader_image = "busybox:1.36"
+
+	s3_access_key_id_key     = "access_key_id"
+	s3_secret_access_key_key = "secret_access_key"
+
+	errNoBroker             = "no MQTT broker is configured"
+	errDriftSourceConfig    = "drift source of type %s has no %s configuration"
+	errGetS3Credentials     = "cannot get S3 credentials Secret"
+	errS3CredentialsMissing = "key %q not found in S3 credentials Secret %s/%s"
+)
+
+// drift_file is the drift data file, relative to the data volume. The
+// detector writes it, and the training Job snapshots and trains on it.
+func drift_file(cr *v1alpha1.CtrlDrift) string {
+	if src := cr.Spec.ForProvider.DriftSource; src != nil && src.File != nil && *src.File != "" {
+		return *src.File
+	}
+	return default_drift_file
+}
+
+// drift_output_name is the name the detector writes the drift data file
+// under, which it appends the extension to.
+func drift_output_name(cr *v1alpha1.CtrlDrift) string {
+	f := drift_file(cr)
+	return strings.TrimSuffix(f, path.Ext(f))
+}
+
+// local_drift_path is the directory of the provider's pod the drift data is
+// read from by a Local source.
+func local_drift_path(cr *v1alpha1.CtrlDrift) string {
+	if src := cr.Spec.ForProvider.DriftSource; src != nil && src.Local != nil && src.Local.Path != nil && *src.Local.Path != "" {
+		return *src.Local.Path
+	}
+	return default_drift_path
+}
+
+// mqtt_topic is the topic the detector publishes its drift statistics to for
+// an MQTT source.
+func mqtt_topic(cr *v1alpha1.CtrlDrift) string {
+	if src := cr.Spec.ForProvider.DriftSource; src != nil && src.MQTT != nil && src.MQTT.Topic != nil && *src.MQTT.Topic != "" {
+		return *src.MQTT.Topic
+	}
+	return fmt.Sprintf("driftprovider/%s/%s/drift", deploy_namespace(cr), cr.Spec.ForProvider.DeployName)
+}
+
+// mqtt_client_id identifies the provider to MQTT brokers. The pod name keeps
+// the client IDs of provider replicas apart.
+func mqtt_client_id() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return "provider-driftprovider"
+	}
+	return "provider-driftprovider-" + host
+}
+
+// drift_events_env tells the detector where to publish its drift statistics
+// when they are received over MQTT. Without DRIFT_EVENTS_BROKER they are
+// published to the broker of the ProviderConfig.
+func drift_events_env(cr *v1alpha1.CtrlDrift) []corev1.EnvVar {
+	src := cr.Spec.ForProvider.DriftSource
+	if src == nil || src.Type != v1alpha1.DriftSourceMQTT || src.MQTT == nil {
+		return nil
+	}
+	env := []corev1.EnvVar{{Name: "DRIFT_EVENTS_TOPIC", Value: mqtt_topic(cr)}}
+	if src.MQTT.Broker != nil && *src.MQTT.Broker != "" {
+		env = append(env, corev1.EnvVar{Name: "DRIFT_EVENTS_BROKER", Value: *src.MQTT.Broker})
+	}
+	return env
+}
+
+func drift_reader_pod_name(cr *v1alpha1.CtrlDrift) string {
+	return cr.Spec.ForProvider.DeployName + "-drift-reader"
+}
+
+// get_drift_reader_pod returns the pod a Pod source reads the drift data
+// through. It mounts the data volume of the detector and is scheduled like
+// the detector, so that a ReadWriteOnce volume can be mounted.
+func get_drift_reader_pod(cr *v1alpha1.CtrlDrift) *corev1.Pod {
+	return get_reader_pod(cr, drift_reader_pod_name(cr), "drift-reader", cr.Spec.ForProvider.Detector)
+}
+
+// get_reader_pod returns a pod that reads files of the data volume of the
+// supplied workload. It is scheduled like the workload.
+func get_reader_pod(cr *v1alpha1.CtrlDrift, name string, app string, spec *v1alpha1.WorkloadSpec) *corev1.Pod {
+	image := default_reader_image
+	if src := cr.Spec.ForProvider.DriftSource; src != nil && src.Pod != nil && src.Pod.Image != nil && *src.Pod.Image != "" {
+		image = *src.Pod.Image
+	}
+	mount := data_volume_mount(spec)
+	mount.ReadOnly = true
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       deploy_namespace(cr),
+			Labels:          object_labels(cr, app),
+			OwnerReferences: owner_references(cr),
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers: []corev1.Container{
+				{
+					Name:         app,
+					Image:        image,
+					VolumeMounts: []corev1.VolumeMount{mount},
+				},
+			},
+			Volumes: []corev1.Volume{
+				data_volume(spec),
+			},
+		},
+	}
+	if spec != nil {
+		pod.Spec.NodeSelector = spec.NodeSelector
+		pod.Spec.Tolerations = spec.Tolerations
+	}
+	return pod
+}
+
+// drift_source returns the source the drift data of the supplied CtrlDrift is
+// read from.
+func (c *external) drift_source(ctx context.Context, cr *v1alpha1.CtrlDrift) (driftsource.Source, error) {
+	src := cr.Spec.ForProvider.DriftSource
+	if src == nil {
+		src = &v1alpha1.DriftSource{Type: v1alpha1.DriftSourceLocal}
+	}
+	if src.Type != v1alpha1.DriftSourceMQTT && c.events != nil {
+		//stop receiving drift events after switching away from MQTT
+		c.events.Unsubscribe(cr.GetName())
+	}
+
+	switch src.Type {
+	case v1alpha1.DriftSourceS3:
+		if src.S3 == nil {
+			return nil, errors.Errorf(errDriftSourceConfig, src.Type, "s3")
+		}
+		reader := &driftsource.S3Reader{
+			Endpoint: src.S3.Endpoint,
+			Bucket:   src.S3.Bucket,
+			Prefix:   src.S3.Prefix,
+		}
+		if src.S3.Region != nil {
+			reader.Region = *src.S3.Region
+		}
+		if ref := src.S3.CredentialsSecretRef; ref != nil {
+			s := &corev1.Secret{}
+			if err := c.reader.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, s); err != nil {
+				return nil, errors.Wrap(err, errGetS3Credentials)
+			}
+			for _, key := range []string{s3_access_key_id_key, s3_secret_access_key_key} {
+				if _, ok := s.Data[key]; !ok {
+					return nil, errors.Errorf(errS3CredentialsMissing, key, s.Namespace, s.Name)
+				}
+			}
+			reader.Credentials = &driftsource.S3Credentials{
+				AccessKeyID:     string(s.Data[s3_access_key_id_key]),
+				SecretAccessKey: string(s.Data[s3_secret_access_key_key]),
+			}
+		}
+		return c.file_source(cr, reader), nil
+
+	case v1alpha1.DriftSourceHTTP:
+		if src.HTTP == nil {
+			return nil, errors.Errorf(errDriftSourceConfig, src.Type, "http")
+		}
+		return &driftsource.HTTPSource{URL: src.HTTP.URL}, nil
+
+	case v1alpha1.DriftSourceMQTT:
+		if src.MQTT == nil {
+			return nil, errors.Errorf(errDriftSourceConfig, src.Type, "mqtt")
+		}
+		if src.MQTT.Broker != nil && *src.MQTT.Broker != "" {
+			return c.events.Source(driftevents.Broker{URL: *src.MQTT.Broker}, mqtt_topic(cr), cr.GetName()), nil
+		}
+		if c.service == nil {
+			return nil, errors.New(errNoBroker)
+		}
+		broker, err := c.service.broker()
+		if err != nil {
+			return nil, err
+		}
+		return c.events.Source(broker, mqtt_topic(cr), cr.GetName()), nil
+
+	case v1alpha1.DriftSourcePod:
+		reader := &driftsource.PodReader{
+			Client:    c.clientset,
+			Pod:       get_drift_reader_pod(cr),
+			MountPath: data_mount_path(cr.Spec.ForProvider.Detector),
+		}
+		return c.file_source(cr, reader), nil
+
+	default:
+		return c.file_source(cr, &driftsource.LocalReader{Dir: local_drift_path(cr)}), nil
+	}
+}
+
+// drift_format is the format of the drift data file, inferred from its name
+// unless configured.
+func drift_format(cr *v1alpha1.CtrlDrift) driftsource.Format {
+	if src := cr.Spec.ForProvider.DriftSource; src != nil && src.Format != nil {
+		return driftsource.Format(*src.Format)
+	}
+	return driftsource.FormatOf(drift_file(cr))
+}
+
+// drift_header is true when the first line of a CSV drift data file names its
+// columns.
+func drift_header(cr *v1alpha1.CtrlDrift) bool {
+	if src := cr.Spec.ForProvider.DriftSource; src != nil && src.Header != nil {
+		return *src.Header
+	}
+	return true
+}
+
+// file_source returns the source reading the drift data file of the supplied
+// CtrlDrift through reader.
+func (c *external) file_source(cr *v1alpha1.CtrlDrift, reader driftsource.Reader) *driftsource.FileSource {
+	s := &driftsource.FileSource{Reader: reader, File: drift_file(cr), Header: drift_header(cr)}
+	src := cr.Spec.ForProvider.DriftSource
+	if src != nil {
+		if src.Format != nil {
+			s.Format = driftsource.Format(*src.Format)
+		}
+		if src.TimestampColumn != nil {
+			s.TimestampColumn = *src.TimestampColumn
+		}
+	}
+	//a cursor is only valid for the file it was moved through
+	key, _ := json.Marshal(src)
+	s.Cursor = c.cursors.get(cr.GetName(), string(key))
+	return s
+}
+
+// cursors remember how far the drift data file of every CtrlDrift was read,
+// so that a poll only reads the samples appended since the previous one.
+type cursors struct {
+	mu      sync.Mutex
+	cursors map[string]keyedCursor
+}
+
+type keyedCursor struct {
+	key    string
+	cursor *driftsource.Cursor
+}
+
+func new_cursors() *cursors {
+	return &cursors{cursors: map[string]keyedCursor{}}
+}
+
+// get returns the cursor of the named CtrlDrift. A new cursor is returned when
+// the drift data is read from another file than before.
+func (c *cursors) get(name string, key string) *driftsource.Cursor {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if kc, ok := c.cursors[name]; ok && kc.key == key {
+		return kc.cursor
+	}
+	kc := keyedCursor{key: key, cursor: &driftsource.Cursor{}}
+	c.cursors[name] = kc
+	return kc.cursor
+}
+
+func (c *cursors) forget(name string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.cursors, name)
+}
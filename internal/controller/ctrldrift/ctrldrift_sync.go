@@ -0,0 +1,172 @@
+package ctrldrift
+
+import (
+	"fmt"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+
+	"github.com/crossplane/provider-driftprovider/apis/mlops/v1alpha1"
+)
+
+// field_missing is reported for a desired Deployment that does not exist.
+const field_missing = "deployment"
+
+// deployment_diff returns the fields of a live Deployment that differ from the
+// desired one. Only the fields the provider sets are compared, so that the
+// fields defaulted by the API server are never reported, while edits of the
+// live object are.
+func deployment_diff(desired, live *appsv1.Deployment) []string {
+	diff := []string{}
+	differs := func(field string, equal bool) {
+		if !equal {
+			diff = append(diff, field)
+		}
+	}
+
+	differs("ownerReferences", has_owner_references(desired, live))
+	differs("replicas", replicas(desired) == replicas(live))
+
+	want, got := &desired.Spec.Template, &live.Spec.Template
+	for _, k := range sorted_keys(want.Labels) {
+		differs(fmt.Sprintf("labels[%s]", k), got.Labels[k] == want.Labels[k])
+	}
+	for _, k := range sorted_keys(want.Annotations) {
+		differs(fmt.Sprintf("annotations[%s]", k), got.Annotations[k] == want.Annotations[k])
+	}
+
+	diff = append(diff, containers_diff("initContainers", want.Spec.InitContainers, got.Spec.InitContainers)...)
+	diff = append(diff, containers_diff("containers", want.Spec.Containers, got.Spec.Containers)...)
+
+	differs("volumes", volumes_equal(want.Spec.Volumes, got.Spec.Volumes))
+	differs("nodeSelector", equality.Semantic.DeepEqual(want.Spec.NodeSelector, got.Spec.NodeSelector))
+	differs("tolerations", equality.Semantic.DeepEqual(want.Spec.Tolerations, got.Spec.Tolerations))
+	differs("imagePullSecrets", equality.Semantic.DeepEqual(want.Spec.ImagePullSecrets, got.Spec.ImagePullSecrets))
+
+	//a change of a field that is not compared still shows in the digest of the template
+	if len(diff) == 0 {
+		differs("template", desired.Annotations[annotationTemplateHash] == live.Annotations[annotationTemplateHash])
+	}
+	return diff
+}
+
+func sorted_keys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func replicas(d *appsv1.Deployment) int32 {
+	if d.Spec.Replicas == nil {
+		return 1
+	}
+	return *d.Spec.Replicas
+}
+
+// containers_diff compares the desired containers with the live ones by name.
+func containers_diff(kind string, desired, live []corev1.Container) []string {
+	diff := []string{}
+	if len(desired) != len(live) {
+		diff = append(diff, kind)
+	}
+	for i := range desired {
+		want := &desired[i]
+		var got *corev1.Container
+		for j := range live {
+			if live[j].Name == want.Name {
+				got = &live[j]
+			}
+		}
+		field := fmt.Sprintf("%s[%s]", kind, want.Name)
+		if got == nil {
+			diff = append(diff, field)
+			continue
+		}
+		for _, f := range []struct {
+			name  string
+			equal bool
+		}{
+			{"image", want.Image == got.Image},
+			{"imagePullPolicy", want.ImagePullPolicy == "" || want.ImagePullPolicy == got.ImagePullPolicy},
+			{"command", equality.Semantic.DeepEqual(want.Command, got.Command)},
+			{"args", equality.Semantic.DeepEqual(want.Args, got.Args)},
+			{"env", equality.Semantic.DeepEqual(normalise_env(want.Env), normalise_env(got.Env))},
+			{"envFrom", equality.Semantic.DeepEqual(want.EnvFrom, got.EnvFrom)},
+			{"resources", equality.Semantic.DeepEqual(want.Resources, got.Resources)},
+			{"volumeMounts", equality.Semantic.DeepEqual(want.VolumeMounts, got.VolumeMounts)},
+		} {
+			if !f.equal {
+				diff = append(diff, field+"."+f.name)
+			}
+		}
+	}
+	return diff
+}
+
+// normalise_env applies the defaults of the API server to a copy of the
+// supplied variables.
+func normalise_env(env []corev1.EnvVar) []corev1.EnvVar {
+	out := make([]corev1.EnvVar, len(env))
+	for i, e := range env {
+		out[i] = *e.DeepCopy()
+		if ref := out[i].ValueFrom; ref != nil && ref.FieldRef != nil && ref.FieldRef.APIVersion == "" {
+			ref.FieldRef.APIVersion = "v1"
+		}
+	}
+	return out
+}
+
+// volumes_equal compares the volumes by name and by what they mount. The
+// defaulted modes of Secret and ConfigMap volumes are ignored.
+func volumes_equal(desired, live []corev1.Volume) bool {
+	if len(desired) != len(live) {
+		return false
+	}
+	for _, want := range desired {
+		found := false
+		for _, got := range live {
+			if got.Name != want.Name {
+				continue
+			}
+			found = true
+			w, g := want.VolumeSource.DeepCopy(), got.VolumeSource.DeepCopy()
+			for _, src := range []*corev1.VolumeSource{w, g} {
+				if src.Secret != nil {
+					src.Secret.DefaultMode = nil
+				}
+				if src.ConfigMap != nil {
+					src.ConfigMap.DefaultMode = nil
+				}
+			}
+			if !equality.Semantic.DeepEqual(w, g) {
+				return false
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// out_of_sync compares the desired Deployments with the live ones.
+func out_of_sync(desired []*appsv1.Deployment, live []appsv1.Deployment) []v1alpha1.WorkloadDiff {
+	var diffs []v1alpha1.WorkloadDiff
+	for _, want := range desired {
+		fields := []string{field_missing}
+		for i := range live {
+			if live[i].Name == want.Name {
+				fields = deployment_diff(want, &live[i])
+			}
+		}
+		if len(fields) > 0 {
+			diffs = append(diffs, v1alpha1.WorkloadDiff{Name: want.Name, Fields: fields})
+		}
+	}
+	return diffs
+}
@@ -18,13 +18,47 @@ package ctrldrift
 
 import (
 	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	kfake "k8s.io/client-go/kubernetes/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ktesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
 	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
 	"github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
 	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/provider-driftprovider/apis"
+	"github.com/crossplane/provider-driftprovider/apis/mlops/v1alpha1"
+	apisv1alpha1 "github.com/crossplane/provider-driftprovider/apis/v1alpha1"
+	"github.com/crossplane/provider-driftprovider/internal/driftsource"
+	"github.com/crossplane/provider-driftprovider/internal/pipeline"
 )
 
 // Unlike many Kubernetes projects Crossplane does not use third party testing
@@ -35,9 +69,177 @@ import (
 // https://github.com/golang/go/wiki/TestComments
 // https://github.com/crossplane/crossplane/blob/master/CONTRIBUTING.md#contributing-code
 
+type ctrlDriftModifier func(cr *v1alpha1.CtrlDrift)
+
+func withName(name string) ctrlDriftModifier {
+	return func(cr *v1alpha1.CtrlDrift) { cr.SetName(name) }
+}
+
+func withUID(uid types.UID) ctrlDriftModifier {
+	return func(cr *v1alpha1.CtrlDrift) { cr.SetUID(uid) }
+}
+
+func withGeneration(g int64) ctrlDriftModifier {
+	return func(cr *v1alpha1.CtrlDrift) { cr.SetGeneration(g) }
+}
+
+func withAnnotations(a map[string]string) ctrlDriftModifier {
+	return func(cr *v1alpha1.CtrlDrift) { cr.SetAnnotations(a) }
+}
+
+func withDeletionTimestamp(t metav1.Time) ctrlDriftModifier {
+	return func(cr *v1alpha1.CtrlDrift) { cr.SetDeletionTimestamp(&t) }
+}
+
+func withDeletionPolicy(p xpv1.DeletionPolicy) ctrlDriftModifier {
+	return func(cr *v1alpha1.CtrlDrift) { cr.SetDeletionPolicy(p) }
+}
+
+func withDeployNamespace(namespace string) ctrlDriftModifier {
+	return func(cr *v1alpha1.CtrlDrift) { cr.Spec.ForProvider.DeployNamespace = namespace }
+}
+
+func withDriftSource(src *v1alpha1.DriftSource) ctrlDriftModifier {
+	return func(cr *v1alpha1.CtrlDrift) { cr.Spec.ForProvider.DriftSource = src }
+}
+
+// withLocalDriftPath reads the drift data from the supplied directory.
+func withLocalDriftPath(path string) ctrlDriftModifier {
+	return withDriftSource(&v1alpha1.DriftSource{Type: v1alpha1.DriftSourceLocal, Local: &v1alpha1.LocalDriftSource{Path: &path}})
+}
+
+// withDriftFile reads the drift data from the supplied file, which has a
+// header unless header is false.
+func withDriftFile(file string, header *bool) ctrlDriftModifier {
+	return func(cr *v1alpha1.CtrlDrift) {
+		if cr.Spec.ForProvider.DriftSource == nil {
+			cr.Spec.ForProvider.DriftSource = &v1alpha1.DriftSource{Type: v1alpha1.DriftSourceLocal}
+		}
+		cr.Spec.ForProvider.DriftSource.File = &file
+		cr.Spec.ForProvider.DriftSource.Header = header
+	}
+}
+
+func withTrainingScript(name string, src *v1alpha1.TrainingScriptSource) ctrlDriftModifier {
+	return func(cr *v1alpha1.CtrlDrift) {
+		cr.Spec.ForProvider.TrainingScript = name
+		cr.Spec.ForProvider.TrainingScriptSource = src
+	}
+}
+
+func withModelVersion(pin string) ctrlDriftModifier {
+	return func(cr *v1alpha1.CtrlDrift) { cr.Spec.ForProvider.ModelVersion = &pin }
+}
+
+func withRetrainPolicy(p *v1alpha1.RetrainPolicy) ctrlDriftModifier {
+	return func(cr *v1alpha1.CtrlDrift) { cr.Spec.ForProvider.RetrainPolicy = p }
+}
+
+func withRetrainSchedule(s *v1alpha1.RetrainSchedule) ctrlDriftModifier {
+	return func(cr *v1alpha1.CtrlDrift) { cr.Spec.ForProvider.RetrainSchedule = s }
+}
+
+func withRetryPolicy(p *v1alpha1.RetryPolicy) ctrlDriftModifier {
+	return func(cr *v1alpha1.CtrlDrift) { cr.Spec.ForProvider.RetryPolicy = p }
+}
+
+func withEvaluation(e *v1alpha1.Evaluation) ctrlDriftModifier {
+	return func(cr *v1alpha1.CtrlDrift) { cr.Spec.ForProvider.Evaluation = e }
+}
+
+func withRollout(p *v1alpha1.RolloutPolicy) ctrlDriftModifier {
+	return func(cr *v1alpha1.CtrlDrift) { cr.Spec.ForProvider.Rollout = p }
+}
+
+func withDatasetSnapshots(p *v1alpha1.DatasetSnapshotPolicy) ctrlDriftModifier {
+	return func(cr *v1alpha1.CtrlDrift) { cr.Spec.ForProvider.DatasetSnapshots = p }
+}
+
+func withProviderConfigReference(name string) ctrlDriftModifier {
+	return func(cr *v1alpha1.CtrlDrift) { cr.SetProviderConfigReference(&xpv1.Reference{Name: name}) }
+}
+
+func withInference(spec *v1alpha1.WorkloadSpec) ctrlDriftModifier {
+	return func(cr *v1alpha1.CtrlDrift) { cr.Spec.ForProvider.Inference = spec }
+}
+
+func withJobHistory(h *v1alpha1.JobHistory) ctrlDriftModifier {
+	return func(cr *v1alpha1.CtrlDrift) { cr.Spec.ForProvider.JobHistory = h }
+}
+
+// withAtProvider sets a copy of the supplied observation, so that cases
+// sharing it do not see the changes of each other.
+func withAtProvider(o v1alpha1.CtrlDriftObservation) ctrlDriftModifier {
+	return func(cr *v1alpha1.CtrlDrift) { o.DeepCopyInto(&cr.Status.AtProvider) }
+}
+
+// ctrlDrift returns a CtrlDrift named regression that deploys its workloads
+// as regression to the default namespace, with the supplied modifiers
+// applied.
+func ctrlDrift(m ...ctrlDriftModifier) *v1alpha1.CtrlDrift {
+	cr := &v1alpha1.CtrlDrift{
+		ObjectMeta: metav1.ObjectMeta{Name: "regression"},
+		Spec: v1alpha1.CtrlDriftSpec{ForProvider: v1alpha1.CtrlDriftParameters{
+			DeployName:      "regression",
+			DeployNamespace: "default",
+		}},
+	}
+	for _, f := range m {
+		f(cr)
+	}
+	return cr
+}
+
 func TestObserve(t *testing.T) {
+	errForbidden := kerrors.NewForbidden(schema.GroupResource{Group: "apps", Resource: "deployments"}, "", errors.New("RBAC"))
+
+	now := metav1.Now()
+	min := int64(10)
+	policy := &v1alpha1.RetrainPolicy{MinDriftedSamples: &min}
+
+	//driftData returns the directory of a drift data file with the supplied samples
+	driftData := func(samples ...string) string {
+		dir := t.TempDir()
+		data := strings.Join(append([]string{"x,y"}, samples...), "\n") + "\n"
+		if err := os.WriteFile(filepath.Join(dir, "drift_data.csv"), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return dir
+	}
+
+	training := batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "regression-training-20240501-120000", Labels: map[string]string{labelStep: step_training}}}
+	failed := batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "regression-training-20240501-120000-3",
+			UID:         "failed",
+			Labels:      map[string]string{labelStep: step_training},
+			Annotations: map[string]string{annotationGeneration: "1"},
+		},
+		Status: batchv1.JobStatus{Failed: 1},
+	}
+	//failedRun has used up the retries of its training job
+	failedRun := withAtProvider(v1alpha1.CtrlDriftObservation{
+		Phase:       v1alpha1.PhaseFailed,
+		Run:         "20240501-120000",
+		Retries:     4,
+		LastFailure: &v1alpha1.JobFailure{Job: failed.Name, UID: "failed", Attempt: 4},
+	})
+	errBoom := errors.New("boom")
+	workloads := func(jobs ...batchv1.Job) client.Client {
+		return &test.MockClient{MockList: func(_ context.Context, obj client.ObjectList, _ ...client.ListOption) error {
+			switch l := obj.(type) {
+			case *appsv1.DeploymentList:
+				l.Items = []appsv1.Deployment{{ObjectMeta: metav1.ObjectMeta{Name: "regression-drift-deploy"}}}
+			case *batchv1.JobList:
+				l.Items = jobs
+			}
+			return nil
+		}}
+	}
+
 	type fields struct {
-		service interface{}
+		service *brokerService
+		kube    client.Client
 	}
 
 	type args struct {
@@ -48,6 +250,9 @@ func TestObserve(t *testing.T) {
 	type want struct {
 		o   managed.ExternalObservation
 		err error
+
+		//status holds the phase, counters and children expected in status
+		status *v1alpha1.CtrlDriftObservation
 	}
 
 	cases := map[string]struct {
@@ -56,12 +261,182 @@ func TestObserve(t *testing.T) {
 		args   args
 		want   want
 	}{
-		// TODO: Add test cases.
+		"NotCtrlDrift": {
+			reason: "We should return an error if the managed resource is not a CtrlDrift.",
+			fields: fields{kube: &test.MockClient{}},
+			args: args{
+				ctx: context.Background(),
+				mg:  &fake.Managed{},
+			},
+			want: want{err: errors.New(errNotCtrlDrift)},
+		},
+		"ListDeploymentsForbidden": {
+			reason: "We should return an error instead of carrying on when the deployments cannot be listed.",
+			fields: fields{kube: &test.MockClient{MockList: test.NewMockListFn(errForbidden)}},
+			args: args{
+				ctx: context.Background(),
+				mg:  ctrlDrift(),
+			},
+			want: want{err: errors.Wrap(errForbidden, errListDeployments)},
+		},
+		"DeletingWorkloadsRemain": {
+			reason: "A deleted CtrlDrift should be reported as existing until its workloads are gone.",
+			fields: fields{kube: &test.MockClient{MockList: func(_ context.Context, obj client.ObjectList, _ ...client.ListOption) error {
+				if l, ok := obj.(*batchv1.JobList); ok {
+					l.Items = []batchv1.Job{{ObjectMeta: metav1.ObjectMeta{Name: "regression-training-job"}}}
+				}
+				return nil
+			}}},
+			args: args{
+				ctx: context.Background(),
+				mg:  ctrlDrift(withDeletionTimestamp(now)),
+			},
+			want: want{o: managed.ExternalObservation{
+				ResourceExists:    true,
+				ResourceUpToDate:  true,
+				ConnectionDetails: managed.ConnectionDetails{},
+			}},
+		},
+		"DeletingWorkloadsGone": {
+			reason: "A deleted CtrlDrift should be reported as gone once its workloads are.",
+			fields: fields{kube: &test.MockClient{MockList: test.NewMockListFn(nil)}},
+			args: args{
+				ctx: context.Background(),
+				mg:  ctrlDrift(withDeletionTimestamp(now)),
+			},
+			want: want{o: managed.ExternalObservation{
+				ResourceExists:    false,
+				ResourceUpToDate:  true,
+				ConnectionDetails: managed.ConnectionDetails{},
+			}},
+		},
+		"NoWorkloads": {
+			reason: "A CtrlDrift without workloads should be reported as not existing.",
+			fields: fields{kube: &test.MockClient{MockList: test.NewMockListFn(nil)}},
+			args: args{
+				ctx: context.Background(),
+				mg:  ctrlDrift(),
+			},
+			want: want{o: managed.ExternalObservation{
+				ResourceExists:    false,
+				ResourceUpToDate:  true,
+				ConnectionDetails: managed.ConnectionDetails{},
+			}},
+		},
+		"Monitoring": {
+			reason: "A CtrlDrift without drifted samples should be monitoring and report its deployments.",
+			fields: fields{kube: workloads()},
+			args: args{
+				ctx: context.Background(),
+				mg:  ctrlDrift(withLocalDriftPath(driftData()), withRetrainPolicy(policy)),
+			},
+			want: want{
+				o: managed.ExternalObservation{
+					ResourceExists:    true,
+					ResourceUpToDate:  false,
+					ConnectionDetails: managed.ConnectionDetails{},
+				},
+				status: &v1alpha1.CtrlDriftObservation{
+					Phase:       v1alpha1.PhaseMonitoring,
+					Drift:       "false",
+					Deployments: []string{"regression-drift-deploy"},
+				},
+			},
+		},
+		"Drifting": {
+			reason: "A CtrlDrift collecting drifted samples below the retrain threshold should be drifting and count them.",
+			fields: fields{kube: workloads()},
+			args: args{
+				ctx: context.Background(),
+				mg:  ctrlDrift(withLocalDriftPath(driftData("1,2", "3,4", "5,6")), withRetrainPolicy(policy)),
+			},
+			want: want{
+				o: managed.ExternalObservation{
+					ResourceExists:    true,
+					ResourceUpToDate:  false,
+					ConnectionDetails: managed.ConnectionDetails{},
+				},
+				status: &v1alpha1.CtrlDriftObservation{
+					Phase:          v1alpha1.PhaseDrifting,
+					DriftedSamples: 3,
+					Drift:          "true",
+					Deployments:    []string{"regression-drift-deploy"},
+				},
+			},
+		},
+		"Training": {
+			reason: "A CtrlDrift whose training job runs should be training and report the job.",
+			fields: fields{kube: workloads(training)},
+			args: args{
+				ctx: context.Background(),
+				mg:  ctrlDrift(withLocalDriftPath(driftData("1,2", "3,4", "5,6")), withRetrainPolicy(policy)),
+			},
+			want: want{
+				o: managed.ExternalObservation{
+					ResourceExists:    true,
+					ResourceUpToDate:  false,
+					ConnectionDetails: managed.ConnectionDetails{},
+				},
+				status: &v1alpha1.CtrlDriftObservation{
+					Phase:          v1alpha1.PhaseTraining,
+					DriftedSamples: 3,
+					Drift:          "true",
+					Deployments:    []string{"regression-drift-deploy"},
+					Jobs:           []string{"regression-training-20240501-120000"},
+				},
+			},
+		},
+		"FailedKept": {
+			reason: "A failed run should be kept for inspection while the spec is unchanged.",
+			fields: fields{kube: workloads(failed)},
+			args: args{
+				ctx: context.Background(),
+				mg:  ctrlDrift(withGeneration(1), withLocalDriftPath(driftData()), withRetrainPolicy(policy), failedRun),
+			},
+			want: want{
+				o: managed.ExternalObservation{
+					ResourceExists:    true,
+					ResourceUpToDate:  false,
+					ConnectionDetails: managed.ConnectionDetails{},
+				},
+				status: &v1alpha1.CtrlDriftObservation{
+					Phase:       v1alpha1.PhaseFailed,
+					Drift:       "false",
+					Deployments: []string{"regression-drift-deploy"},
+					Jobs:        []string{failed.Name},
+				},
+			},
+		},
+		"FailedSpecChanged": {
+			reason: "A failed run should be retired and a new one started once the spec changed.",
+			fields: fields{kube: &test.MockClient{
+				MockList:   workloads(failed).(*test.MockClient).MockList,
+				MockPatch:  test.NewMockPatchFn(nil),
+				MockCreate: test.NewMockCreateFn(errBoom),
+			}},
+			args: args{
+				ctx: context.Background(),
+				mg:  ctrlDrift(withGeneration(2), withLocalDriftPath(driftData()), withRetrainPolicy(policy), failedRun),
+			},
+			want: want{
+				err: errors.Wrap(errBoom, errCreateJob),
+				status: &v1alpha1.CtrlDriftObservation{
+					Phase:       v1alpha1.PhaseTraining,
+					Drift:       "false",
+					Deployments: []string{"regression-drift-deploy"},
+				},
+			},
+		},
 	}
 
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
-			e := external{service: tc.fields.service}
+			e := external{
+				service:  tc.fields.service,
+				kube:     tc.fields.kube,
+				logger:   logging.NewNopLogger(),
+				recorder: event.NewNopRecorder(),
+			}
 			got, err := e.Observe(tc.args.ctx, tc.args.mg)
 			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
 				t.Errorf("\n%s\ne.Observe(...): -want error, +got error:\n%s\n", tc.reason, diff)
@@ -69,6 +444,2386 @@ func TestObserve(t *testing.T) {
 			if diff := cmp.Diff(tc.want.o, got); diff != "" {
 				t.Errorf("\n%s\ne.Observe(...): -want, +got:\n%s\n", tc.reason, diff)
 			}
+			if tc.want.status == nil {
+				return
+			}
+			at := tc.args.mg.(*v1alpha1.CtrlDrift).Status.AtProvider
+			status := &v1alpha1.CtrlDriftObservation{
+				Phase:          at.Phase,
+				DriftedSamples: at.DriftedSamples,
+				Drift:          at.Drift,
+				Deployments:    at.Deployments,
+				Jobs:           at.Jobs,
+			}
+			if diff := cmp.Diff(tc.want.status, status); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want status, +got status:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	errForbidden := kerrors.NewForbidden(schema.GroupResource{Group: "apps", Resource: "deployments"}, "", errors.New("RBAC"))
+
+	cr := ctrlDrift(withAtProvider(v1alpha1.CtrlDriftObservation{ActiveModelVersion: "20240501-123000"}))
+	desired := map[string]*appsv1.Deployment{}
+	for _, d := range (&external{}).deployments(cr) {
+		desired[d.Name] = d
+	}
+
+	// live returns the deployments with the supplied names as they were
+	// created for the previous model, and the others as desired.
+	live := func(outdated ...string) test.MockGetFn {
+		return func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+			d := desired[key.Name].DeepCopy()
+			for _, name := range outdated {
+				if name == key.Name {
+					d.Annotations[annotationTemplateHash] = "outdated"
+				}
+			}
+			*obj.(*appsv1.Deployment) = *d
+			return nil
+		}
+	}
+
+	type want struct {
+		patched []string
+		created []string
+		err     error
+	}
+
+	cases := map[string]struct {
+		reason string
+		get    test.MockGetFn
+		want   want
+	}{
+		"Unchanged": {
+			reason: "Deployments whose pod template did not change should not be touched.",
+			get:    live(),
+			want:   want{},
+		},
+		"TemplateChanged": {
+			reason: "Only the deployment whose pod template changed should be patched.",
+			get:    live("regression-python-tflite-deploy"),
+			want:   want{patched: []string{"regression-python-tflite-deploy"}},
+		},
+		"Edited": {
+			reason: "A deployment edited by hand should be patched back.",
+			get: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+				_ = live()(ctx, key, obj)
+				if key.Name == "regression-drift-deploy" {
+					obj.(*appsv1.Deployment).Spec.Template.Spec.Containers[0].Image = "example/detector:debug"
+				}
+				return nil
+			},
+			want: want{patched: []string{"regression-drift-deploy"}},
+		},
+		"Missing": {
+			reason: "A deployment that does not exist should be created.",
+			get:    test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{Group: "apps", Resource: "deployments"}, "")),
+			want:   want{created: []string{"regression-drift-deploy", "regression-python-tflite-deploy"}},
+		},
+		"GetForbidden": {
+			reason: "We should return an error when a deployment cannot be read.",
+			get:    test.NewMockGetFn(errForbidden),
+			want:   want{err: errors.Wrap(errForbidden, errGetDeployment)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var patched, created []string
+			kube := &test.MockClient{
+				MockGet: tc.get,
+				MockPatch: func(_ context.Context, obj client.Object, _ client.Patch, _ ...client.PatchOption) error {
+					d := obj.(*appsv1.Deployment)
+					if diff := cmp.Diff(desired[d.Name].Spec.Template, d.Spec.Template); diff != "" {
+						t.Errorf("\n%s\nPatch(%s): -want template, +got template:\n%s\n", tc.reason, d.Name, diff)
+					}
+					patched = append(patched, d.Name)
+					return nil
+				},
+				MockCreate: func(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+					created = append(created, obj.GetName())
+					return nil
+				},
+			}
+			e := external{kube: kube, logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+			_, err := e.Update(context.Background(), cr.DeepCopy())
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.Update(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.patched, patched); diff != "" {
+				t.Errorf("\n%s\ne.Update(...): -want patched, +got patched:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.created, created); diff != "" {
+				t.Errorf("\n%s\ne.Update(...): -want created, +got created:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestContainerImage(t *testing.T) {
+	str := func(s string) *string { return &s }
+	digest := "sha256:0123456789abcdef0123456789abcdef"
+
+	cases := map[string]struct {
+		reason string
+		spec   *v1alpha1.WorkloadSpec
+		want   string
+	}{
+		"NoSpec": {
+			reason: "Without a spec the default image should be used with the default tag.",
+			want:   "lucaserf/drift_detection:latest",
+		},
+		"Empty": {
+			reason: "Empty fields should fall back to the defaults.",
+			spec:   &v1alpha1.WorkloadSpec{Image: str(""), Tag: str(""), Digest: str("")},
+			want:   "lucaserf/drift_detection:latest",
+		},
+		"Tag": {
+			reason: "The configured image and tag should be used.",
+			spec:   &v1alpha1.WorkloadSpec{Image: str("registry.local/drift"), Tag: str("v2")},
+			want:   "registry.local/drift:v2",
+		},
+		"DigestOverTag": {
+			reason: "A digest should take precedence over a tag.",
+			spec:   &v1alpha1.WorkloadSpec{Tag: str("v2"), Digest: str(digest)},
+			want:   "lucaserf/drift_detection@" + digest,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := container_image(tc.spec, "lucaserf/drift_detection")
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\ncontainer_image(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestMergeEnv(t *testing.T) {
+	defaults := []corev1.EnvVar{{Name: "DATA_DIR", Value: "/var/data/"}, {Name: "MODEL", Value: "regression"}}
+
+	cases := map[string]struct {
+		reason    string
+		overrides []corev1.EnvVar
+		want      []corev1.EnvVar
+	}{
+		"NoOverrides": {
+			reason: "Without overrides the defaults should be kept.",
+			want:   defaults,
+		},
+		"Replace": {
+			reason:    "An override should replace the default of the same name in place.",
+			overrides: []corev1.EnvVar{{Name: "DATA_DIR", Value: "/mnt/data/"}},
+			want:      []corev1.EnvVar{{Name: "DATA_DIR", Value: "/mnt/data/"}, {Name: "MODEL", Value: "regression"}},
+		},
+		"Append": {
+			reason:    "Overrides without a default should be appended in their order.",
+			overrides: []corev1.EnvVar{{Name: "LOG_LEVEL", Value: "debug"}, {Name: "EPOCHS", Value: "5"}},
+			want:      []corev1.EnvVar{{Name: "DATA_DIR", Value: "/var/data/"}, {Name: "MODEL", Value: "regression"}, {Name: "LOG_LEVEL", Value: "debug"}, {Name: "EPOCHS", Value: "5"}},
+		},
+		"LastOverrideWins": {
+			reason:    "The last of several overrides of the same name should win.",
+			overrides: []corev1.EnvVar{{Name: "MODEL", Value: "first"}, {Name: "EPOCHS", Value: "5"}, {Name: "MODEL", Value: "second"}, {Name: "EPOCHS", Value: "10"}},
+			want:      []corev1.EnvVar{{Name: "DATA_DIR", Value: "/var/data/"}, {Name: "MODEL", Value: "second"}, {Name: "EPOCHS", Value: "10"}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			original := append([]corev1.EnvVar{}, defaults...)
+			got := merge_env(defaults, tc.overrides)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nmerge_env(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(original, defaults); diff != "" {
+				t.Errorf("\n%s\nmerge_env(...): the defaults should not be modified: -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestCustomisePod(t *testing.T) {
+	always := corev1.PullAlways
+	resources := corev1.ResourceRequirements{Claims: []corev1.ResourceClaim{{Name: "gpu"}}}
+	generated := func() *corev1.PodSpec {
+		return &corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "training", Env: []corev1.EnvVar{{Name: "DATA_DIR", Value: "/var/data/"}}},
+				{Name: "sidecar"},
+			},
+			Tolerations:      []corev1.Toleration{{Key: "dedicated", Value: "drift"}},
+			ImagePullSecrets: []corev1.LocalObjectReference{{Name: "default"}},
+		}
+	}
+
+	cases := map[string]struct {
+		reason string
+		spec   *v1alpha1.WorkloadSpec
+		pod    *corev1.PodSpec
+		want   *corev1.PodSpec
+	}{
+		"NoSpec": {
+			reason: "Without a spec the generated pod should be left alone.",
+			pod:    generated(),
+			want:   generated(),
+		},
+		"NoContainers": {
+			reason: "A pod without containers should be left alone.",
+			spec:   &v1alpha1.WorkloadSpec{NodeSelector: map[string]string{"gpu": "true"}},
+			pod:    &corev1.PodSpec{},
+			want:   &corev1.PodSpec{},
+		},
+		"Customised": {
+			reason: "The spec should customise the first container and the pod, adding to what was generated.",
+			spec: &v1alpha1.WorkloadSpec{
+				ImagePullPolicy:  &always,
+				Env:              []corev1.EnvVar{{Name: "DATA_DIR", Value: "/mnt/data/"}, {Name: "EPOCHS", Value: "5"}},
+				EnvFrom:          []corev1.EnvFromSource{{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "training"}}}},
+				Resources:        &resources,
+				NodeSelector:     map[string]string{"gpu": "true"},
+				Tolerations:      []corev1.Toleration{{Key: "gpu", Operator: corev1.TolerationOpExists}},
+				ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry"}},
+			},
+			pod: generated(),
+			want: &corev1.PodSpec{
+				Containers: []corev1.Container{
+					{
+						Name:            "training",
+						ImagePullPolicy: corev1.PullAlways,
+						Env:             []corev1.EnvVar{{Name: "DATA_DIR", Value: "/mnt/data/"}, {Name: "EPOCHS", Value: "5"}},
+						EnvFrom:         []corev1.EnvFromSource{{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "training"}}}},
+						Resources:       resources,
+					},
+					{Name: "sidecar"},
+				},
+				NodeSelector:     map[string]string{"gpu": "true"},
+				Tolerations:      []corev1.Toleration{{Key: "dedicated", Value: "drift"}, {Key: "gpu", Operator: corev1.TolerationOpExists}},
+				ImagePullSecrets: []corev1.LocalObjectReference{{Name: "default"}, {Name: "registry"}},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			customise_pod(tc.spec, tc.pod)
+			if diff := cmp.Diff(tc.want, tc.pod); diff != "" {
+				t.Errorf("\n%s\ncustomise_pod(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestDeploymentDiff(t *testing.T) {
+	cr := ctrlDrift(
+		withInference(&v1alpha1.WorkloadSpec{Env: []corev1.EnvVar{
+			{Name: "POD_NAME", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"}}},
+		}}),
+		withAtProvider(v1alpha1.CtrlDriftObservation{ActiveModelVersion: "20240501-123000"}),
+	)
+	service := &brokerService{host: "broker", port: 8883, tls: true, ca: []byte("ca"), revision: "1"}
+	desired := (&external{service: service}).deployments(cr)[1]
+
+	// live returns the desired deployment as the API server stores it, with
+	// the supplied edits applied.
+	live := func(edits ...func(d *appsv1.Deployment)) *appsv1.Deployment {
+		d := desired.DeepCopy()
+		mode := int32(0o644)
+		spec := &d.Spec.Template.Spec
+		spec.DNSPolicy = corev1.DNSClusterFirst
+		spec.RestartPolicy = corev1.RestartPolicyAlways
+		spec.Containers[0].TerminationMessagePath = corev1.TerminationMessagePathDefault
+		for i := range spec.Volumes {
+			if s := spec.Volumes[i].Secret; s != nil {
+				s.DefaultMode = &mode
+			}
+		}
+		for i := range spec.Containers[0].Env {
+			if ref := spec.Containers[0].Env[i].ValueFrom; ref != nil && ref.FieldRef != nil {
+				ref.FieldRef.APIVersion = "v1"
+			}
+		}
+		for _, edit := range edits {
+			edit(d)
+		}
+		return d
+	}
+
+	cases := map[string]struct {
+		reason string
+		live   *appsv1.Deployment
+		want   []string
+	}{
+		"InSync": {
+			reason: "Fields defaulted by the API server should not be reported.",
+			live:   live(),
+			want:   []string{},
+		},
+		"ImageEdited": {
+			reason: "An edited image should be reported.",
+			live:   live(func(d *appsv1.Deployment) { d.Spec.Template.Spec.Containers[0].Image = "example/inference:debug" }),
+			want:   []string{"containers[python-tflite].image"},
+		},
+		"EnvEdited": {
+			reason: "An edited variable should be reported.",
+			live: live(func(d *appsv1.Deployment) {
+				d.Spec.Template.Spec.Containers[0].Env = append(d.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{Name: "DEBUG", Value: "1"})
+			}),
+			want: []string{"containers[python-tflite].env"},
+		},
+		"Scaled": {
+			reason: "A scaled deployment should be reported.",
+			live:   live(func(d *appsv1.Deployment) { d.Spec.Replicas = int32Ptr(0) }),
+			want:   []string{"replicas"},
+		},
+		"OutdatedModel": {
+			reason: "A deployment serving another model should be reported.",
+			live: live(func(d *appsv1.Deployment) {
+				d.Spec.Template.Annotations[annotationModelVersion] = "20240401-090000"
+			}),
+			want: []string{"annotations[driftprovider.crossplane.io/model-version]"},
+		},
+		"VolumeRemoved": {
+			reason: "A removed volume should be reported.",
+			live: live(func(d *appsv1.Deployment) {
+				d.Spec.Template.Spec.Volumes = d.Spec.Template.Spec.Volumes[:1]
+			}),
+			want: []string{"volumes"},
+		},
+		"TemplateChanged": {
+			reason: "A changed template should be reported even if no compared field changed.",
+			live:   live(func(d *appsv1.Deployment) { d.Annotations[annotationTemplateHash] = "outdated" }),
+			want:   []string{"template"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := deployment_diff(desired, tc.live)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\ndeployment_diff(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestResolveTrainingScript(t *testing.T) {
+	errBoom := errors.New("boom")
+	script := []byte("print('training')\n")
+	revision := fmt.Sprintf("sha256:%x", sha256.Sum256(script))
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "scripts"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "scripts", "train.py"), script, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	local := &v1alpha1.DriftSource{Type: v1alpha1.DriftSourceLocal, Local: &v1alpha1.LocalDriftSource{Path: &dir}}
+	configmap := &v1alpha1.TrainingScriptSource{Type: v1alpha1.ScriptSourceConfigMap, Name: "scripts"}
+	secret := &v1alpha1.TrainingScriptSource{Type: v1alpha1.ScriptSourceSecret, Name: "scripts"}
+	volume := &v1alpha1.TrainingScriptSource{Type: v1alpha1.ScriptSourceVolume}
+	get := func(obj client.Object) test.MockGetFn {
+		return func(_ context.Context, _ client.ObjectKey, o client.Object) error {
+			switch o := o.(type) {
+			case *corev1.ConfigMap:
+				*o = *obj.(*corev1.ConfigMap)
+			case *corev1.Secret:
+				*o = *obj.(*corev1.Secret)
+			}
+			return nil
+		}
+	}
+	meta := metav1.ObjectMeta{Namespace: "default", Name: "scripts"}
+
+	type want struct {
+		revision string
+		err      error
+		pending  bool
+	}
+
+	cases := map[string]struct {
+		reason string
+		cr     *v1alpha1.CtrlDrift
+		kube   client.Client
+		want   want
+	}{
+		"NoSource": {
+			reason: "The image's own entrypoint should have no revision.",
+			cr:     ctrlDrift(withTrainingScript("train.py", nil)),
+			kube:   &test.MockClient{},
+		},
+		"ConfigMap": {
+			reason: "The revision should be the digest of the script in the ConfigMap.",
+			cr:     ctrlDrift(withTrainingScript("train.py", configmap)),
+			kube:   &test.MockClient{MockGet: get(&corev1.ConfigMap{ObjectMeta: meta, Data: map[string]string{"train.py": string(script)}})},
+			want:   want{revision: revision},
+		},
+		"ConfigMapBinaryData": {
+			reason: "A script in the binary data of a ConfigMap should be found.",
+			cr:     ctrlDrift(withTrainingScript("train.py", configmap)),
+			kube:   &test.MockClient{MockGet: get(&corev1.ConfigMap{ObjectMeta: meta, BinaryData: map[string][]byte{"train.py": script}})},
+			want:   want{revision: revision},
+		},
+		"ConfigMapKeyMissing": {
+			reason: "A ConfigMap without the script should be reported.",
+			cr:     ctrlDrift(withTrainingScript("train.py", configmap)),
+			kube:   &test.MockClient{MockGet: get(&corev1.ConfigMap{ObjectMeta: meta})},
+			want:   want{err: errors.Errorf(errScriptKeyMissing, "train.py", "ConfigMap", "default", "scripts")},
+		},
+		"ConfigMapGetError": {
+			reason: "We should return an error if the ConfigMap cannot be read.",
+			cr:     ctrlDrift(withTrainingScript("train.py", configmap)),
+			kube:   &test.MockClient{MockGet: test.NewMockGetFn(errBoom)},
+			want:   want{err: errors.Wrap(errBoom, errGetScriptConfigMap)},
+		},
+		"SecretKey": {
+			reason: "The revision should be the digest of the script under the configured key of the Secret.",
+			cr:     ctrlDrift(withTrainingScript("train.py", &v1alpha1.TrainingScriptSource{Type: v1alpha1.ScriptSourceSecret, Name: "scripts", Key: "main"})),
+			kube:   &test.MockClient{MockGet: get(&corev1.Secret{ObjectMeta: meta, Data: map[string][]byte{"main": script}})},
+			want:   want{revision: revision},
+		},
+		"SecretKeyMissing": {
+			reason: "A Secret without the script should be reported.",
+			cr:     ctrlDrift(withTrainingScript("train.py", secret)),
+			kube:   &test.MockClient{MockGet: get(&corev1.Secret{ObjectMeta: meta})},
+			want:   want{err: errors.Errorf(errScriptKeyMissing, "train.py", "Secret", "default", "scripts")},
+		},
+		"Volume": {
+			reason: "The revision should be the digest of the script on the data volume.",
+			cr:     ctrlDrift(withTrainingScript("scripts/train.py", volume), withDriftSource(local)),
+			kube:   &test.MockClient{},
+			want:   want{revision: revision},
+		},
+		"VolumeMissing": {
+			reason: "A script missing from the data volume should be reported.",
+			cr:     ctrlDrift(withTrainingScript("scripts/missing.py", volume), withDriftSource(local)),
+			kube:   &test.MockClient{},
+			want: want{err: errors.Wrap(driftsource.NotFound(&os.PathError{
+				Op:   "open",
+				Path: filepath.Join(dir, "scripts", "missing.py"),
+				Err:  errors.New("no such file or directory"),
+			}), errReadScriptVolume)},
+		},
+		"VolumeReaderPod": {
+			reason: "A script on a data volume the provider does not mount should be read by a reader pod.",
+			cr:     ctrlDrift(withTrainingScript("scripts/train.py", volume), withDriftSource(&v1alpha1.DriftSource{Type: v1alpha1.DriftSourceS3})),
+			kube:   &test.MockClient{},
+			want:   want{pending: true},
+		},
+		"AbsolutePath": {
+			reason: "A script outside the data volume should be rejected.",
+			cr:     ctrlDrift(withTrainingScript("/etc/passwd", volume), withDriftSource(local)),
+			kube:   &test.MockClient{},
+			want:   want{err: errors.Errorf(errScriptPath, "/etc/passwd")},
+		},
+		"ParentDirectory": {
+			reason: "A script that escapes the data volume should be rejected.",
+			cr:     ctrlDrift(withTrainingScript("scripts/../../train.py", volume), withDriftSource(local)),
+			kube:   &test.MockClient{},
+			want:   want{err: errors.Errorf(errScriptPath, "scripts/../../train.py")},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := external{kube: tc.kube, reader: tc.kube, clientset: kfake.NewSimpleClientset(), logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+			got, err := e.resolve_training_script(context.Background(), tc.cr)
+			if tc.want.pending {
+				if !driftsource.IsPending(err) {
+					t.Errorf("\n%s\ne.resolve_training_script(...): want pending error, got %v\n", tc.reason, err)
+				}
+				return
+			}
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.resolve_training_script(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.revision, got); diff != "" {
+				t.Errorf("\n%s\ne.resolve_training_script(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestMountTrainingScript(t *testing.T) {
+	pod := func() *corev1.PodSpec {
+		return &corev1.PodSpec{Containers: []corev1.Container{{Name: "training"}}}
+	}
+	mount := corev1.VolumeMount{Name: script_volume_name, MountPath: script_mount_path, ReadOnly: true}
+
+	cases := map[string]struct {
+		reason string
+		cr     *v1alpha1.CtrlDrift
+		want   *corev1.PodSpec
+	}{
+		"NoSource": {
+			reason: "The training container should keep the entrypoint of its image.",
+			cr:     ctrlDrift(withTrainingScript("train.py", nil)),
+			want:   pod(),
+		},
+		"ConfigMap": {
+			reason: "The script should be mounted from the ConfigMap and run with python.",
+			cr:     ctrlDrift(withTrainingScript("train.py", &v1alpha1.TrainingScriptSource{Type: v1alpha1.ScriptSourceConfigMap, Name: "scripts"})),
+			want: &corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name:         "training",
+					Command:      []string{"python", "/opt/driftprovider/script/train.py"},
+					VolumeMounts: []corev1.VolumeMount{mount},
+				}},
+				Volumes: []corev1.Volume{{Name: script_volume_name, VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "scripts"},
+					Items:                []corev1.KeyToPath{{Key: "train.py", Path: "train.py"}},
+				}}}},
+			},
+		},
+		"Secret": {
+			reason: "The script should be mounted from the configured key of the Secret and run with the configured command.",
+			cr: ctrlDrift(withTrainingScript("train.py", &v1alpha1.TrainingScriptSource{
+				Type:    v1alpha1.ScriptSourceSecret,
+				Name:    "scripts",
+				Key:     "main",
+				Command: []string{"python3", "-u"},
+			})),
+			want: &corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name:         "training",
+					Command:      []string{"python3", "-u", "/opt/driftprovider/script/train.py"},
+					VolumeMounts: []corev1.VolumeMount{mount},
+				}},
+				Volumes: []corev1.Volume{{Name: script_volume_name, VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+					SecretName: "scripts",
+					Items:      []corev1.KeyToPath{{Key: "main", Path: "train.py"}},
+				}}}},
+			},
+		},
+		"Volume": {
+			reason: "A script on the data volume should be run from where the volume is mounted.",
+			cr:     ctrlDrift(withTrainingScript("scripts/train.py", &v1alpha1.TrainingScriptSource{Type: v1alpha1.ScriptSourceVolume})),
+			want: &corev1.PodSpec{Containers: []corev1.Container{{
+				Name:    "training",
+				Command: []string{"python", path.Join(data_mount_path(nil), "scripts/train.py")},
+			}}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := pod()
+			mount_training_script(tc.cr, got)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nmount_training_script(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	errForbidden := kerrors.NewForbidden(schema.GroupResource{Group: "batch", Resource: "jobs"}, "", errors.New("RBAC"))
+
+	cr := ctrlDrift()
+
+	type want struct {
+		deleted []string
+		err     error
+	}
+
+	cases := map[string]struct {
+		reason string
+		err    error
+		want   want
+	}{
+		"AllWorkloads": {
+			reason: "Every workload of the CtrlDrift should be deleted, including the jobs of a run in flight and the history.",
+			want: want{deleted: []string{
+				"Deployment/regression-drift-deploy",
+				"Deployment/regression-python-tflite-deploy",
+				"Deployment/regression-python-tflite-candidate",
+				"Job/regression-training-20260101-000000",
+				"Job/regression-evaluation-20260101-000000",
+				"Job/regression-training-20260102-000000",
+				"Pod/regression-drift-reader",
+				"Pod/regression-model-reader",
+				"Pod/regression-evaluation-reader",
+				"Pod/regression-rollout-reader",
+				"Pod/regression-dataset-reader",
+				"Pod/regression-script-reader",
+				"Secret/regression-broker",
+			}},
+		},
+		"DeleteJobForbidden": {
+			reason: "We should return an error instead of ignoring a job that cannot be deleted.",
+			err:    errForbidden,
+			want: want{
+				deleted: []string{
+					"Deployment/regression-drift-deploy",
+					"Deployment/regression-python-tflite-deploy",
+					"Deployment/regression-python-tflite-candidate",
+				},
+				err: errors.Wrap(errForbidden, errDeleteJob),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var deleted []string
+			kube := &test.MockClient{
+				MockList: func(_ context.Context, obj client.ObjectList, _ ...client.ListOption) error {
+					jobs := obj.(*batchv1.JobList)
+					for _, name := range []string{"regression-training-20260101-000000", "regression-evaluation-20260101-000000", "regression-training-20260102-000000"} {
+						jobs.Items = append(jobs.Items, batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name}})
+					}
+					return nil
+				},
+				MockDelete: func(_ context.Context, obj client.Object, _ ...client.DeleteOption) error {
+					kind := strings.TrimPrefix(fmt.Sprintf("%T", obj), "*v1.")
+					if kind == "Job" && tc.err != nil {
+						return tc.err
+					}
+					deleted = append(deleted, kind+"/"+obj.GetName())
+					return kerrors.NewNotFound(schema.GroupResource{}, obj.GetName())
+				},
+			}
+			e := external{kube: kube, logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+			err := e.Delete(context.Background(), cr.DeepCopy())
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.Delete(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.deleted, deleted); diff != "" {
+				t.Errorf("\n%s\ne.Delete(...): -want deleted, +got deleted:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestOrphaningFinalizer(t *testing.T) {
+	now := metav1.Now()
+	owned := func(cr *v1alpha1.CtrlDrift) []metav1.OwnerReference {
+		return []metav1.OwnerReference{{UID: "other-uid"}, owner_reference(cr)}
+	}
+
+	cases := map[string]struct {
+		reason   string
+		cr       *v1alpha1.CtrlDrift
+		released []string
+	}{
+		"Orphan": {
+			reason:   "The workloads of a CtrlDrift with the Orphan deletion policy should be released before its finalizer is removed.",
+			cr:       ctrlDrift(withUID("ctrldrift-uid"), withDeletionPolicy(xpv1.DeletionOrphan), withDeletionTimestamp(now)),
+			released: []string{"regression-drift-deploy", "regression-training-job", "regression-broker"},
+		},
+		"Delete": {
+			reason: "The workloads of a CtrlDrift with the Delete deletion policy should be left to the garbage collector.",
+			cr:     ctrlDrift(withUID("ctrldrift-uid"), withDeletionPolicy(xpv1.DeletionDelete), withDeletionTimestamp(now)),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var released []string
+			removed := false
+			kube := &test.MockClient{
+				MockList: func(_ context.Context, obj client.ObjectList, _ ...client.ListOption) error {
+					switch l := obj.(type) {
+					case *appsv1.DeploymentList:
+						l.Items = []appsv1.Deployment{{ObjectMeta: metav1.ObjectMeta{Name: "regression-drift-deploy", OwnerReferences: owned(tc.cr)}}}
+					case *batchv1.JobList:
+						l.Items = []batchv1.Job{{ObjectMeta: metav1.ObjectMeta{Name: "regression-training-job", OwnerReferences: owned(tc.cr)}}}
+					}
+					return nil
+				},
+				MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+					obj.SetName("regression-broker")
+					obj.SetOwnerReferences(owned(tc.cr))
+					return nil
+				},
+				MockPatch: func(_ context.Context, obj client.Object, _ client.Patch, _ ...client.PatchOption) error {
+					if diff := cmp.Diff([]metav1.OwnerReference{{UID: "other-uid"}}, obj.GetOwnerReferences()); diff != "" {
+						t.Errorf("\n%s\nPatch(%s): -want owner references, +got owner references:\n%s\n", tc.reason, obj.GetName(), diff)
+					}
+					released = append(released, obj.GetName())
+					return nil
+				},
+			}
+			f := &orphaningFinalizer{
+				Finalizer: resource.FinalizerFns{RemoveFinalizerFn: func(_ context.Context, _ resource.Object) error {
+					removed = true
+					return nil
+				}},
+				kube:   kube,
+				logger: logging.NewNopLogger(),
+			}
+			if err := f.RemoveFinalizer(context.Background(), tc.cr); err != nil {
+				t.Fatalf("\n%s\nf.RemoveFinalizer(...): %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.released, released); diff != "" {
+				t.Errorf("\n%s\nf.RemoveFinalizer(...): -want released, +got released:\n%s\n", tc.reason, diff)
+			}
+			if !removed {
+				t.Errorf("\n%s\nf.RemoveFinalizer(...): finalizer was not removed", tc.reason)
+			}
+		})
+	}
+}
+
+func TestCtrlDriftRequests(t *testing.T) {
+	workload := func(labels map[string]string, owners ...metav1.OwnerReference) client.Object {
+		return &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "regression-drift-deploy", Namespace: "default", Labels: labels, OwnerReferences: owners}}
+	}
+	owner := owner_reference(ctrlDrift(withName("example"), withUID("ctrldrift-uid")))
+
+	cases := map[string]struct {
+		reason string
+		obj    client.Object
+		want   []reconcile.Request
+	}{
+		"OwnerLabel": {
+			reason: "A workload labelled with its CtrlDrift should enqueue that CtrlDrift.",
+			obj:    workload(map[string]string{labelDeployName: "regression", labelCtrlDrift: "example"}),
+			want:   []reconcile.Request{{NamespacedName: types.NamespacedName{Name: "example"}}},
+		},
+		"OwnerReference": {
+			reason: "A workload without the owner label should enqueue the CtrlDrift that owns it.",
+			obj:    workload(map[string]string{labelDeployName: "regression"}, metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "other"}, owner),
+			want:   []reconcile.Request{{NamespacedName: types.NamespacedName{Name: "example"}}},
+		},
+		"DeployName": {
+			reason: "A workload with neither the owner label nor an owner reference should not be mapped by its deploy name.",
+			obj:    workload(map[string]string{labelDeployName: "regression"}),
+		},
+		"Unlabelled": {
+			reason: "A workload that was not generated by the provider should not enqueue anything.",
+			obj:    workload(nil),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := ctrldrift_requests(tc.obj)
+			if diff := cmp.Diff(tc.want, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("\n%s\nctrldrift_requests(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
 		})
 	}
 }
+
+func TestNewBrokerService(t *testing.T) {
+	secrets := func(data map[string][]byte) test.MockGetFn {
+		return func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+			s := obj.(*corev1.Secret)
+			s.Namespace, s.Name = key.Namespace, key.Name
+			s.Data = data
+			return nil
+		}
+	}
+	pc := func(broker *apisv1alpha1.BrokerConfig) *apisv1alpha1.ProviderConfig {
+		return &apisv1alpha1.ProviderConfig{Spec: apisv1alpha1.ProviderConfigSpec{Broker: broker}}
+	}
+	ref := xpv1.SecretKeySelector{SecretReference: xpv1.SecretReference{Namespace: "crossplane-system", Name: "broker"}, Key: "password"}
+	username := "provider"
+	port := int32(1884)
+
+	type want struct {
+		b   *brokerService
+		err error
+	}
+
+	cases := map[string]struct {
+		reason string
+		reader client.Reader
+		pc     *apisv1alpha1.ProviderConfig
+		creds  []byte
+		want   want
+	}{
+		"Default": {
+			reason: "A ProviderConfig without a broker should use the default broker.",
+			reader: &test.MockClient{},
+			pc:     pc(nil),
+			want:   want{b: &brokerService{host: default_broker_host, port: default_broker_port}},
+		},
+		"Password": {
+			reason: "The password should be read from the referenced Secret.",
+			reader: &test.MockClient{MockGet: secrets(map[string][]byte{"password": []byte("secret")})},
+			pc:     pc(&apisv1alpha1.BrokerConfig{Host: "mosquitto", Port: &port, Username: &username, PasswordSecretRef: &ref}),
+			creds:  []byte("credentials"),
+			want:   want{b: &brokerService{host: "mosquitto", port: 1884, username: username, password: []byte("secret")}},
+		},
+		"CredentialsPassword": {
+			reason: "The ProviderConfig's credentials should be the password when no password is referenced.",
+			reader: &test.MockClient{},
+			pc:     pc(&apisv1alpha1.BrokerConfig{Host: "mosquitto", Username: &username}),
+			creds:  []byte("credentials"),
+			want:   want{b: &brokerService{host: "mosquitto", port: default_broker_port, username: username, password: []byte("credentials")}},
+		},
+		"TLS": {
+			reason: "A TLS broker should default to the TLS port and verify the host name.",
+			reader: &test.MockClient{},
+			pc:     pc(&apisv1alpha1.BrokerConfig{Host: "mosquitto", TLS: &apisv1alpha1.BrokerTLS{}}),
+			want:   want{b: &brokerService{host: "mosquitto", port: default_broker_tls_port, tls: true, server_name: "mosquitto"}},
+		},
+		"InvalidCA": {
+			reason: "A CA that is not PEM encoded should be rejected.",
+			reader: &test.MockClient{MockGet: secrets(map[string][]byte{"ca.crt": []byte("not a certificate")})},
+			pc: pc(&apisv1alpha1.BrokerConfig{Host: "mosquitto", TLS: &apisv1alpha1.BrokerTLS{
+				CASecretRef: &xpv1.SecretKeySelector{SecretReference: ref.SecretReference, Key: "ca.crt"},
+			}}),
+			want: want{err: errors.New(errBrokerCA)},
+		},
+		"MissingKey": {
+			reason: "A referenced Secret without the referenced key should be rejected.",
+			reader: &test.MockClient{MockGet: secrets(map[string][]byte{})},
+			pc:     pc(&apisv1alpha1.BrokerConfig{Host: "mosquitto", Username: &username, PasswordSecretRef: &ref}),
+			want:   want{err: errors.Errorf(errBrokerSecretKey, "password", "crossplane-system", "broker")},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := new_broker_service(context.Background(), tc.reader, tc.pc, tc.creds)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nnew_broker_service(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.b, got, cmp.AllowUnexported(brokerService{}), cmpopts.IgnoreFields(brokerService{}, "revision")); diff != "" {
+				t.Errorf("\n%s\nnew_broker_service(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestConnect(t *testing.T) {
+	username := "provider"
+	ref := xpv1.SecretKeySelector{SecretReference: xpv1.SecretReference{Namespace: "crossplane-system", Name: "broker"}, Key: "password"}
+	kube := &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+		switch o := obj.(type) {
+		case *apisv1alpha1.ProviderConfig:
+			o.Spec.Credentials.Source = xpv1.CredentialsSourceNone
+			o.Spec.Broker = &apisv1alpha1.BrokerConfig{Host: "mosquitto", Username: &username, PasswordSecretRef: &ref}
+		case *corev1.Secret:
+			o.Data = map[string][]byte{"password": []byte("secret")}
+		}
+		return nil
+	}}
+	c := &connector{
+		kube:         kube,
+		reader:       &test.MockClient{MockGet: test.NewMockGetFn(errors.New("uncached read"))},
+		usage:        resource.TrackerFn(func(context.Context, resource.Managed) error { return nil }),
+		logger:       logging.NewNopLogger(),
+		recorder:     event.NewNopRecorder(),
+		newServiceFn: new_broker_service,
+	}
+	cr := ctrlDrift(withProviderConfigReference("default"))
+
+	got, err := c.Connect(context.Background(), cr)
+	if err != nil {
+		t.Fatalf("c.Connect(...): the broker Secrets should be read from the cache: %s", err)
+	}
+	if diff := cmp.Diff([]byte("secret"), got.(*external).service.password); diff != "" {
+		t.Errorf("c.Connect(...): the broker password should be read from its Secret: -want, +got:\n%s\n", diff)
+	}
+}
+
+func TestBrokerInject(t *testing.T) {
+	cr := ctrlDrift()
+	b := &brokerService{host: "mosquitto", port: 8883, tls: true, username: "provider", password: []byte("secret"), ca: []byte("ca")}
+	spec := &v1alpha1.WorkloadSpec{Env: []corev1.EnvVar{{Name: "BROKER_PORT", Value: "18883"}}}
+	pod := &corev1.PodSpec{Containers: []corev1.Container{{Env: spec.Env}}}
+
+	b.inject(cr, spec, pod)
+
+	secret := func(key string) *corev1.EnvVarSource {
+		return &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "regression-broker"},
+			Key:                  key,
+		}}
+	}
+	want := &corev1.PodSpec{
+		Containers: []corev1.Container{{
+			Env: []corev1.EnvVar{
+				{Name: "BROKER_PORT", Value: "18883"},
+				{Name: "BROKER_ADDRESS", Value: "mosquitto"},
+				{Name: "BROKER_URL", Value: "ssl://mosquitto:8883"},
+				{Name: "BROKER_TLS", Value: "true"},
+				{Name: "BROKER_USERNAME", ValueFrom: secret("username")},
+				{Name: "BROKER_PASSWORD", ValueFrom: secret("password")},
+				{Name: "BROKER_CA_FILE", Value: "/etc/driftprovider/broker/ca.crt"},
+			},
+			VolumeMounts: []corev1.VolumeMount{{Name: broker_volume_name, MountPath: broker_mount_path, ReadOnly: true}},
+		}},
+		Volumes: []corev1.Volume{{
+			Name: broker_volume_name,
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+				SecretName: "regression-broker",
+				Items:      []corev1.KeyToPath{{Key: "ca.crt", Path: "ca.crt"}},
+			}},
+		}},
+	}
+	if diff := cmp.Diff(want, pod); diff != "" {
+		t.Errorf("inject(...): variables set by the workload spec should be kept, -want, +got:\n%s\n", diff)
+	}
+}
+
+func TestApplyBrokerSecret(t *testing.T) {
+	cr := ctrlDrift(withUID("0f1e"))
+	credentials := &brokerService{host: "mosquitto", username: "provider", password: []byte("secret")}
+	secret := func(password string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "regression-broker", OwnerReferences: owner_references(cr)},
+			Data:       map[string][]byte{broker_username_key: []byte("provider"), broker_password_key: []byte(password)},
+		}
+	}
+	errBoom := errors.New("boom")
+
+	cases := map[string]struct {
+		reason    string
+		service   *brokerService
+		existing  *corev1.Secret
+		createErr error
+		want      []string
+		wantErr   error
+	}{
+		"Missing": {
+			reason:  "A missing copy of the broker credentials should be created.",
+			service: credentials,
+			want:    []string{"create"},
+		},
+		"CacheLag": {
+			reason:    "A copy that the cache does not hold yet should not fail the reconcile.",
+			service:   credentials,
+			createErr: kerrors.NewAlreadyExists(schema.GroupResource{Resource: "secrets"}, "regression-broker"),
+			want:      []string{"create"},
+		},
+		"CreateFailed": {
+			reason:    "Errors creating the copy should be returned.",
+			service:   credentials,
+			createErr: errBoom,
+			want:      []string{"create"},
+			wantErr:   errors.Wrap(errBoom, errApplyBrokerSecret),
+		},
+		"Unchanged": {
+			reason:   "An unchanged copy should not be written.",
+			service:  credentials,
+			existing: secret("secret"),
+		},
+		"Changed": {
+			reason:   "A copy of rotated credentials should be updated.",
+			service:  credentials,
+			existing: secret("rotated"),
+			want:     []string{"update"},
+		},
+		"NoCredentials": {
+			reason:   "The copy should be deleted once the broker needs no credentials.",
+			service:  &brokerService{host: "mosquitto"},
+			existing: secret("secret"),
+			want:     []string{"delete"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var writes []string
+			kube := &test.MockClient{
+				MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+					if tc.existing == nil {
+						return kerrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, "regression-broker")
+					}
+					tc.existing.DeepCopyInto(obj.(*corev1.Secret))
+					return nil
+				},
+				MockCreate: func(context.Context, client.Object, ...client.CreateOption) error {
+					writes = append(writes, "create")
+					return tc.createErr
+				},
+				MockUpdate: func(context.Context, client.Object, ...client.UpdateOption) error {
+					writes = append(writes, "update")
+					return nil
+				},
+				MockDelete: func(context.Context, client.Object, ...client.DeleteOption) error {
+					writes = append(writes, "delete")
+					return nil
+				},
+			}
+			e := external{
+				kube:     kube,
+				reader:   &test.MockClient{MockGet: test.NewMockGetFn(errors.New("uncached read"))},
+				service:  tc.service,
+				logger:   logging.NewNopLogger(),
+				recorder: event.NewNopRecorder(),
+			}
+			err := e.apply_broker_secret(context.Background(), cr)
+			if diff := cmp.Diff(tc.wantErr, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.apply_broker_secret(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want, writes); diff != "" {
+				t.Errorf("\n%s\ne.apply_broker_secret(...): -want writes, +got writes:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRecordModelVersion(t *testing.T) {
+	started := metav1.NewTime(time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC))
+	model := []byte("tflite")
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "models", "20240501-123000"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "models", "20240501-123000", "model_regression.tflite"), model, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "models", "20240501-123000", "metrics.json"), []byte(`{"rmse": 0.12}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	trained := v1alpha1.CtrlDriftObservation{LastTrainingStartTime: &started, TrainingSamples: 3000}
+	notFound := kerrors.NewNotFound(schema.GroupResource{Group: v1alpha1.Group, Resource: "modelversions"}, "regression-20240501-123000")
+
+	cases := map[string]struct {
+		reason string
+		cr     *v1alpha1.CtrlDrift
+		get    error
+		want   *v1alpha1.ModelVersionParameters
+	}{
+		"Recorded": {
+			reason: "A new model should be recorded with its checksum and metrics.",
+			cr:     ctrlDrift(withLocalDriftPath(dir), withAtProvider(trained)),
+			get:    notFound,
+			want: &v1alpha1.ModelVersionParameters{
+				CtrlDrift:         "regression",
+				Version:           "20240501-123000",
+				ArtifactPath:      "models/20240501-123000/model_regression.tflite",
+				TrainedModelPath:  "models/20240501-123000/regression_model_tf.keras",
+				Checksum:          fmt.Sprintf("sha256:%x", sha256.Sum256(model)),
+				Dataset:           &v1alpha1.ModelDataset{Path: "drift_data.csv", Samples: 3000},
+				Metrics:           map[string]string{"rmse": "0.12"},
+				TrainingStartTime: &started,
+			},
+		},
+		"Unreadable": {
+			reason: "A model that cannot be read should be recorded without its checksum and metrics.",
+			cr:     ctrlDrift(withLocalDriftPath(t.TempDir()), withAtProvider(trained)),
+			get:    notFound,
+			want: &v1alpha1.ModelVersionParameters{
+				CtrlDrift:         "regression",
+				Version:           "20240501-123000",
+				ArtifactPath:      "models/20240501-123000/model_regression.tflite",
+				TrainedModelPath:  "models/20240501-123000/regression_model_tf.keras",
+				Dataset:           &v1alpha1.ModelDataset{Path: "drift_data.csv", Samples: 3000},
+				TrainingStartTime: &started,
+			},
+		},
+		"AlreadyRecorded": {
+			reason: "A model that was already recorded should be left alone.",
+			cr:     ctrlDrift(withLocalDriftPath(dir), withAtProvider(trained)),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got *v1alpha1.ModelVersionParameters
+			e := external{
+				kube: &test.MockClient{
+					MockGet: test.NewMockGetFn(tc.get),
+					MockCreate: func(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+						got = &obj.(*v1alpha1.ModelVersion).Spec.ForProvider
+						return nil
+					},
+				},
+				logger:   logging.NewNopLogger(),
+				recorder: event.NewNopRecorder(),
+			}
+			if err := e.record_model_version(context.Background(), tc.cr); err != nil {
+				t.Fatalf("\n%s\ne.record_model_version(...): %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\ne.record_model_version(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestParseModelManifest(t *testing.T) {
+	sum := strings.Repeat("ab", 32)
+
+	cases := map[string]struct {
+		reason  string
+		in      string
+		want    modelManifest
+		wantErr error
+	}{
+		"ChecksumAndMetrics": {
+			reason: "The checksum line should be followed by the metrics.",
+			in:     sum + "  /var/data/models/20240501-123000/model_regression.tflite\n{\"rmse\": 0.12}\n",
+			want:   modelManifest{checksum: "sha256:" + sum, metrics: map[string]string{"rmse": "0.12"}},
+		},
+		"NoMetrics": {
+			reason: "A model without metrics should only report its checksum.",
+			in:     sum + "  /var/data/models/20240501-123000/model_regression.tflite\n",
+			want:   modelManifest{checksum: "sha256:" + sum},
+		},
+		"NoChecksum": {
+			reason:  "Output without a checksum should be rejected.",
+			in:      "sha256sum: not found\n",
+			wantErr: errors.Wrap(errors.Errorf(errChecksumLine, "sha256sum: not found"), errModelManifest),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := parse_model_manifest(strings.NewReader(tc.in))
+			if diff := cmp.Diff(tc.wantErr, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nparse_model_manifest(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(modelManifest{})); diff != "" {
+				t.Errorf("\n%s\nparse_model_manifest(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestModelVersionName(t *testing.T) {
+	staging := model_version_name(ctrlDrift(withName("regression-staging"), withDeployNamespace("staging")), "20240501-123000")
+	production := model_version_name(ctrlDrift(withName("regression-production"), withDeployNamespace("production")), "20240501-123000")
+
+	if diff := cmp.Diff("regression-staging-20240501-123000", staging); diff != "" {
+		t.Errorf("model_version_name(...): a ModelVersion should be named after its CtrlDrift: -want, +got:\n%s\n", diff)
+	}
+	if staging == production {
+		t.Errorf("model_version_name(...): the same version of CtrlDrifts sharing a deploy name should not collide, got %q twice", staging)
+	}
+}
+
+func TestApplyModelPin(t *testing.T) {
+	errBoom := errors.New("boom")
+	notFound := kerrors.NewNotFound(schema.GroupResource{Group: v1alpha1.Group, Resource: "modelversions"}, "regression-20240501-123000")
+
+	recorded := func(owner, version, revision string) v1alpha1.ModelVersion {
+		return v1alpha1.ModelVersion{Spec: v1alpha1.ModelVersionSpec{ForProvider: v1alpha1.ModelVersionParameters{
+			CtrlDrift:              owner,
+			Version:                version,
+			TrainingScriptRevision: revision,
+		}}}
+	}
+	get := func(mv v1alpha1.ModelVersion) test.MockGetFn {
+		return func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+			*obj.(*v1alpha1.ModelVersion) = mv
+			return nil
+		}
+	}
+
+	cases := map[string]struct {
+		reason  string
+		cr      *v1alpha1.CtrlDrift
+		kube    client.Client
+		want    v1alpha1.CtrlDriftObservation
+		wantErr error
+	}{
+		"Unpinned": {
+			reason: "A CtrlDrift that was never pinned should be left alone.",
+			cr:     ctrlDrift(withAtProvider(v1alpha1.CtrlDriftObservation{ActiveModelVersion: "20240601-080000"})),
+			kube:   &test.MockClient{},
+			want:   v1alpha1.CtrlDriftObservation{ActiveModelVersion: "20240601-080000"},
+		},
+		"Pinned": {
+			reason: "A pinned model should become the active model.",
+			cr:     ctrlDrift(withModelVersion("20240501-123000"), withAtProvider(v1alpha1.CtrlDriftObservation{ActiveModelVersion: "20240601-080000"})),
+			kube:   &test.MockClient{MockGet: get(recorded("regression", "20240501-123000", "sha256:a"))},
+			want: v1alpha1.CtrlDriftObservation{
+				ActiveModelVersion:        "20240501-123000",
+				ActiveModelScriptRevision: "sha256:a",
+				PinnedModelVersion:        "20240501-123000",
+			},
+		},
+		"NotRecorded": {
+			reason:  "Pinning a model that was never recorded should be rejected.",
+			cr:      ctrlDrift(withModelVersion("20240501-123000")),
+			kube:    &test.MockClient{MockGet: test.NewMockGetFn(notFound)},
+			wantErr: errors.Wrapf(notFound, errModelNotRecorded, "20240501-123000", "regression"),
+		},
+		"OtherCtrlDrift": {
+			reason:  "Pinning a model recorded for another CtrlDrift should be rejected.",
+			cr:      ctrlDrift(withModelVersion("20240501-123000")),
+			kube:    &test.MockClient{MockGet: get(recorded("classification", "20240501-123000", ""))},
+			wantErr: errors.Wrapf(notFound, errModelNotRecorded, "20240501-123000", "regression"),
+		},
+		"GetError": {
+			reason:  "We should return an error if the ModelVersion cannot be read.",
+			cr:      ctrlDrift(withModelVersion("20240501-123000")),
+			kube:    &test.MockClient{MockGet: test.NewMockGetFn(errBoom)},
+			wantErr: errors.Wrap(errBoom, errGetModelVersion),
+		},
+		"PinRemoved": {
+			reason: "Removing the pin should make the newest recorded model active again.",
+			cr:     ctrlDrift(withAtProvider(v1alpha1.CtrlDriftObservation{ActiveModelVersion: "20240501-123000", PinnedModelVersion: "20240501-123000"})),
+			kube: &test.MockClient{MockList: func(_ context.Context, list client.ObjectList, _ ...client.ListOption) error {
+				list.(*v1alpha1.ModelVersionList).Items = []v1alpha1.ModelVersion{
+					recorded("regression", "20240501-123000", "sha256:a"),
+					recorded("regression", "20240701-100000", "sha256:c"),
+					recorded("regression", "20240601-080000", "sha256:b"),
+				}
+				return nil
+			}},
+			want: v1alpha1.CtrlDriftObservation{
+				ActiveModelVersion:        "20240701-100000",
+				ActiveModelScriptRevision: "sha256:c",
+				LatestModelVersion:        "20240701-100000",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := external{kube: tc.kube, logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+			err := e.apply_model_pin(context.Background(), tc.cr)
+			if diff := cmp.Diff(tc.wantErr, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.apply_model_pin(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if tc.wantErr != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.want, tc.cr.Status.AtProvider); diff != "" {
+				t.Errorf("\n%s\ne.apply_model_pin(...): -want status, +got status:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestPinnedRun(t *testing.T) {
+	pin, run, dir := "20240501-123000", "20240701-100000", t.TempDir()
+	started := metav1.NewTime(time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC))
+
+	cr := ctrlDrift(
+		withModelVersion(pin),
+		withLocalDriftPath(dir),
+		withRollout(&v1alpha1.RolloutPolicy{Strategy: v1alpha1.RolloutCanary}),
+		withAtProvider(v1alpha1.CtrlDriftObservation{
+			Phase:                 v1alpha1.PhaseConverting,
+			Run:                   run,
+			LastTrainingStartTime: &started,
+		}),
+	)
+	served := func(name string) *appsv1.Deployment {
+		d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{labelDeployName: "regression"}}}
+		d.Spec.Template.Annotations = map[string]string{annotationModelVersion: pin}
+		return d
+	}
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{clientgoscheme.AddToScheme, apis.AddToScheme} {
+		if err := add(scheme); err != nil {
+			t.Fatal(err)
+		}
+	}
+	kube := ctrlfake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&v1alpha1.ModelVersion{
+			ObjectMeta: metav1.ObjectMeta{Name: model_version_name(cr, pin), Labels: map[string]string{labelCtrlDrift: "regression"}},
+			Spec:       v1alpha1.ModelVersionSpec{ForProvider: v1alpha1.ModelVersionParameters{CtrlDrift: "regression", Version: pin}},
+		},
+		served(drift_deployment_name(cr)),
+		served(tflite_deployment_name(cr)),
+		&batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "regression-conversion-" + run, Namespace: "default", Labels: map[string]string{
+				labelDeployName: "regression",
+				labelStep:       step_conversion,
+				labelRun:        run,
+			}},
+			Status: batchv1.JobStatus{Succeeded: 1},
+		},
+	).Build()
+	e := external{kube: kube, reader: kube, cursors: new_cursors(), logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+
+	observe := func(reason string, want v1alpha1.Phase) {
+		t.Helper()
+		if _, err := e.Observe(context.Background(), cr); err != nil {
+			t.Fatalf("\n%s\ne.Observe(...): %v\n", reason, err)
+		}
+		if diff := cmp.Diff(want, cr.Status.AtProvider.Phase); diff != "" {
+			t.Fatalf("\n%s\ne.Observe(...): -want phase, +got phase:\n%s\n", reason, diff)
+		}
+	}
+
+	observe("A converted model should bake while a model is pinned.", v1alpha1.PhaseBaking)
+	if err := kube.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: candidate_deployment_name(cr)}, &appsv1.Deployment{}); err != nil {
+		t.Errorf("\nThe candidate deployment should be started while a model is pinned.\nkube.Get(...): %v\n", err)
+	}
+
+	cr.Status.AtProvider.Rollout.Outcome = v1alpha1.RolloutRolledBack
+	observe("A candidate that was rolled back should reject its model.", v1alpha1.PhaseRejected)
+	err := kube.Get(context.Background(), types.NamespacedName{Name: model_version_name(cr, run)}, &v1alpha1.ModelVersion{})
+	if diff := cmp.Diff(true, kerrors.IsNotFound(err)); diff != "" {
+		t.Errorf("\nA rejected model should not be recorded.\nkube.Get(...): -want not found, +got not found:\n%s\n", diff)
+	}
+
+	cr.Spec.ForProvider.ModelVersion = nil
+	observe("Removing the pin should not start a new run.", v1alpha1.PhaseRejected)
+	if diff := cmp.Diff(pin, cr.Status.AtProvider.ActiveModelVersion); diff != "" {
+		t.Errorf("\nRemoving the pin should not activate a rejected model.\ne.Observe(...): -want active model, +got active model:\n%s\n", diff)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	started := metav1.NewTime(time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC))
+	limit := "0.2"
+
+	evaluated := func(metrics string) string {
+		dir := t.TempDir()
+		if err := os.MkdirAll(filepath.Join(dir, "models", "20240501-123000"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "models", "20240501-123000", "evaluation.json"), []byte(metrics), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return dir
+	}
+	evaluation := &v1alpha1.Evaluation{Thresholds: []v1alpha1.MetricThreshold{{Metric: "rmse", Max: &limit}}}
+	trained := v1alpha1.CtrlDriftObservation{LastTrainingStartTime: &started}
+
+	cases := map[string]struct {
+		reason string
+		cr     *v1alpha1.CtrlDrift
+		want   pipeline.Verdict
+		result *v1alpha1.EvaluationResult
+	}{
+		"Accepted": {
+			reason: "A model meeting every threshold should be accepted.",
+			cr:     ctrlDrift(withLocalDriftPath(evaluated(`{"rmse": 0.12}`)), withEvaluation(evaluation), withAtProvider(trained)),
+			want:   pipeline.VerdictAccepted,
+			result: &v1alpha1.EvaluationResult{ModelVersion: "20240501-123000", Accepted: true, Metrics: map[string]string{"rmse": "0.12"}},
+		},
+		"Rejected": {
+			reason: "A model missing a threshold should be rejected with its metrics.",
+			cr:     ctrlDrift(withLocalDriftPath(evaluated(`{"rmse": 0.3}`)), withEvaluation(evaluation), withAtProvider(trained)),
+			want:   pipeline.VerdictRejected,
+			result: &v1alpha1.EvaluationResult{
+				ModelVersion: "20240501-123000",
+				Metrics:      map[string]string{"rmse": "0.3"},
+				Message:      "rmse 0.3 is above the maximum 0.2",
+			},
+		},
+		"Unreadable": {
+			reason: "A model whose metrics cannot be read should be rejected.",
+			cr:     ctrlDrift(withLocalDriftPath(evaluated(`rmse=0.1`)), withEvaluation(evaluation), withAtProvider(trained)),
+			want:   pipeline.VerdictRejected,
+			result: &v1alpha1.EvaluationResult{
+				ModelVersion: "20240501-123000",
+				Message:      "cannot read evaluation metrics: cannot decode metrics: invalid character 'r' looking for beginning of value",
+			},
+		},
+		"AlreadyEvaluated": {
+			reason: "A model that was already evaluated should keep its verdict.",
+			cr:     ctrlDrift(withLocalDriftPath(t.TempDir()), withEvaluation(evaluation), withAtProvider(v1alpha1.CtrlDriftObservation{LastTrainingStartTime: &started, LastEvaluation: &v1alpha1.EvaluationResult{ModelVersion: "20240501-123000", Accepted: true}})),
+			want:   pipeline.VerdictAccepted,
+			result: &v1alpha1.EvaluationResult{ModelVersion: "20240501-123000", Accepted: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := external{kube: &test.MockClient{}, logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+			got, err := e.evaluate(context.Background(), tc.cr)
+			if err != nil {
+				t.Fatalf("\n%s\ne.evaluate(...): %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\ne.evaluate(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.result, tc.cr.Status.AtProvider.LastEvaluation, cmpopts.IgnoreFields(v1alpha1.EvaluationResult{}, "Time")); diff != "" {
+				t.Errorf("\n%s\nstatus.atProvider.lastEvaluation: -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestJudgeRollout(t *testing.T) {
+	started := metav1.NewTime(time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC))
+	baked := metav1.NewTime(started.Add(2 * time.Hour))
+	goal := v1alpha1.MetricGoalMinimize
+
+	reported := func(candidate, stable string) string {
+		dir := t.TempDir()
+		for version, metrics := range map[string]string{"20240501-123000": candidate, "20240401-090000": stable} {
+			if metrics == "" {
+				continue
+			}
+			if err := os.MkdirAll(filepath.Join(dir, "models", version), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "models", version, "inference.json"), []byte(metrics), 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dir
+	}
+	canary := &v1alpha1.RolloutPolicy{
+		Strategy:   v1alpha1.RolloutCanary,
+		Thresholds: []v1alpha1.MetricThreshold{{Metric: "mae", Goal: &goal, NoWorseThanActive: true}},
+	}
+	baking := v1alpha1.CtrlDriftObservation{
+		LastTrainingStartTime: &started,
+		ActiveModelVersion:    "20240401-090000",
+		Rollout:               &v1alpha1.RolloutStatus{ModelVersion: "20240501-123000", Strategy: v1alpha1.RolloutCanary, StartTime: &started},
+	}
+	available := &appsv1.Deployment{Status: appsv1.DeploymentStatus{AvailableReplicas: 1}}
+
+	cases := map[string]struct {
+		reason    string
+		cr        *v1alpha1.CtrlDrift
+		candidate *appsv1.Deployment
+		now       metav1.Time
+		want      pipeline.Verdict
+		rollout   *v1alpha1.RolloutStatus
+	}{
+		"Baking": {
+			reason:    "A candidate should not be judged before its bake time elapsed.",
+			cr:        ctrlDrift(withLocalDriftPath(reported(`{"mae": 0.1}`, `{"mae": 0.2}`)), withRollout(canary), withAtProvider(baking)),
+			candidate: available,
+			now:       metav1.NewTime(started.Add(time.Minute)),
+			want:      pipeline.VerdictUnknown,
+			rollout:   &v1alpha1.RolloutStatus{ModelVersion: "20240501-123000", Strategy: v1alpha1.RolloutCanary, StartTime: &started},
+		},
+		"Promoted": {
+			reason:    "A candidate doing no worse than the inference deployment should be promoted.",
+			cr:        ctrlDrift(withLocalDriftPath(reported(`{"mae": 0.1}`, `{"mae": 0.2}`)), withRollout(canary), withAtProvider(baking)),
+			candidate: available,
+			now:       baked,
+			want:      pipeline.VerdictAccepted,
+			rollout: &v1alpha1.RolloutStatus{
+				ModelVersion:     "20240501-123000",
+				Strategy:         v1alpha1.RolloutCanary,
+				StartTime:        &started,
+				Outcome:          v1alpha1.RolloutPromoted,
+				CandidateMetrics: map[string]string{"mae": "0.1"},
+				StableMetrics:    map[string]string{"mae": "0.2"},
+				CompletionTime:   &baked,
+			},
+		},
+		"RolledBack": {
+			reason:    "A candidate doing worse than the inference deployment should be rolled back.",
+			cr:        ctrlDrift(withLocalDriftPath(reported(`{"mae": 0.3}`, `{"mae": 0.2}`)), withRollout(canary), withAtProvider(baking)),
+			candidate: available,
+			now:       baked,
+			want:      pipeline.VerdictRejected,
+			rollout: &v1alpha1.RolloutStatus{
+				ModelVersion:     "20240501-123000",
+				Strategy:         v1alpha1.RolloutCanary,
+				StartTime:        &started,
+				Outcome:          v1alpha1.RolloutRolledBack,
+				CandidateMetrics: map[string]string{"mae": "0.3"},
+				StableMetrics:    map[string]string{"mae": "0.2"},
+				Message:          "mae 0.3 is worse than 0.2 of the active model",
+				CompletionTime:   &baked,
+			},
+		},
+		"NoMetrics": {
+			reason:    "A candidate that reported no metrics should be rolled back.",
+			cr:        ctrlDrift(withLocalDriftPath(reported("", `{"mae": 0.2}`)), withRollout(canary), withAtProvider(baking)),
+			candidate: available,
+			now:       baked,
+			want:      pipeline.VerdictRejected,
+			rollout: &v1alpha1.RolloutStatus{
+				ModelVersion:   "20240501-123000",
+				Strategy:       v1alpha1.RolloutCanary,
+				StartTime:      &started,
+				Outcome:        v1alpha1.RolloutRolledBack,
+				StableMetrics:  map[string]string{"mae": "0.2"},
+				Message:        "candidate deployment reported no metrics",
+				CompletionTime: &baked,
+			},
+		},
+		"Unavailable": {
+			reason:    "A candidate that never became available should be rolled back.",
+			cr:        ctrlDrift(withLocalDriftPath(t.TempDir()), withRollout(canary), withAtProvider(baking)),
+			candidate: &appsv1.Deployment{},
+			now:       baked,
+			want:      pipeline.VerdictRejected,
+			rollout: &v1alpha1.RolloutStatus{
+				ModelVersion:   "20240501-123000",
+				Strategy:       v1alpha1.RolloutCanary,
+				StartTime:      &started,
+				Outcome:        v1alpha1.RolloutRolledBack,
+				Message:        "candidate deployment never became available",
+				CompletionTime: &baked,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := external{kube: &test.MockClient{}, logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+			got, err := e.judge_rollout(context.Background(), tc.cr, tc.candidate, tc.now)
+			if err != nil {
+				t.Fatalf("\n%s\ne.judge_rollout(...): %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\ne.judge_rollout(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.rollout, tc.cr.Status.AtProvider.Rollout); diff != "" {
+				t.Errorf("\n%s\nstatus.atProvider.rollout: -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRecordJobFailure(t *testing.T) {
+	now := metav1.NewTime(time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC))
+	failed := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "regression-training-job", Namespace: "default", UID: "job-2"},
+		Spec: batchv1.JobSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "training-regression"}},
+		}}},
+		Status: batchv1.JobStatus{
+			Failed: 1,
+			Conditions: []batchv1.JobCondition{{
+				Type:    batchv1.JobFailed,
+				Status:  corev1.ConditionTrue,
+				Reason:  "BackoffLimitExceeded",
+				Message: "Job has reached the specified backoff limit",
+			}},
+		},
+	}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:      "regression-training-job-x7k2p",
+		Namespace: "default",
+		Labels:    map[string]string{"job-name": "regression-training-job"},
+	}}
+	limit := int32(1)
+
+	policy := &v1alpha1.RetryPolicy{Limit: &limit}
+	retry := metav1.NewTime(now.Add(30 * time.Second))
+
+	type want struct {
+		retries int32
+		failure *v1alpha1.JobFailure
+		next    *metav1.Time
+	}
+
+	cases := map[string]struct {
+		reason string
+		cr     *v1alpha1.CtrlDrift
+		want   want
+	}{
+		"FirstFailure": {
+			reason: "A failed job should be recorded with the end of its log and retried after the backoff.",
+			cr:     ctrlDrift(withRetryPolicy(policy)),
+			want: want{
+				retries: 1,
+				failure: &v1alpha1.JobFailure{
+					Job:     "regression-training-job",
+					UID:     "job-2",
+					Attempt: 1,
+					Reason:  "BackoffLimitExceeded: Job has reached the specified backoff limit",
+					Log:     "fake logs",
+					Time:    &now,
+				},
+				next: &retry,
+			},
+		},
+		"RetriesUsedUp": {
+			reason: "A failed job should not be retried once the retries of the run are used up.",
+			cr:     ctrlDrift(withRetryPolicy(policy), withAtProvider(v1alpha1.CtrlDriftObservation{Retries: 1, LastFailure: &v1alpha1.JobFailure{Job: "regression-training-job", UID: "job-1", Attempt: 1}})),
+			want: want{
+				retries: 2,
+				failure: &v1alpha1.JobFailure{
+					Job:     "regression-training-job",
+					UID:     "job-2",
+					Attempt: 2,
+					Reason:  "BackoffLimitExceeded: Job has reached the specified backoff limit",
+					Log:     "fake logs",
+					Time:    &now,
+				},
+			},
+		},
+		"AlreadyRecorded": {
+			reason: "A failed job should only be recorded once.",
+			cr:     ctrlDrift(withRetryPolicy(policy), withAtProvider(v1alpha1.CtrlDriftObservation{Retries: 1, LastFailure: &v1alpha1.JobFailure{Job: "regression-training-job", UID: "job-2", Attempt: 1}})),
+			want: want{
+				retries: 1,
+				failure: &v1alpha1.JobFailure{Job: "regression-training-job", UID: "job-2", Attempt: 1},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := external{clientset: kfake.NewSimpleClientset(pod), logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+			e.record_job_failure(context.Background(), tc.cr, failed, now)
+			got := want{
+				retries: tc.cr.Status.AtProvider.Retries,
+				failure: tc.cr.Status.AtProvider.LastFailure,
+				next:    tc.cr.Status.AtProvider.NextRetryTime,
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\ne.record_job_failure(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	backoff := metav1.Duration{Duration: time.Minute}
+	limit := metav1.Duration{Duration: 5 * time.Minute}
+	cr := ctrlDrift(withRetryPolicy(&v1alpha1.RetryPolicy{Backoff: &backoff, MaxBackoff: &limit}))
+
+	cases := map[string]struct {
+		retry int32
+		want  time.Duration
+	}{
+		"First":  {retry: 1, want: time.Minute},
+		"Second": {retry: 2, want: 2 * time.Minute},
+		"Third":  {retry: 3, want: 4 * time.Minute},
+		"Capped": {retry: 4, want: 5 * time.Minute},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, retry_backoff(cr, tc.retry)); diff != "" {
+				t.Errorf("retry_backoff(%d): -want, +got:\n%s\n", tc.retry, diff)
+			}
+		})
+	}
+}
+
+func TestRunJobs(t *testing.T) {
+	job := func(name, step string, minute int, labels ...string) batchv1.Job {
+		j := batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Labels:            map[string]string{labelStep: step},
+			CreationTimestamp: metav1.NewTime(time.Date(2026, 1, 1, 0, minute, 0, 0, time.UTC)),
+		}}
+		for i := 0; i+1 < len(labels); i += 2 {
+			j.Labels[labels[i]] = labels[i+1]
+		}
+		return j
+	}
+	jobs := []batchv1.Job{
+		job("regression-training-20260101-000000-1", "training", 2),
+		job("regression-training-20260101-000000", "training", 1, labelRetired, "true"),
+		job("regression-evaluation-20251231-000000", "evaluation", 0, labelRetired, "true"),
+		job("regression-conversion-20251231-000000", "conversion", 0),
+		job("regression-conversion-20251230-000000", "conversion", 3),
+	}
+
+	training, evaluation, conversion := run_jobs(jobs)
+	name := func(j *batchv1.Job) string {
+		if j == nil {
+			return ""
+		}
+		return j.Name
+	}
+	if diff := cmp.Diff("regression-training-20260101-000000-1", name(training)); diff != "" {
+		t.Errorf("run_jobs(...): training: the newest job that was not retired should be returned: -want, +got:\n%s\n", diff)
+	}
+	if diff := cmp.Diff("", name(evaluation)); diff != "" {
+		t.Errorf("run_jobs(...): evaluation: a retired job should never be returned: -want, +got:\n%s\n", diff)
+	}
+	if diff := cmp.Diff("regression-conversion-20251230-000000", name(conversion)); diff != "" {
+		t.Errorf("run_jobs(...): conversion: the most recently created job should be returned: -want, +got:\n%s\n", diff)
+	}
+}
+
+func TestSpecChanged(t *testing.T) {
+	job := func(generation ...string) *batchv1.Job {
+		j := &batchv1.Job{}
+		if len(generation) > 0 {
+			j.SetAnnotations(map[string]string{annotationGeneration: generation[0]})
+		}
+		return j
+	}
+
+	cases := map[string]struct {
+		reason string
+		jobs   []*batchv1.Job
+		want   bool
+	}{
+		"Unchanged": {
+			reason: "Jobs started for the current generation should not report a change.",
+			jobs:   []*batchv1.Job{job("2"), nil},
+			want:   false,
+		},
+		"Changed": {
+			reason: "A Job started for an earlier generation should report a change.",
+			jobs:   []*batchv1.Job{job("2"), job("1")},
+			want:   true,
+		},
+		"Unannotated": {
+			reason: "A Job started before the generation was recorded should not report a change.",
+			jobs:   []*batchv1.Job{job()},
+			want:   false,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, spec_changed(ctrlDrift(withGeneration(2)), tc.jobs...)); diff != "" {
+				t.Errorf("\n%s\nspec_changed(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestPruneHistory(t *testing.T) {
+	one := int32(1)
+	zero := int32(0)
+	cr := ctrlDrift(withJobHistory(&v1alpha1.JobHistory{SuccessfulJobsHistoryLimit: &one, FailedJobsHistoryLimit: &zero}))
+	job := func(run, step string, state pipeline.JobState, retire bool) batchv1.Job {
+		created, _ := time.Parse("20060102-150405", run)
+		j := batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              fmt.Sprintf("regression-%s-%s", step, run),
+				Labels:            map[string]string{labelRun: run, labelStep: step},
+				CreationTimestamp: metav1.NewTime(created),
+			},
+		}
+		if retire {
+			j.Labels[labelRetired] = "true"
+		}
+		switch state {
+		case pipeline.JobSucceeded:
+			j.Status.Succeeded = 1
+		case pipeline.JobFailed:
+			j.Status.Failed = 1
+		}
+		return j
+	}
+	jobs := []batchv1.Job{
+		job("20260101-000000", "training", pipeline.JobSucceeded, true),
+		job("20260101-000000", "conversion", pipeline.JobSucceeded, true),
+		job("20260102-000000", "training", pipeline.JobFailed, true),
+		job("20260103-000000", "training", pipeline.JobSucceeded, true),
+		job("20260103-000000", "evaluation", pipeline.JobRunning, false),
+	}
+
+	var deleted []string
+	kube := &test.MockClient{MockDelete: func(_ context.Context, obj client.Object, _ ...client.DeleteOption) error {
+		deleted = append(deleted, obj.GetName())
+		return nil
+	}}
+	e := external{kube: kube, logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+	kept, err := e.prune_history(context.Background(), cr, jobs)
+	if err != nil {
+		t.Fatalf("e.prune_history(...): %s", err)
+	}
+
+	want := []string{"regression-training-20260101-000000", "regression-conversion-20260101-000000", "regression-training-20260102-000000"}
+	if diff := cmp.Diff(want, deleted, cmpopts.SortSlices(func(a, b string) bool { return a < b })); diff != "" {
+		t.Errorf("e.prune_history(...): the oldest retired jobs beyond the limits should be deleted: -want, +got:\n%s\n", diff)
+	}
+	wantRuns := []v1alpha1.RunStatus{{ID: "20260103-000000", Jobs: []string{"regression-evaluation-20260103-000000", "regression-training-20260103-000000"}}}
+	if diff := cmp.Diff(wantRuns, run_history(kept)); diff != "" {
+		t.Errorf("run_history(...): the runs of the kept jobs should be linked to them: -want, +got:\n%s\n", diff)
+	}
+}
+
+func TestRetireJob(t *testing.T) {
+	hour := metav1.Duration{Duration: time.Hour}
+	job := func(labels map[string]string) *batchv1.Job {
+		return &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "regression-training-20240501-120000", Labels: labels}}
+	}
+
+	cases := map[string]struct {
+		reason  string
+		history *v1alpha1.JobHistory
+		job     *batchv1.Job
+		want    *batchv1.Job
+	}{
+		"Finished": {
+			reason: "A retired job should be labelled and get the default TTL.",
+			job:    job(map[string]string{labelStep: step_training}),
+			want: &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "regression-training-20240501-120000", Labels: map[string]string{labelStep: step_training, labelRetired: "true"}},
+				Spec:       batchv1.JobSpec{TTLSecondsAfterFinished: int32Ptr(86400)},
+			},
+		},
+		"TTL": {
+			reason:  "A retired job should get the configured TTL.",
+			history: &v1alpha1.JobHistory{TTLAfterFinished: &hour},
+			job:     job(nil),
+			want: &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "regression-training-20240501-120000", Labels: map[string]string{labelRetired: "true"}},
+				Spec:       batchv1.JobSpec{TTLSecondsAfterFinished: int32Ptr(3600)},
+			},
+		},
+		"Retired": {
+			reason: "A job that was already retired should not be patched.",
+			job:    job(map[string]string{labelRetired: "true"}),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var patched *batchv1.Job
+			kube := &test.MockClient{MockPatch: func(_ context.Context, obj client.Object, _ client.Patch, _ ...client.PatchOption) error {
+				patched = obj.(*batchv1.Job).DeepCopy()
+				return nil
+			}}
+			cr := ctrlDrift(withJobHistory(tc.history))
+			e := external{kube: kube, logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+			if err := e.retire_job(context.Background(), cr, tc.job); err != nil {
+				t.Fatalf("\n%s\ne.retire_job(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, patched); diff != "" {
+				t.Errorf("\n%s\ne.retire_job(...): -want patched, +got patched:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestScheduledRun(t *testing.T) {
+	now := metav1.NewTime(time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC))
+	last := metav1.NewTime(time.Date(2024, 4, 30, 12, 0, 0, 0, time.UTC))
+	due := metav1.NewTime(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	next := metav1.NewTime(time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC))
+	cron := "0 12 * * *"
+
+	schedule := &v1alpha1.RetrainSchedule{Cron: &cron}
+	missed := metav1.NewTime(last.Add(-48 * time.Hour))
+
+	type want struct {
+		due     bool
+		last    *metav1.Time
+		next    *metav1.Time
+		skipped []v1alpha1.SkippedRun
+	}
+
+	cases := map[string]struct {
+		reason string
+		cr     *v1alpha1.CtrlDrift
+		want   want
+	}{
+		"Due": {
+			reason: "A scheduled run should be started while no other run is in flight.",
+			cr:     ctrlDrift(withRetrainSchedule(schedule), withAtProvider(v1alpha1.CtrlDriftObservation{Phase: v1alpha1.PhaseMonitoring, LastScheduleTime: &last})),
+			want:   want{due: true, last: &last, next: &next},
+		},
+		"NotDue": {
+			reason: "No run should be started before the schedule is due.",
+			cr:     ctrlDrift(withRetrainSchedule(schedule), withAtProvider(v1alpha1.CtrlDriftObservation{Phase: v1alpha1.PhaseMonitoring, LastScheduleTime: &due})),
+			want:   want{last: &due, next: &next},
+		},
+		"Overlap": {
+			reason: "A scheduled run that is due while another run is in flight should be skipped and recorded.",
+			cr:     ctrlDrift(withRetrainSchedule(schedule), withAtProvider(v1alpha1.CtrlDriftObservation{Phase: v1alpha1.PhaseTraining, LastScheduleTime: &last})),
+			want: want{last: &due, next: &next, skipped: []v1alpha1.SkippedRun{{
+				Time:    &due,
+				Reason:  v1alpha1.SkipOverlap,
+				Message: "run scheduled at 2024-05-01T12:00:00Z skipped, the pipeline is Training",
+			}}},
+		},
+		"Failed": {
+			reason: "A scheduled run that is due while the pipeline is failed should be skipped and recorded.",
+			cr:     ctrlDrift(withRetrainSchedule(schedule), withAtProvider(v1alpha1.CtrlDriftObservation{Phase: v1alpha1.PhaseFailed, LastScheduleTime: &last})),
+			want: want{last: &due, next: &next, skipped: []v1alpha1.SkippedRun{{
+				Time:    &due,
+				Reason:  v1alpha1.SkipFailed,
+				Message: "run scheduled at 2024-05-01T12:00:00Z skipped, the pipeline is Failed",
+			}}},
+		},
+		"Paused": {
+			reason: "A scheduled run that is due while the pipeline is paused should be skipped and recorded.",
+			cr:     ctrlDrift(withRetrainSchedule(schedule), withAtProvider(v1alpha1.CtrlDriftObservation{Phase: v1alpha1.PhaseMonitoring, LastScheduleTime: &last}), withAnnotations(map[string]string{v1alpha1.AnnotationPaused: "true"})),
+			want: want{last: &due, next: &next, skipped: []v1alpha1.SkippedRun{{
+				Time:    &due,
+				Reason:  v1alpha1.SkipPaused,
+				Message: "run scheduled at 2024-05-01T12:00:00Z skipped, the pipeline is paused",
+			}}},
+		},
+		"Missed": {
+			reason: "Only the last of several passed runs should be started, the others should be recorded as missed.",
+			cr:     ctrlDrift(withRetrainSchedule(schedule), withAtProvider(v1alpha1.CtrlDriftObservation{Phase: v1alpha1.PhaseMonitoring, LastScheduleTime: &missed})),
+			want: want{due: true, last: &missed, next: &next, skipped: []v1alpha1.SkippedRun{{
+				Time:    &due,
+				Reason:  v1alpha1.SkipMissed,
+				Message: "2 runs scheduled before 2024-05-01T12:00:00Z were missed",
+			}}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := external{logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+			d, err := e.scheduled_run(tc.cr, now)
+			if err != nil {
+				t.Fatalf("e.scheduled_run(...): %s", err)
+			}
+			got := want{
+				due:     d.Due,
+				last:    tc.cr.Status.AtProvider.LastScheduleTime,
+				next:    tc.cr.Status.AtProvider.NextScheduleTime,
+				skipped: tc.cr.Status.AtProvider.SkippedRuns,
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\ne.scheduled_run(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestCancelRun(t *testing.T) {
+	started := metav1.NewTime(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	running := v1alpha1.CtrlDriftObservation{
+		LastTrainingStartTime: &started,
+		Retries:               2,
+		Rollout:               &v1alpha1.RolloutStatus{ModelVersion: "20240501-120000", Strategy: v1alpha1.RolloutCanary, StartTime: &started},
+	}
+	job := func(name string) *batchv1.Job {
+		return &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name}}
+	}
+	candidate := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "regression-python-tflite-candidate"}}
+
+	type want struct {
+		retired []string
+		deleted []string
+		outcome v1alpha1.RolloutOutcome
+		err     error
+	}
+
+	cases := map[string]struct {
+		reason    string
+		jobs      []*batchv1.Job
+		candidate *appsv1.Deployment
+		want      want
+	}{
+		"Jobs": {
+			reason: "The jobs of the run in flight should be retired and deleted.",
+			jobs:   []*batchv1.Job{job("regression-training-20240501-120000"), nil, job("regression-conversion-20240501-120000")},
+			want: want{
+				retired: []string{"regression-training-20240501-120000", "regression-conversion-20240501-120000"},
+				deleted: []string{"Job/regression-training-20240501-120000", "Job/regression-conversion-20240501-120000"},
+				outcome: v1alpha1.RolloutRolledBack,
+			},
+		},
+		"Candidate": {
+			reason:    "The candidate deployment of a baking run should be deleted and its rollout rolled back.",
+			jobs:      []*batchv1.Job{nil, nil, nil},
+			candidate: candidate,
+			want: want{
+				deleted: []string{"Deployment/regression-python-tflite-candidate"},
+				outcome: v1alpha1.RolloutRolledBack,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var retired, deleted []string
+			kube := &test.MockClient{
+				MockPatch: func(_ context.Context, obj client.Object, _ client.Patch, _ ...client.PatchOption) error {
+					if obj.GetLabels()[labelRetired] == "true" {
+						retired = append(retired, obj.GetName())
+					}
+					return nil
+				},
+				MockDelete: func(_ context.Context, obj client.Object, _ ...client.DeleteOption) error {
+					deleted = append(deleted, strings.TrimPrefix(fmt.Sprintf("%T", obj), "*v1.")+"/"+obj.GetName())
+					return nil
+				},
+			}
+			cr := ctrlDrift(withAtProvider(running))
+			e := external{kube: kube, logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+			err := e.cancel_run(context.Background(), cr, tc.jobs, tc.candidate)
+			got := want{retired: retired, deleted: deleted, outcome: cr.Status.AtProvider.Rollout.Outcome, err: err}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{}), test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.cancel_run(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if cr.Status.AtProvider.Retries != 0 {
+				t.Errorf("\n%s\ne.cancel_run(...): the retries of the cancelled run should be reset, got %d\n", tc.reason, cr.Status.AtProvider.Retries)
+			}
+		})
+	}
+}
+
+func TestSnapshotScript(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no shell to run the snapshot script with")
+	}
+
+	type want struct {
+		files []string
+		err   bool
+	}
+
+	cases := map[string]struct {
+		reason string
+		files  []string
+		keep   []string
+		want   want
+	}{
+		"Snapshot": {
+			reason: "The drift dataset should be copied with its manifest, and only the snapshots of the CtrlDrift that are not kept deleted.",
+			files:  []string{"drift_data.csv", "datasets/regression/old/drift_data.csv", "datasets/regression/kept/drift_data.csv", "datasets/other/old/drift_data.csv"},
+			keep:   []string{"kept", "20240501-123000"},
+			want: want{files: []string{
+				"datasets/other/old/drift_data.csv",
+				"datasets/regression/20240501-123000/drift_data.csv",
+				"datasets/regression/20240501-123000/manifest.json",
+				"datasets/regression/kept/drift_data.csv",
+				"drift_data.csv",
+			}},
+		},
+		"NoDriftData": {
+			reason: "A missing drift dataset should be snapshotted as an empty file instead of failing training.",
+			files:  []string{"datasets/regression/old/drift_data.csv"},
+			want: want{files: []string{
+				"datasets/regression/20240501-123000/drift_data.csv",
+				"datasets/regression/20240501-123000/manifest.json",
+			}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tc.files {
+				if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, f)), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, f), []byte("a,b\n1,2\n"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cr := ctrlDrift(withAtProvider(v1alpha1.CtrlDriftObservation{Run: "20240501-123000"}))
+			container := get_snapshot_container(cr, cr.Spec.ForProvider.Training, tc.keep)
+			cmd := exec.Command(container.Command[0], container.Command[1:]...) //nolint:gosec // Runs the script under test.
+			for _, env := range container.Env {
+				if env.Name == "DATA_DIR" {
+					env.Value = dir
+				}
+				cmd.Env = append(cmd.Env, env.Name+"="+env.Value)
+			}
+			cmd.Env = append(cmd.Env, "PATH="+os.Getenv("PATH"))
+			out, err := cmd.CombinedOutput()
+			if diff := cmp.Diff(tc.want.err, err != nil); diff != "" {
+				t.Errorf("\n%s\nsnapshot_script: -want error, +got error:\n%s\n%s\n", tc.reason, diff, out)
+			}
+
+			var files []string
+			_ = filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+				if err == nil && !info.IsDir() {
+					rel, _ := filepath.Rel(dir, p)
+					files = append(files, filepath.ToSlash(rel))
+				}
+				return nil
+			})
+			if diff := cmp.Diff(tc.want.files, files); diff != "" {
+				t.Errorf("\n%s\nsnapshot_script: -want, +got:\n%s\n", tc.reason, diff)
+			}
+			//let the temp dir be removed
+			_ = filepath.Walk(dir, func(p string, _ os.FileInfo, _ error) error { return os.Chmod(p, 0o755) })
+		})
+	}
+}
+
+func TestRecordDatasetSnapshot(t *testing.T) {
+	now := metav1.NewTime(time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC))
+	earlier := metav1.NewTime(time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC))
+	sum := strings.Repeat("cd", 32)
+	limit := func(i int32) *int32 { return &i }
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "datasets", "regression", "20240501-123000"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "datasets", "regression", "20240501-123000", "manifest.json"), []byte(`{"sha256":"`+sum+`","lines":3001,"bytes":48213}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	recorded := v1alpha1.DatasetSnapshot{
+		Run:      "20240501-123000",
+		Path:     "datasets/regression/20240501-123000/drift_data.csv",
+		Checksum: "sha256:" + sum,
+		Rows:     3000,
+		Bytes:    48213,
+		Time:     &now,
+	}
+	older := v1alpha1.DatasetSnapshot{Run: "20240401-000000", Path: "datasets/regression/20240401-000000/drift_data.csv", Time: &earlier}
+	remote := &v1alpha1.DriftSource{Type: v1alpha1.DriftSourceS3}
+	forbidden := func() *kfake.Clientset {
+		cs := kfake.NewSimpleClientset()
+		cs.PrependReactor("create", "pods", func(ktesting.Action) (bool, runtime.Object, error) {
+			return true, nil, kerrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "", errors.New("RBAC"))
+		})
+		return cs
+	}
+
+	type want struct {
+		datasets []v1alpha1.DatasetSnapshot
+		err      bool
+	}
+
+	cases := map[string]struct {
+		reason string
+		cr     *v1alpha1.CtrlDrift
+		run    string
+		client *kfake.Clientset
+		want   want
+	}{
+		"Recorded": {
+			reason: "The snapshot should be recorded with the checksum, rows and size of its manifest, newest first.",
+			cr:     ctrlDrift(withLocalDriftPath(dir), withAtProvider(v1alpha1.CtrlDriftObservation{Datasets: []v1alpha1.DatasetSnapshot{older}})),
+			run:    "20240501-123000",
+			want:   want{datasets: []v1alpha1.DatasetSnapshot{recorded, older}},
+		},
+		"Limit": {
+			reason: "Only as many snapshots as the limit should be recorded.",
+			cr:     ctrlDrift(withLocalDriftPath(dir), withDatasetSnapshots(&v1alpha1.DatasetSnapshotPolicy{Limit: limit(1)}), withAtProvider(v1alpha1.CtrlDriftObservation{Datasets: []v1alpha1.DatasetSnapshot{older}})),
+			run:    "20240501-123000",
+			want:   want{datasets: []v1alpha1.DatasetSnapshot{recorded}},
+		},
+		"AlreadyRecorded": {
+			reason: "A snapshot that was already recorded should not be read again.",
+			cr:     ctrlDrift(withLocalDriftPath(dir), withAtProvider(v1alpha1.CtrlDriftObservation{Datasets: []v1alpha1.DatasetSnapshot{older}})),
+			run:    "20240401-000000",
+			want:   want{datasets: []v1alpha1.DatasetSnapshot{older}},
+		},
+		"NoManifest": {
+			reason: "A snapshot without a manifest should be recorded without its checksum and size.",
+			cr:     ctrlDrift(withLocalDriftPath(dir), withAtProvider(v1alpha1.CtrlDriftObservation{Datasets: []v1alpha1.DatasetSnapshot{older}})),
+			run:    "20240502-000000",
+			want: want{datasets: []v1alpha1.DatasetSnapshot{
+				{Run: "20240502-000000", Path: "datasets/regression/20240502-000000/drift_data.csv", Time: &now},
+				older,
+			}},
+		},
+		"Pending": {
+			reason: "A snapshot should not be recorded while the reader pod reads its manifest.",
+			cr:     ctrlDrift(withDriftSource(remote)),
+			run:    "20240501-123000",
+			want:   want{err: true},
+		},
+		"ReaderPodError": {
+			reason: "A snapshot should not be recorded when the reader pod cannot be started.",
+			cr:     ctrlDrift(withDriftSource(remote)),
+			run:    "20240501-123000",
+			client: forbidden(),
+			want:   want{err: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			clientset := tc.client
+			if clientset == nil {
+				clientset = kfake.NewSimpleClientset()
+			}
+			e := external{clientset: clientset, logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+			err := e.record_dataset_snapshot(context.Background(), tc.cr, tc.run, now)
+			if diff := cmp.Diff(tc.want.err, err != nil); diff != "" {
+				t.Errorf("\n%s\ne.record_dataset_snapshot(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.datasets, tc.cr.Status.AtProvider.Datasets); diff != "" {
+				t.Errorf("\n%s\ne.record_dataset_snapshot(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestDatasetRows(t *testing.T) {
+	records := func(n int64) *int64 { return &n }
+	no := false
+
+	cases := map[string]struct {
+		reason string
+		cr     *v1alpha1.CtrlDrift
+		m      datasetManifest
+		want   int64
+	}{
+		"CSV": {
+			reason: "The header of a CSV file should not be counted.",
+			cr:     ctrlDrift(withDriftFile("drift_data.csv", nil)),
+			m:      datasetManifest{Lines: 3001, Records: records(3001)},
+			want:   3000,
+		},
+		"CSVWithoutHeader": {
+			reason: "Every record of a CSV file without a header should be counted.",
+			cr:     ctrlDrift(withDriftFile("drift_data.csv", &no)),
+			m:      datasetManifest{Lines: 3000, Records: records(3000)},
+			want:   3000,
+		},
+		"NoTrailingNewline": {
+			reason: "A last line without a newline should be counted.",
+			cr:     ctrlDrift(withDriftFile("drift_data.csv", &no)),
+			m:      datasetManifest{Lines: 2999, Records: records(3000)},
+			want:   3000,
+		},
+		"JSONLines": {
+			reason: "Every record of a JSON Lines file should be counted.",
+			cr:     ctrlDrift(withDriftFile("drift_data.jsonl", nil)),
+			m:      datasetManifest{Lines: 3000, Records: records(3000)},
+			want:   3000,
+		},
+		"Parquet": {
+			reason: "The rows of a Parquet file should not be counted from its lines.",
+			cr:     ctrlDrift(withDriftFile("drift_data.parquet", nil)),
+			m:      datasetManifest{Lines: 17, Records: records(12)},
+			want:   0,
+		},
+		"EmptyCSV": {
+			reason: "An empty CSV file should have no rows.",
+			cr:     ctrlDrift(withDriftFile("drift_data.csv", nil)),
+			m:      datasetManifest{Records: records(0)},
+			want:   0,
+		},
+		"EarlierManifest": {
+			reason: "The lines of a manifest without records should be counted.",
+			cr:     ctrlDrift(withDriftFile("drift_data.csv", nil)),
+			m:      datasetManifest{Lines: 3001},
+			want:   3000,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, dataset_rows(tc.cr, tc.m)); diff != "" {
+				t.Errorf("\n%s\ndataset_rows(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRetainedSnapshots(t *testing.T) {
+	keep := func(b bool) *bool { return &b }
+	limit := func(i int32) *int32 { return &i }
+
+	recorded := v1alpha1.CtrlDriftObservation{
+		Run: "20240601-000000",
+		Datasets: []v1alpha1.DatasetSnapshot{
+			{Run: "20240501-000000"},
+			{Run: "20240401-000000"},
+			{Run: "20240301-000000"},
+		},
+	}
+	model := func(run string) v1alpha1.ModelVersion {
+		mv := v1alpha1.ModelVersion{}
+		mv.Spec.ForProvider.CtrlDrift = "regression"
+		mv.Spec.ForProvider.Dataset = &v1alpha1.ModelDataset{Path: "datasets/regression/" + run + "/drift_data.csv"}
+		return mv
+	}
+	unsnapshotted := v1alpha1.ModelVersion{}
+	unsnapshotted.Spec.ForProvider.CtrlDrift = "regression"
+	unsnapshotted.Spec.ForProvider.Dataset = &v1alpha1.ModelDataset{Path: "drift_data.csv"}
+
+	cases := map[string]struct {
+		reason string
+		cr     *v1alpha1.CtrlDrift
+		models []v1alpha1.ModelVersion
+		want   []string
+	}{
+		"Limit": {
+			reason: "The current run and the most recent snapshots should be kept up to the limit.",
+			cr:     ctrlDrift(withDatasetSnapshots(&v1alpha1.DatasetSnapshotPolicy{Limit: limit(2)}), withAtProvider(recorded)),
+			want:   []string{"20240501-000000", "20240601-000000"},
+		},
+		"RecordedModels": {
+			reason: "The snapshots the recorded models were trained on should be kept beyond the limit when the policy says so.",
+			cr:     ctrlDrift(withDatasetSnapshots(&v1alpha1.DatasetSnapshotPolicy{Limit: limit(1), KeepRecordedModels: keep(true)}), withAtProvider(recorded)),
+			models: []v1alpha1.ModelVersion{model("20240301-000000"), unsnapshotted},
+			want:   []string{"20240301-000000", "20240601-000000"},
+		},
+		"IgnoreRecordedModels": {
+			reason: "The snapshots of recorded models should not be kept beyond the limit by default.",
+			cr:     ctrlDrift(withDatasetSnapshots(&v1alpha1.DatasetSnapshotPolicy{Limit: limit(1)}), withAtProvider(recorded)),
+			models: []v1alpha1.ModelVersion{model("20240301-000000")},
+			want:   []string{"20240601-000000"},
+		},
+		"NoPolicy": {
+			reason: "The current run and the snapshots of the most recent runs should be kept up to the default limit.",
+			cr:     ctrlDrift(withAtProvider(recorded)),
+			models: []v1alpha1.ModelVersion{model("20240201-000000")},
+			want:   []string{"20240301-000000", "20240401-000000", "20240501-000000", "20240601-000000"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := external{
+				kube: &test.MockClient{
+					MockList: func(_ context.Context, obj client.ObjectList, _ ...client.ListOption) error {
+						obj.(*v1alpha1.ModelVersionList).Items = tc.models
+						return nil
+					},
+				},
+				logger:   logging.NewNopLogger(),
+				recorder: event.NewNopRecorder(),
+			}
+			got, err := e.retained_snapshots(context.Background(), tc.cr)
+			if err != nil {
+				t.Fatalf("\n%s\ne.retained_snapshots(...): %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\ne.retained_snapshots(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestDriftFileEnv(t *testing.T) {
+	str := func(s string) *string { return &s }
+	env := func(c corev1.Container, name string) string {
+		for _, e := range c.Env {
+			if e.Name == name {
+				return e.Value
+			}
+		}
+		return ""
+	}
+
+	cases := map[string]struct {
+		reason string
+		cr     *v1alpha1.CtrlDrift
+		want   []string
+	}{
+		"Default": {
+			reason: "The detector should write, and training read, the default drift data file.",
+			cr:     ctrlDrift(),
+			want:   []string{"drift_data", "drift_data.csv", "drift_data.csv"},
+		},
+		"File": {
+			reason: "The detector should write, and training read, the drift data file of the drift source that is snapshotted.",
+			cr:     ctrlDrift(withDriftSource(&v1alpha1.DriftSource{Type: v1alpha1.DriftSourcePod, File: str("detector/drift.csv")})),
+			want:   []string{"detector/drift", "detector/drift.csv", "detector/drift.csv"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			training := get_training_job(tc.cr).Spec.Template.Spec
+			snapshot := get_snapshot_container(tc.cr, tc.cr.Spec.ForProvider.Training, nil)
+			got := []string{
+				env(get_drift_detection_deployment(tc.cr).Spec.Template.Spec.Containers[0], "OUTPUT_NAME"),
+				env(training.Containers[0], "DATA_PATH"),
+				env(snapshot, "DATA_FILE"),
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nOUTPUT_NAME, DATA_PATH and DATA_FILE: -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	str := func(s string) *string { return &s }
+	header := false
+	format := v1alpha1.DriftFileJSONLines
+
+	e := external{cursors: new_cursors()}
+	reader := &driftsource.LocalReader{Dir: t.TempDir()}
+
+	got := e.file_source(ctrlDrift(), reader)
+	if diff := cmp.Diff(&driftsource.FileSource{Reader: reader, File: "drift_data.csv", Header: true, Cursor: &driftsource.Cursor{}}, got); diff != "" {
+		t.Errorf("\nThe drift data file should be a CSV file with a header by default.\ne.file_source(...): -want, +got:\n%s\n", diff)
+	}
+
+	got.Cursor.Offset = 42
+	if again := e.file_source(ctrlDrift(), reader); again.Cursor != got.Cursor {
+		t.Errorf("\nThe cursor should be kept while the file is read from the same source.\ne.file_source(...).Cursor: want %v, got %v", got.Cursor, again.Cursor)
+	}
+
+	src := &v1alpha1.DriftSource{Type: v1alpha1.DriftSourceLocal, File: str("drift.jsonl"), Format: &format, Header: &header, TimestampColumn: str("ts")}
+	want := &driftsource.FileSource{Reader: reader, File: "drift.jsonl", Format: driftsource.FormatJSONLines, TimestampColumn: "ts", Cursor: &driftsource.Cursor{}}
+	if diff := cmp.Diff(want, e.file_source(ctrlDrift(withDriftSource(src)), reader)); diff != "" {
+		t.Errorf("\nThe format of the drift source should be used, and a new cursor once the file changed.\ne.file_source(...): -want, +got:\n%s\n", diff)
+	}
+}
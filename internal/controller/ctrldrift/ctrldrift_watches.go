@@ -0,0 +1,38 @@
+package ctrldrift
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/provider-driftprovider/apis/mlops/v1alpha1"
+)
+
+// enqueue_ctrldrift returns an event handler that enqueues the CtrlDrift that
+// generated the workload an event is about, so that a finished Job or a
+// changed Deployment advances the pipeline without waiting for the next poll.
+func enqueue_ctrldrift() handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(_ context.Context, obj client.Object) []reconcile.Request {
+		return ctrldrift_requests(obj)
+	})
+}
+
+// ctrldrift_requests maps a generated workload to the CtrlDrift that owns it,
+// by its labelCtrlDrift or else by its owner reference. Workloads with
+// neither are not mapped.
+func ctrldrift_requests(obj client.Object) []reconcile.Request {
+	if name := obj.GetLabels()[labelCtrlDrift]; name != "" {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name}}}
+	}
+	for _, ref := range obj.GetOwnerReferences() {
+		gv, err := schema.ParseGroupVersion(ref.APIVersion)
+		if err == nil && gv.Group == v1alpha1.Group && ref.Kind == v1alpha1.CtrlDriftKind {
+			return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: ref.Name}}}
+		}
+	}
+	return nil
+}
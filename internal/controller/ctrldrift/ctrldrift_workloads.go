@@ -0,0 +1,116 @@
+package ctrldrift
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/crossplane/provider-driftprovider/apis/mlops/v1alpha1"
+)
+
+// container_image returns the image configured by the supplied workload spec,
+// falling back to the default image and tag. A digest wins over a tag.
+func container_image(spec *v1alpha1.WorkloadSpec, default_image string) string {
+	image := default_image
+	tag := default_image_tag
+	if spec == nil {
+		return image + ":" + tag
+	}
+	if spec.Image != nil && *spec.Image != "" {
+		image = *spec.Image
+	}
+	if spec.Digest != nil && *spec.Digest != "" {
+		return image + "@" + *spec.Digest
+	}
+	if spec.Tag != nil && *spec.Tag != "" {
+		tag = *spec.Tag
+	}
+	return image + ":" + tag
+}
+
+func data_volume_spec(spec *v1alpha1.WorkloadSpec) *v1alpha1.DataVolume {
+	if spec == nil || spec.Volume == nil {
+		return &v1alpha1.DataVolume{}
+	}
+	return spec.Volume
+}
+
+// data_mount_path is where the data volume is mounted in the container.
+func data_mount_path(spec *v1alpha1.WorkloadSpec) string {
+	if v := data_volume_spec(spec); v.MountPath != nil && *v.MountPath != "" {
+		return *v.MountPath
+	}
+	return default_mount_path
+}
+
+func data_claim_name(spec *v1alpha1.WorkloadSpec) string {
+	if v := data_volume_spec(spec); v.ClaimName != nil && *v.ClaimName != "" {
+		return *v.ClaimName
+	}
+	return default_claim_name
+}
+
+func data_volume(spec *v1alpha1.WorkloadSpec) corev1.Volume {
+	return corev1.Volume{
+		Name: data_volume_name,
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: data_claim_name(spec),
+			},
+		},
+	}
+}
+
+func data_volume_mount(spec *v1alpha1.WorkloadSpec) corev1.VolumeMount {
+	m := corev1.VolumeMount{
+		Name:      data_volume_name,
+		MountPath: data_mount_path(spec),
+	}
+	if v := data_volume_spec(spec); v.SubPath != nil {
+		m.SubPath = *v.SubPath
+	}
+	return m
+}
+
+// merge_env returns the default variables with every override applied. An
+// override replaces the default variable of the same name, or is appended.
+func merge_env(defaults []corev1.EnvVar, overrides []corev1.EnvVar) []corev1.EnvVar {
+	merged := make([]corev1.EnvVar, 0, len(defaults)+len(overrides))
+	merged = append(merged, defaults...)
+	for _, o := range overrides {
+		replaced := false
+		for i := range merged {
+			if merged[i].Name == o.Name {
+				merged[i] = o
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, o)
+		}
+	}
+	return merged
+}
+
+// customise_pod applies the optional fields of a workload spec to the first
+// container and to the pod of a generated workload.
+func customise_pod(spec *v1alpha1.WorkloadSpec, pod *corev1.PodSpec) {
+	if spec == nil || len(pod.Containers) == 0 {
+		return
+	}
+	container := &pod.Containers[0]
+
+	if spec.ImagePullPolicy != nil {
+		container.ImagePullPolicy = *spec.ImagePullPolicy
+	}
+	container.Env = merge_env(container.Env, spec.Env)
+	container.EnvFrom = append(container.EnvFrom, spec.EnvFrom...)
+	if spec.Resources != nil {
+		container.Resources = *spec.Resources
+	}
+
+	if len(spec.NodeSelector) > 0 {
+		pod.NodeSelector = spec.NodeSelector
+	}
+	pod.Tolerations = append(pod.Tolerations, spec.Tolerations...)
+	pod.ImagePullSecrets = append(pod.ImagePullSecrets, spec.ImagePullSecrets...)
+}
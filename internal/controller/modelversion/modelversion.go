@@ -0,0 +1,199 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package modelversion
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlevent "sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/controller"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/ratelimiter"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	"github.com/crossplane/provider-driftprovider/apis/mlops/v1alpha1"
+	apisv1alpha1 "github.com/crossplane/provider-driftprovider/apis/v1alpha1"
+)
+
+const (
+	errNotModelVersion = "managed resource is not a ModelVersion custom resource"
+	errTrackPCUsage    = "cannot track ProviderConfig usage"
+	errGetCtrlDrift    = "cannot get CtrlDrift"
+
+	// labelCtrlDrift is set by the CtrlDrift controller on every ModelVersion
+	// it records to the name of its CtrlDrift.
+	labelCtrlDrift = "driftprovider.crossplane.io/ctrldrift"
+
+	// lastActiveInterval is how often the last active time of a ModelVersion
+	// whose model is served is refreshed.
+	lastActiveInterval = time.Hour
+)
+
+// Setup adds a controller that reconciles ModelVersion managed resources.
+// ModelVersions are created by the CtrlDrift controller. This controller only
+// reports whether their model is the one being served.
+func Setup(mgr ctrl.Manager, o controller.Options) error {
+	name := managed.ControllerName(v1alpha1.ModelVersionGroupKind)
+
+	r := managed.NewReconciler(mgr,
+		resource.ManagedKind(v1alpha1.ModelVersionGroupVersionKind),
+		managed.WithExternalConnecter(&connector{
+			kube:  mgr.GetClient(),
+			usage: resource.NewProviderConfigUsageTracker(mgr.GetClient(), &apisv1alpha1.ProviderConfigUsage{}),
+		}),
+		managed.WithLogger(o.Logger.WithValues("controller", name)),
+		managed.WithPollInterval(o.PollInterval),
+		managed.WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))))
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		WithOptions(o.ForControllerRuntime()).
+		For(&v1alpha1.ModelVersion{}).
+		Watches(&v1alpha1.CtrlDrift{}, enqueue_model_versions(mgr.GetClient(), o.Logger), builder.WithPredicates(active_model_version_changed())).
+		Complete(ratelimiter.NewReconciler(name, r, o.GlobalRateLimiter))
+}
+
+// active_model_version_changed filters the events of a CtrlDrift down to the
+// ones that may change which of its ModelVersions is active.
+func active_model_version_changed() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e ctrlevent.UpdateEvent) bool {
+			old, ok := e.ObjectOld.(*v1alpha1.CtrlDrift)
+			if !ok {
+				return true
+			}
+			updated, ok := e.ObjectNew.(*v1alpha1.CtrlDrift)
+			return !ok || old.Status.AtProvider.ActiveModelVersion != updated.Status.AtProvider.ActiveModelVersion
+		},
+		GenericFunc: func(ctrlevent.GenericEvent) bool { return false },
+	}
+}
+
+// enqueue_model_versions returns an event handler that enqueues the
+// ModelVersions of a CtrlDrift, so that they notice a rollout right away.
+func enqueue_model_versions(kube client.Reader, log logging.Logger) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+		mvs := &v1alpha1.ModelVersionList{}
+		if err := kube.List(ctx, mvs, client.MatchingLabels{labelCtrlDrift: obj.GetName()}); err != nil {
+			log.Debug("Cannot list ModelVersions of a CtrlDrift", "ctrldrift", obj.GetName(), "error", err)
+			return nil
+		}
+		requests := []reconcile.Request{}
+		for _, mv := range mvs.Items {
+			if mv.Spec.ForProvider.CtrlDrift == obj.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: mv.GetName()}})
+			}
+		}
+		return requests
+	})
+}
+
+// A connector is expected to produce an ExternalClient when its Connect method
+// is called.
+type connector struct {
+	kube  client.Client
+	usage resource.Tracker
+}
+
+// Connect tracks that the managed resource is using a ProviderConfig. A
+// ModelVersion needs nothing else from it.
+func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.ExternalClient, error) {
+	if _, ok := mg.(*v1alpha1.ModelVersion); !ok {
+		return nil, errors.New(errNotModelVersion)
+	}
+	if err := c.usage.Track(ctx, mg); err != nil {
+		return nil, errors.Wrap(err, errTrackPCUsage)
+	}
+	return &external{kube: c.kube}, nil
+}
+
+// An external observes the CtrlDrift that produced a ModelVersion. The model
+// itself lives on the data volume of the CtrlDrift and is never changed by
+// this controller.
+type external struct {
+	kube client.Client
+}
+
+func (c *external) Observe(ctx context.Context, mg resource.Managed) (managed.ExternalObservation, error) {
+	cr, ok := mg.(*v1alpha1.ModelVersion)
+	if !ok {
+		return managed.ExternalObservation{}, errors.New(errNotModelVersion)
+	}
+	if meta.WasDeleted(cr) {
+		// Deleting a ModelVersion only deletes the record.
+		return managed.ExternalObservation{ResourceExists: false}, nil
+	}
+
+	cd := &v1alpha1.CtrlDrift{}
+	err := c.kube.Get(ctx, types.NamespacedName{Name: cr.Spec.ForProvider.CtrlDrift}, cd)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return managed.ExternalObservation{}, errors.Wrap(err, errGetCtrlDrift)
+	}
+	// The record outlives its CtrlDrift, but its model is no longer served.
+	active := err == nil && cd.Status.AtProvider.ActiveModelVersion == cr.Spec.ForProvider.Version
+	// The last active time is only refreshed coarsely, so that the status is
+	// not written on every poll.
+	at := &cr.Status.AtProvider
+	if now := metav1.Now(); active != at.Active || (active && (at.LastActiveTime == nil || now.Sub(at.LastActiveTime.Time) >= lastActiveInterval)) {
+		at.LastActiveTime = &now
+	}
+	at.Active = active
+	cr.SetConditions(xpv1.Available())
+
+	return managed.ExternalObservation{
+		ResourceExists:    true,
+		ResourceUpToDate:  true,
+		ConnectionDetails: managed.ConnectionDetails{},
+	}, nil
+}
+
+func (c *external) Create(_ context.Context, mg resource.Managed) (managed.ExternalCreation, error) {
+	if _, ok := mg.(*v1alpha1.ModelVersion); !ok {
+		return managed.ExternalCreation{}, errors.New(errNotModelVersion)
+	}
+	return managed.ExternalCreation{}, nil
+}
+
+func (c *external) Update(_ context.Context, mg resource.Managed) (managed.ExternalUpdate, error) {
+	if _, ok := mg.(*v1alpha1.ModelVersion); !ok {
+		return managed.ExternalUpdate{}, errors.New(errNotModelVersion)
+	}
+	return managed.ExternalUpdate{}, nil
+}
+
+func (c *external) Delete(_ context.Context, mg resource.Managed) error {
+	if _, ok := mg.(*v1alpha1.ModelVersion); !ok {
+		return errors.New(errNotModelVersion)
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package modelversion
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlevent "sigs.k8s.io/controller-runtime/pkg/event"
+
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/provider-driftprovider/apis/mlops/v1alpha1"
+)
+
+func TestObserve(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	modelVersion := func() *v1alpha1.ModelVersion {
+		return &v1alpha1.ModelVersion{Spec: v1alpha1.ModelVersionSpec{ForProvider: v1alpha1.ModelVersionParameters{
+			CtrlDrift: "regression",
+			Version:   "20240501-123000",
+		}}}
+	}
+	activeSince := func(d time.Duration) *v1alpha1.ModelVersion {
+		mv := modelVersion()
+		t := metav1.NewTime(time.Now().Add(-d))
+		mv.Status.AtProvider.Active = true
+		mv.Status.AtProvider.LastActiveTime = &t
+		return mv
+	}
+	serving := func(version string) test.MockGetFn {
+		return func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+			obj.(*v1alpha1.CtrlDrift).Status.AtProvider.ActiveModelVersion = version
+			return nil
+		}
+	}
+	exists := managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: true, ConnectionDetails: managed.ConnectionDetails{}}
+
+	cases := map[string]struct {
+		reason     string
+		kube       client.Client
+		mg         resource.Managed
+		want       managed.ExternalObservation
+		wantErr    error
+		wantActive bool
+
+		// wantRefreshed is true when the last active time should be set to
+		// now.
+		wantRefreshed bool
+	}{
+		"NotModelVersion": {
+			reason:  "We should return an error if the managed resource is not a ModelVersion.",
+			kube:    &test.MockClient{},
+			mg:      &fake.Managed{},
+			wantErr: errors.New(errNotModelVersion),
+		},
+		"Active": {
+			reason:        "A ModelVersion whose model is served should be active.",
+			kube:          &test.MockClient{MockGet: serving("20240501-123000")},
+			mg:            modelVersion(),
+			want:          exists,
+			wantActive:    true,
+			wantRefreshed: true,
+		},
+		"StillActive": {
+			reason:     "The last active time of a ModelVersion that stays active should not be refreshed on every poll.",
+			kube:       &test.MockClient{MockGet: serving("20240501-123000")},
+			mg:         activeSince(time.Minute),
+			want:       exists,
+			wantActive: true,
+		},
+		"LongActive": {
+			reason:        "The last active time of a ModelVersion that stays active should be refreshed hourly.",
+			kube:          &test.MockClient{MockGet: serving("20240501-123000")},
+			mg:            activeSince(2 * time.Hour),
+			want:          exists,
+			wantActive:    true,
+			wantRefreshed: true,
+		},
+		"Deactivated": {
+			reason:        "The last active time of a ModelVersion whose model was just replaced should be refreshed.",
+			kube:          &test.MockClient{MockGet: serving("20240601-080000")},
+			mg:            activeSince(time.Minute),
+			want:          exists,
+			wantRefreshed: true,
+		},
+		"Inactive": {
+			reason: "A ModelVersion whose model was replaced should be inactive.",
+			kube:   &test.MockClient{MockGet: serving("20240601-080000")},
+			mg:     modelVersion(),
+			want:   exists,
+		},
+		"CtrlDriftDeleted": {
+			reason: "A ModelVersion should outlive its CtrlDrift as an inactive record.",
+			kube:   &test.MockClient{MockGet: test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{Resource: "ctrldrifts"}, "regression"))},
+			mg:     modelVersion(),
+			want:   exists,
+		},
+		"GetCtrlDriftError": {
+			reason:  "We should return an error if the CtrlDrift cannot be read.",
+			kube:    &test.MockClient{MockGet: test.NewMockGetFn(errBoom)},
+			mg:      modelVersion(),
+			wantErr: errors.Wrap(errBoom, errGetCtrlDrift),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := external{kube: tc.kube}
+			before := time.Now().Add(-time.Second)
+			got, err := e.Observe(context.Background(), tc.mg)
+			if diff := cmp.Diff(tc.wantErr, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if mv, ok := tc.mg.(*v1alpha1.ModelVersion); ok && mv.Status.AtProvider.Active != tc.wantActive {
+				t.Errorf("\n%s\nstatus.atProvider.active: want %t, got %t", tc.reason, tc.wantActive, mv.Status.AtProvider.Active)
+			}
+			if mv, ok := tc.mg.(*v1alpha1.ModelVersion); ok {
+				last := mv.Status.AtProvider.LastActiveTime
+				if refreshed := last != nil && last.After(before); refreshed != tc.wantRefreshed {
+					t.Errorf("\n%s\nstatus.atProvider.lastActiveTime: want refreshed %t, got %v", tc.reason, tc.wantRefreshed, last)
+				}
+			}
+		})
+	}
+}
+
+func TestActiveModelVersionChanged(t *testing.T) {
+	ctrlDrift := func(version string) *v1alpha1.CtrlDrift {
+		cd := &v1alpha1.CtrlDrift{ObjectMeta: metav1.ObjectMeta{Name: "regression"}}
+		cd.Status.AtProvider.ActiveModelVersion = version
+		return cd
+	}
+
+	cases := map[string]struct {
+		reason string
+		event  ctrlevent.UpdateEvent
+		want   bool
+	}{
+		"RolledOut": {
+			reason: "A CtrlDrift that serves another model should enqueue its ModelVersions.",
+			event:  ctrlevent.UpdateEvent{ObjectOld: ctrlDrift("20240501-123000"), ObjectNew: ctrlDrift("20240601-080000")},
+			want:   true,
+		},
+		"Unchanged": {
+			reason: "A CtrlDrift that still serves the same model should not enqueue its ModelVersions.",
+			event:  ctrlevent.UpdateEvent{ObjectOld: ctrlDrift("20240501-123000"), ObjectNew: ctrlDrift("20240501-123000")},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := active_model_version_changed().Update(tc.event); got != tc.want {
+				t.Errorf("\n%s\nactive_model_version_changed().Update(...): want %t, got %t", tc.reason, tc.want, got)
+			}
+		})
+	}
+}
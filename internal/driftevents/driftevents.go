@@ -0,0 +1,471 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package driftevents receives the drift statistics detectors publish to an
+// MQTT broker, so that a CtrlDrift reacts to drift as soon as it is reported
+// instead of at its next poll.
+package driftevents
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/pkg/errors"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/crossplane/provider-driftprovider/internal/driftsource"
+)
+
+const (
+	errConnect   = "cannot connect to MQTT broker %s"
+	errSubscribe = "cannot subscribe to MQTT topic %s"
+	errTimeout   = "timed out"
+
+	msgNoEvents = "no drift event received on MQTT topic %s yet"
+
+	// qos of the subscriptions. Drift statistics are cumulative, so a lost
+	// message is made up for by the next one.
+	qos = 0
+)
+
+// DefaultTimeout bounds how long connecting to a broker or subscribing to a
+// topic may take.
+var DefaultTimeout = 10 * time.Second
+
+// A Broker is an MQTT broker drift events are received from.
+type Broker struct {
+	// URL of the broker, e.g. "tcp://mosquitto.mqtt.svc:1883".
+	URL string
+
+	Username string
+	Password string
+
+	// TLS configures the connection to brokers with an ssl://, tls://,
+	// mqtts:// or wss:// URL.
+	TLS *tls.Config
+
+	// Revision distinguishes configurations of the same broker, such as
+	// rotated credentials. Each revision gets a connection of its own.
+	Revision string
+}
+
+func (b Broker) key() string {
+	return b.URL + "\x00" + b.Username + "\x00" + b.Revision
+}
+
+// clientID returns the client ID of the connection to the supplied broker.
+// Brokers drop the session of a client when another one connects with its ID,
+// so each connection, e.g. to two revisions of a broker, gets an ID of its
+// own.
+func (h *Hub) clientID(b Broker) string {
+	sum := sha256.Sum256([]byte(b.key()))
+	return h.baseClientID + "-" + hex.EncodeToString(sum[:4])
+}
+
+// An Event is the drift statistics last published to a topic.
+type Event struct {
+	Stats    driftsource.Stats
+	Received time.Time
+}
+
+// A subscription has a lock of its own, so that the message handler never
+// waits for the Hub, which may be waiting for the broker.
+type subscription struct {
+	broker string
+	topic  string
+
+	// ready is closed once the broker acknowledged the subscription, or
+	// err is set.
+	ready chan struct{}
+	err   error
+
+	mu     sync.Mutex
+	latest *Event
+	owners map[string]bool
+}
+
+// A connection is registered before it is established, so that concurrent
+// subscribers connect to a broker only once, without holding the Hub's lock.
+type connection struct {
+	// ready is closed once client is connected, or err is set.
+	ready  chan struct{}
+	err    error
+	client mqtt.Client
+
+	topics map[string]*subscription
+}
+
+// made returns true once the broker acknowledged the subscription.
+func (s *subscription) made() bool {
+	select {
+	case <-s.ready:
+		return s.err == nil
+	default:
+		return false
+	}
+}
+
+// connected returns true once the connection is established.
+func (c *connection) connected() bool {
+	select {
+	case <-c.ready:
+		return c.err == nil
+	default:
+		return false
+	}
+}
+
+// A Hub shares one MQTT connection per broker and one subscription per topic
+// between every CtrlDrift that receives its drift events from them. It keeps
+// the drift statistics last published to each topic.
+type Hub struct {
+	baseClientID string
+	notify       func(owner string)
+	log          logging.Logger
+
+	// newClient is overridden by tests.
+	newClient func(o *mqtt.ClientOptions) mqtt.Client
+	timeout   time.Duration
+
+	mu    sync.Mutex
+	conns map[string]*connection
+	subs  map[string]*subscription
+}
+
+// NewHub returns a Hub that identifies itself to brokers with client IDs
+// derived from the supplied one. notify is called with the owner of every subscription of a topic
+// whenever drift statistics are published to it.
+func NewHub(clientID string, notify func(owner string), log logging.Logger) *Hub {
+	return &Hub{
+		baseClientID: clientID,
+		notify:       notify,
+		log:          log,
+		newClient:    mqtt.NewClient,
+		timeout:      DefaultTimeout,
+		conns:        map[string]*connection{},
+		subs:         map[string]*subscription{},
+	}
+}
+
+func subKey(b Broker, topic string) string {
+	return b.key() + "\x00" + topic
+}
+
+// Subscribe subscribes owner to the drift events published to topic on the
+// supplied broker, connecting to it if needed. An owner has one subscription
+// at most, so any other subscription of owner is removed. It is idempotent.
+//
+// The Hub is not locked while the broker is connected to, subscribed with or
+// unsubscribed from, so that an unreachable broker does not hold up the
+// subscribers of others.
+func (h *Hub) Subscribe(b Broker, topic, owner string) error {
+	key := subKey(b, topic)
+
+	h.mu.Lock()
+	if s, ok := h.subs[key]; ok {
+		s.mu.Lock()
+		s.owners[owner] = true
+		s.mu.Unlock()
+		released := h.unsubscribe(owner, key)
+		h.mu.Unlock()
+		h.release(released)
+		<-s.ready
+		return s.err
+	}
+	c, ok := h.conns[b.key()]
+	if !ok {
+		c = &connection{ready: make(chan struct{}), topics: map[string]*subscription{}}
+		h.conns[b.key()] = c
+	}
+	s := &subscription{broker: b.key(), topic: topic, ready: make(chan struct{}), owners: map[string]bool{owner: true}}
+	c.topics[topic] = s
+	h.subs[key] = s
+	released := h.unsubscribe(owner, key)
+	h.mu.Unlock()
+	h.release(released)
+
+	if !ok {
+		c.client, c.err = h.connect(b)
+		close(c.ready)
+	}
+	<-c.ready
+	err := c.err
+	if err == nil {
+		err = errors.Wrapf(wait(c.client.Subscribe(topic, qos, h.receive(s)), h.timeout), errSubscribe, topic)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s.err = err
+	close(s.ready)
+	if err == nil && h.subs[key] == s {
+		return nil
+	}
+
+	// The subscription failed, or every owner left it while it was made.
+	if h.subs[key] == s {
+		delete(h.subs, key)
+	}
+	if c.topics[topic] == s {
+		delete(c.topics, topic)
+	}
+	switch {
+	case h.conns[b.key()] != c:
+		// The Hub was closed or the connection failed meanwhile.
+		if c.connected() {
+			c.client.Disconnect(0)
+		}
+	case len(c.topics) == 0 || c.err != nil:
+		delete(h.conns, b.key())
+		if c.connected() {
+			c.client.Disconnect(0)
+		}
+	case err == nil && c.topics[topic] == nil:
+		c.client.Unsubscribe(topic)
+	}
+	return err
+}
+
+// Unsubscribe removes owner from the subscriptions of every topic. Topics
+// without owners are unsubscribed from, and brokers without topics are
+// disconnected from.
+func (h *Hub) Unsubscribe(owner string) {
+	h.mu.Lock()
+	released := h.unsubscribe(owner, "")
+	h.mu.Unlock()
+	h.release(released)
+}
+
+// A release is a subscription without owners, or a connection without
+// subscriptions, that is still to be unsubscribed from or disconnected.
+type release struct {
+	conn  *connection
+	topic string
+	// disconnect is true when conn has no subscriptions left.
+	disconnect bool
+}
+
+// unsubscribe removes owner from every subscription but keep. It must be
+// called with mu held. The subscriptions and connections it removed are
+// returned, to be released once mu is no longer held.
+func (h *Hub) unsubscribe(owner, keep string) []release {
+	var released []release
+	for key, s := range h.subs {
+		if key == keep {
+			continue
+		}
+		s.mu.Lock()
+		delete(s.owners, owner)
+		owned := len(s.owners) > 0
+		s.mu.Unlock()
+		if owned {
+			continue
+		}
+		delete(h.subs, key)
+		c, ok := h.conns[s.broker]
+		if !ok {
+			continue
+		}
+		delete(c.topics, s.topic)
+		// Subscribe cleans up after the connections and the subscriptions
+		// that are still being made.
+		if !c.connected() {
+			continue
+		}
+		if len(c.topics) == 0 {
+			delete(h.conns, s.broker)
+			released = append(released, release{conn: c, disconnect: true})
+			continue
+		}
+		if !s.made() {
+			continue
+		}
+		released = append(released, release{conn: c, topic: s.topic})
+	}
+	return released
+}
+
+// release unsubscribes from and disconnects from what unsubscribe removed. It
+// must be called without mu held, so that a slow broker does not hold up the
+// subscribers of others.
+func (h *Hub) release(released []release) {
+	for _, r := range released {
+		if r.disconnect {
+			r.conn.client.Disconnect(250)
+			continue
+		}
+		if err := wait(r.conn.client.Unsubscribe(r.topic), h.timeout); err != nil {
+			h.log.Debug("Cannot unsubscribe from MQTT topic", "topic", r.topic, "error", err)
+		}
+
+		// The topic may have been subscribed to again meanwhile, before the
+		// broker handled the unsubscribe.
+		h.mu.Lock()
+		s, ok := r.conn.topics[r.topic]
+		h.mu.Unlock()
+		if ok && s.made() {
+			r.conn.client.Subscribe(r.topic, qos, h.receive(s))
+		}
+	}
+}
+
+// Latest returns the drift statistics last published to topic on the
+// supplied broker, if any were received.
+func (h *Hub) Latest(b Broker, topic string) (Event, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.subs[subKey(b, topic)]
+	if !ok {
+		return Event{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.latest == nil {
+		return Event{}, false
+	}
+	return *s.latest, true
+}
+
+// Close disconnects from every broker.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	var released []release
+	for key, c := range h.conns {
+		if c.connected() {
+			released = append(released, release{conn: c, disconnect: true})
+		}
+		delete(h.conns, key)
+	}
+	h.subs = map[string]*subscription{}
+	h.mu.Unlock()
+	h.release(released)
+}
+
+// Start blocks until ctx is done, then closes the Hub. It lets the Hub be
+// added to a controller manager.
+func (h *Hub) Start(ctx context.Context) error {
+	<-ctx.Done()
+	h.Close()
+	return nil
+}
+
+func (h *Hub) connect(b Broker) (mqtt.Client, error) {
+	o := mqtt.NewClientOptions().
+		AddBroker(b.URL).
+		SetClientID(h.clientID(b)).
+		SetUsername(b.Username).
+		SetPassword(b.Password).
+		SetCleanSession(true).
+		SetAutoReconnect(true).
+		SetConnectTimeout(h.timeout).
+		SetOnConnectHandler(func(client mqtt.Client) {
+			// A clean session loses its subscriptions on reconnect.
+			h.resubscribe(b, client)
+		})
+	if b.TLS != nil {
+		o.SetTLSConfig(b.TLS)
+	}
+
+	client := h.newClient(o)
+	if err := wait(client.Connect(), h.timeout); err != nil {
+		return nil, errors.Wrapf(err, errConnect, b.URL)
+	}
+	return client, nil
+}
+
+func (h *Hub) resubscribe(b Broker, client mqtt.Client) {
+	h.mu.Lock()
+	c, ok := h.conns[b.key()]
+	subs := []*subscription{}
+	// Subscribe makes the subscriptions after the first connect.
+	if ok && c.connected() {
+		for _, s := range c.topics {
+			subs = append(subs, s)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, s := range subs {
+		// Waiting here would block the client's callback goroutine.
+		client.Subscribe(s.topic, qos, h.receive(s))
+	}
+}
+
+// receive returns the handler of the messages published to the topic of the
+// supplied subscription.
+func (h *Hub) receive(s *subscription) mqtt.MessageHandler {
+	return func(_ mqtt.Client, m mqtt.Message) {
+		stats, err := driftsource.DecodeStats(bytes.NewReader(m.Payload()))
+		if err != nil {
+			h.log.Debug("Ignoring malformed drift event", "topic", m.Topic(), "error", err)
+			return
+		}
+
+		s.mu.Lock()
+		s.latest = &Event{Stats: stats, Received: time.Now()}
+		owners := make([]string, 0, len(s.owners))
+		for owner := range s.owners {
+			owners = append(owners, owner)
+		}
+		s.mu.Unlock()
+
+		for _, owner := range owners {
+			h.notify(owner)
+		}
+	}
+}
+
+// Source returns a drift source that reports the drift statistics last
+// published to topic on the supplied broker. Reading it subscribes owner to
+// the topic.
+func (h *Hub) Source(b Broker, topic, owner string) driftsource.Source {
+	return &source{hub: h, broker: b, topic: topic, owner: owner}
+}
+
+type source struct {
+	hub    *Hub
+	broker Broker
+	topic  string
+	owner  string
+}
+
+// Stats returns the drift statistics last published to the topic, or a
+// pending error until some were received.
+func (s *source) Stats(_ context.Context) (driftsource.Stats, error) {
+	if err := s.hub.Subscribe(s.broker, s.topic, s.owner); err != nil {
+		return driftsource.Stats{}, err
+	}
+	e, ok := s.hub.Latest(s.broker, s.topic)
+	if !ok {
+		return driftsource.Stats{}, driftsource.Pending(errors.Errorf(msgNoEvents, s.topic))
+	}
+	return e.Stats, nil
+}
+
+func wait(t mqtt.Token, timeout time.Duration) error {
+	if !t.WaitTimeout(timeout) {
+		return errors.New(errTimeout)
+	}
+	return t.Error()
+}
@@ -0,0 +1,467 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driftevents
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/crossplane/provider-driftprovider/internal/driftsource"
+)
+
+// A broker is a minimal MQTT broker stand-in. It accepts every connection and
+// delivers the messages published by the test to the connections subscribed
+// to their exact topic.
+type broker struct {
+	t  *testing.T
+	ln net.Listener
+
+	mu      sync.Mutex
+	subs    map[net.Conn]map[string]bool
+	clients []string
+
+	// silent makes the broker never acknowledge an unsubscribe.
+	silent bool
+}
+
+func newBroker(t *testing.T) *broker {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &broker{t: t, ln: ln, subs: map[net.Conn]map[string]bool{}}
+	go b.accept()
+	t.Cleanup(func() { _ = ln.Close() })
+	return b
+}
+
+func (b *broker) URL() string {
+	return "tcp://" + b.ln.Addr().String()
+}
+
+func (b *broker) accept() {
+	for {
+		conn, err := b.ln.Accept()
+		if err != nil {
+			return
+		}
+		go b.serve(conn)
+	}
+}
+
+func (b *broker) serve(conn net.Conn) {
+	defer func() {
+		b.mu.Lock()
+		delete(b.subs, conn)
+		b.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	for {
+		p, err := packets.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		var reply packets.ControlPacket
+		switch p := p.(type) {
+		case *packets.ConnectPacket:
+			b.mu.Lock()
+			b.clients = append(b.clients, p.ClientIdentifier)
+			b.mu.Unlock()
+			reply = packets.NewControlPacket(packets.Connack)
+		case *packets.SubscribePacket:
+			b.mu.Lock()
+			if b.subs[conn] == nil {
+				b.subs[conn] = map[string]bool{}
+			}
+			for _, topic := range p.Topics {
+				b.subs[conn][topic] = true
+			}
+			b.mu.Unlock()
+			ack := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+			ack.MessageID = p.MessageID
+			ack.ReturnCodes = p.Qoss
+			reply = ack
+		case *packets.UnsubscribePacket:
+			b.mu.Lock()
+			for _, topic := range p.Topics {
+				delete(b.subs[conn], topic)
+			}
+			silent := b.silent
+			b.mu.Unlock()
+			if silent {
+				continue
+			}
+			ack := packets.NewControlPacket(packets.Unsuback).(*packets.UnsubackPacket)
+			ack.MessageID = p.MessageID
+			reply = ack
+		case *packets.PingreqPacket:
+			reply = packets.NewControlPacket(packets.Pingresp)
+		case *packets.DisconnectPacket:
+			return
+		}
+		if reply != nil {
+			b.mu.Lock()
+			err := reply.Write(conn)
+			b.mu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// publish delivers payload to every connection subscribed to topic.
+func (b *broker) publish(topic, payload string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for conn, topics := range b.subs {
+		if !topics[topic] {
+			continue
+		}
+		p := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+		p.TopicName = topic
+		p.Payload = []byte(payload)
+		if err := p.Write(conn); err != nil {
+			b.t.Errorf("cannot publish to %s: %v", topic, err)
+		}
+	}
+}
+
+// subscribers returns the number of connections subscribed to topic.
+func (b *broker) subscribers(topic string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := 0
+	for _, topics := range b.subs {
+		if topics[topic] {
+			n++
+		}
+	}
+	return n
+}
+
+func TestHub(t *testing.T) {
+	const topic = "driftprovider/default/regression/drift"
+
+	type want struct {
+		notified []string
+		stats    driftsource.Stats
+		pending  bool
+	}
+
+	cases := map[string]struct {
+		reason   string
+		owners   []string
+		payloads []string
+		want     want
+	}{
+		"NoEvents": {
+			reason: "The source should be pending until drift statistics are published.",
+			owners: []string{"example"},
+			want:   want{pending: true},
+		},
+		"Event": {
+			reason: "Published drift statistics should be reported and notify the subscriber.",
+			owners: []string{"example"},
+			payloads: []string{
+				`{"drifted_samples": 100, "total_samples": 1000}`,
+			},
+			want: want{
+				notified: []string{"example"},
+				stats:    driftsource.Stats{DriftedSamples: 100, TotalSamples: 1000},
+			},
+		},
+		"Latest": {
+			reason: "The drift statistics published last should be reported.",
+			owners: []string{"example"},
+			payloads: []string{
+				`{"drifted_samples": 100}`,
+				`{"drifted_samples": 200}`,
+			},
+			want: want{
+				notified: []string{"example", "example"},
+				stats:    driftsource.Stats{DriftedSamples: 200},
+			},
+		},
+		"Malformed": {
+			reason: "Malformed drift events should be ignored.",
+			owners: []string{"example"},
+			payloads: []string{
+				`{"drifted_samples": 100}`,
+				`drifted`,
+				`{"total_samples": 100}`,
+			},
+			want: want{
+				notified: []string{"example"},
+				stats:    driftsource.Stats{DriftedSamples: 100},
+			},
+		},
+		"SharedTopic": {
+			reason: "Every subscriber of a topic should be notified.",
+			owners: []string{"a", "b"},
+			payloads: []string{
+				`{"drifted_samples": 1}`,
+			},
+			want: want{
+				notified: []string{"a", "b"},
+				stats:    driftsource.Stats{DriftedSamples: 1},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			b := newBroker(t)
+			notified := make(chan string, 10)
+			h := NewHub("test", func(owner string) { notified <- owner }, logging.NewNopLogger())
+			defer h.Close()
+
+			broker := Broker{URL: b.URL()}
+			for _, owner := range tc.owners {
+				if _, err := h.Source(broker, topic, owner).Stats(context.Background()); !driftsource.IsPending(err) {
+					t.Fatalf("\n%s\ns.Stats(...): want pending error, got %v", tc.reason, err)
+				}
+			}
+			if got := b.subscribers(topic); got != 1 {
+				t.Errorf("\n%s\nsubscribers: want 1 shared subscription, got %d", tc.reason, got)
+			}
+
+			got := []string{}
+			for _, payload := range tc.payloads {
+				b.publish(topic, payload)
+			}
+			for range tc.want.notified {
+				select {
+				case owner := <-notified:
+					got = append(got, owner)
+				case <-time.After(5 * time.Second):
+					t.Fatalf("\n%s\nnotify: timed out, notified %v", tc.reason, got)
+				}
+			}
+			// Give malformed or unexpected events a chance to arrive.
+			select {
+			case owner := <-notified:
+				t.Errorf("\n%s\nnotify: unexpected notification of %s", tc.reason, owner)
+			case <-time.After(100 * time.Millisecond):
+			}
+			if diff := cmp.Diff(len(tc.want.notified), len(got)); diff != "" {
+				t.Errorf("\n%s\nnotify: -want, +got:\n%s\n", tc.reason, diff)
+			}
+
+			stats, err := h.Source(broker, topic, tc.owners[0]).Stats(context.Background())
+			if driftsource.IsPending(err) != tc.want.pending {
+				t.Errorf("\n%s\ns.Stats(...): want pending %t, got error %v", tc.reason, tc.want.pending, err)
+			}
+			if diff := cmp.Diff(tc.want.stats, stats); diff != "" {
+				t.Errorf("\n%s\ns.Stats(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestHubUnsubscribe(t *testing.T) {
+	b := newBroker(t)
+	h := NewHub("test", func(string) {}, logging.NewNopLogger())
+	defer h.Close()
+	broker := Broker{URL: b.URL()}
+
+	for _, sub := range []struct{ topic, owner string }{{"a", "one"}, {"a", "two"}, {"b", "three"}} {
+		if err := h.Subscribe(broker, sub.topic, sub.owner); err != nil {
+			t.Fatalf("h.Subscribe(...): %v", err)
+		}
+	}
+
+	h.Unsubscribe("two")
+	h.Unsubscribe("three")
+	if diff := cmp.Diff([]int{1, 0}, []int{b.subscribers("a"), b.subscribers("b")}); diff != "" {
+		t.Errorf("Unsubscribe(...): topics still owned by one should stay subscribed, -want, +got:\n%s\n", diff)
+	}
+
+	// Moving one to another topic releases its subscription of a.
+	if err := h.Subscribe(broker, "c", "one"); err != nil {
+		t.Fatalf("h.Subscribe(...): %v", err)
+	}
+	if diff := cmp.Diff([]int{0, 1}, []int{b.subscribers("a"), b.subscribers("c")}); diff != "" {
+		t.Errorf("Subscribe(c, one): -want, +got:\n%s\n", diff)
+	}
+
+	h.Unsubscribe("one")
+	h.mu.Lock()
+	conns := len(h.conns)
+	h.mu.Unlock()
+	if conns != 0 {
+		t.Errorf("Unsubscribe(one): want the broker disconnected, got %d connections", conns)
+	}
+}
+
+func TestHubSlowUnsubscribe(t *testing.T) {
+	b := newBroker(t)
+	h := NewHub("test", func(string) {}, logging.NewNopLogger())
+	h.timeout = 2 * time.Second
+	defer h.Close()
+	broker := Broker{URL: b.URL()}
+
+	for _, sub := range []struct{ topic, owner string }{{"a", "one"}, {"b", "two"}} {
+		if err := h.Subscribe(broker, sub.topic, sub.owner); err != nil {
+			t.Fatalf("h.Subscribe(...): %v", err)
+		}
+	}
+
+	b.mu.Lock()
+	b.silent = true
+	b.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		h.Unsubscribe("one")
+		close(done)
+	}()
+	for b.subscribers("a") != 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Other subscribers should not wait for the broker to acknowledge the
+	// unsubscribe.
+	start := time.Now()
+	if err := h.Subscribe(broker, "c", "three"); err != nil {
+		t.Errorf("h.Subscribe(...): %v", err)
+	}
+	h.Latest(broker, "b")
+	if elapsed := time.Since(start); elapsed >= h.timeout/2 {
+		t.Errorf("h.Subscribe(...): waited %s for the unsubscribe of another owner", elapsed)
+	}
+	<-done
+}
+
+func TestHubClientID(t *testing.T) {
+	b := newBroker(t)
+	h := NewHub("test", func(string) {}, logging.NewNopLogger())
+	defer h.Close()
+
+	// Two revisions of a broker connect to it twice, and must not take over
+	// the session of each other.
+	for _, revision := range []string{"1", "2"} {
+		if err := h.Subscribe(Broker{URL: b.URL(), Revision: revision}, "a", "owner-"+revision); err != nil {
+			t.Fatalf("h.Subscribe(...): %v", err)
+		}
+	}
+	b.mu.Lock()
+	clients := append([]string{}, b.clients...)
+	b.mu.Unlock()
+	if len(clients) != 2 || clients[0] == clients[1] {
+		t.Errorf("h.Subscribe(...): want two connections with distinct client IDs, got %q", clients)
+	}
+	for _, id := range clients {
+		if !strings.HasPrefix(id, "test-") {
+			t.Errorf("h.Subscribe(...): want client ID %q to start with the ID of the Hub", id)
+		}
+	}
+}
+
+func TestHubConnectError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "tcp://" + ln.Addr().String()
+	_ = ln.Close()
+
+	h := NewHub("test", func(string) {}, logging.NewNopLogger())
+	h.timeout = time.Second
+	if err := h.Subscribe(Broker{URL: url}, "a", "one"); err == nil {
+		t.Errorf("h.Subscribe(...): want an error for an unreachable broker")
+	}
+}
+
+func TestHubConcurrentSubscribe(t *testing.T) {
+	// A broker that accepts connections but never acknowledges them.
+	silent, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close() //nolint:errcheck // Test listener.
+	go func() {
+		for {
+			conn, err := silent.Accept()
+			if err != nil {
+				return
+			}
+			go func() { _, _ = io.Copy(io.Discard, conn) }()
+		}
+	}()
+
+	b := newBroker(t)
+	h := NewHub("test", func(string) {}, logging.NewNopLogger())
+	h.timeout = 2 * time.Second
+	defer h.Close()
+
+	stuck := make(chan error, 1)
+	go func() { stuck <- h.Subscribe(Broker{URL: "tcp://" + silent.Addr().String()}, "a", "one") }()
+	for registered := false; !registered; {
+		time.Sleep(10 * time.Millisecond)
+		h.mu.Lock()
+		registered = len(h.conns) == 1
+		h.mu.Unlock()
+	}
+
+	// Subscribers of other brokers should not wait for the silent one, and
+	// concurrent subscribers of a topic should share its subscription.
+	start := time.Now()
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for _, owner := range []string{"two", "three", "four", "five", "six"} {
+		wg.Add(1)
+		go func(owner string) {
+			defer wg.Done()
+			errs <- h.Subscribe(Broker{URL: b.URL()}, "b", owner)
+		}(owner)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("h.Subscribe(...): %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed >= h.timeout {
+		t.Errorf("h.Subscribe(...): waited %s for the silent broker", elapsed)
+	}
+	if got := b.subscribers("b"); got != 1 {
+		t.Errorf("subscribers: want 1 shared subscription, got %d", got)
+	}
+
+	if err := <-stuck; err == nil {
+		t.Errorf("h.Subscribe(...): want an error for a broker that never acknowledges the connection")
+	}
+	h.mu.Lock()
+	conns, subs := len(h.conns), len(h.subs)
+	h.mu.Unlock()
+	if diff := cmp.Diff([]int{1, 1}, []int{conns, subs}); diff != "" {
+		t.Errorf("h.Subscribe(...): the failed connection should be forgotten, -want connections and subscriptions, +got:\n%s\n", diff)
+	}
+}
@@ -0,0 +1,270 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package driftsource reads the drift data written by a drift detector from
+// wherever the detector stores it.
+package driftsource
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	errOpen  = "cannot open drift data"
+	errCount = "cannot count drifted samples"
+)
+
+// Stats are the drift statistics reported by a Source.
+type Stats struct {
+	// DriftedSamples is the number of samples flagged as drifted.
+	DriftedSamples int64
+
+	// TotalSamples is the number of samples inspected by the detector. Zero
+	// means unknown.
+	TotalSamples int64
+
+	// OldestSample is the timestamp of the oldest drifted sample. The zero
+	// value means unknown.
+	OldestSample time.Time
+}
+
+// A Source reports the drift statistics of a detector.
+type Source interface {
+	// Stats returns the current drift statistics. It returns an error for
+	// which IsPending is true when they are not available yet, in which
+	// case the caller should keep the statistics it already has and try
+	// again later.
+	Stats(ctx context.Context) (Stats, error)
+}
+
+// A Reader opens files written by a detector.
+type Reader interface {
+	// Open opens the named file. It returns an error for which IsNotFound
+	// is true when the file does not exist, and one for which IsPending is
+	// true when the file cannot be read yet.
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+}
+
+// A RangeReader is a Reader that can also open a file from a byte offset on.
+type RangeReader interface {
+	Reader
+
+	// OpenRange opens the named file from the supplied offset on, and
+	// describes the whole file. When the file is not larger than the offset
+	// nothing is read.
+	OpenRange(ctx context.Context, name string, offset int64) (io.ReadCloser, FileInfo, error)
+}
+
+// A FileInfo describes a file opened by a RangeReader.
+type FileInfo struct {
+	// Size of the whole file, or -1 when unknown.
+	Size int64
+
+	// ID identifies the file: it changes when the file is replaced by
+	// another one, but not when records are appended to it, e.g. it is the
+	// device and inode of a local file. Empty when unknown.
+	ID string
+
+	// Version changes whenever the file is written, e.g. it is the
+	// modification time of a local file or the ETag of an object. Empty
+	// when unknown.
+	Version string
+}
+
+type notFound struct{ error }
+
+type pending struct{ error }
+
+// NotFound wraps err so that IsNotFound reports true for it.
+func NotFound(err error) error { return notFound{err} }
+
+// Pending wraps err so that IsPending reports true for it.
+func Pending(err error) error { return pending{err} }
+
+// IsNotFound reports whether err means that a file does not exist.
+func IsNotFound(err error) bool {
+	_, ok := errors.Cause(err).(notFound)
+	return ok
+}
+
+// IsPending reports whether err means that the data is not available yet.
+func IsPending(err error) bool {
+	_, ok := errors.Cause(err).(pending)
+	return ok
+}
+
+// A FileSource reports the drift statistics of a detector that appends every
+// drifted sample to a file, one record per sample.
+type FileSource struct {
+	Reader Reader
+	File   string
+
+	// Format of the file. It is inferred from the name of the file when
+	// empty.
+	Format Format
+
+	// Header is true when the first line of a CSV file names its columns.
+	Header bool
+
+	// TimestampColumn holds the time every sample was collected at. The
+	// oldest sample is not reported when empty.
+	TimestampColumn string
+
+	// Cursor remembers how far the file was read. A Reader that is a
+	// RangeReader only reads what was appended since. It is reset when the
+	// file shrinks or is replaced by another one. The file is read from the
+	// start every time when nil.
+	Cursor *Cursor
+}
+
+// Stats counts the records of the drift data file. A missing file means that
+// no drift was detected.
+func (s *FileSource) Stats(ctx context.Context) (Stats, error) {
+	c := s.Cursor
+	if c == nil {
+		c = &Cursor{}
+	}
+	format := s.Format
+	if format == "" {
+		format = FormatOf(s.File)
+	}
+
+	rc, from, info, err := s.open(ctx, *c)
+	if IsNotFound(err) {
+		*c = Cursor{}
+		return Stats{}, nil
+	}
+	if err != nil {
+		return Stats{}, errors.Wrap(err, errOpen)
+	}
+	defer rc.Close() //nolint:errcheck // Nothing was written.
+
+	if format == FormatParquet {
+		return s.parquet(ctx, rc, c, info)
+	}
+
+	scanner := &recordScanner{format: format, header: s.Header, column: s.TimestampColumn}
+	next, pending, oldest, err := scanner.scan(rc, from)
+	if err != nil {
+		return Stats{}, errors.Wrap(err, errCount)
+	}
+	*c = next
+	return Stats{DriftedSamples: next.Records + pending, OldestSample: older(next.Oldest, oldest)}, nil
+}
+
+// open opens the file where the supplied cursor left off, or from the start.
+// It returns the cursor the file is read from, and the description of the
+// file, whose size is -1 when it is unknown.
+func (s *FileSource) open(ctx context.Context, c Cursor) (io.ReadCloser, Cursor, FileInfo, error) {
+	rr, ok := s.Reader.(RangeReader)
+	if !ok {
+		rc, err := s.Reader.Open(ctx, s.File)
+		return rc, Cursor{}, FileInfo{Size: -1}, err
+	}
+	rc, info, err := rr.OpenRange(ctx, s.File, c.Offset)
+	if err != nil {
+		return nil, c, info, err
+	}
+	replaced := c.ID != "" && info.ID != "" && c.ID != info.ID
+	if info.Size >= c.Offset && !replaced {
+		if info.ID != "" {
+			c.ID = info.ID
+		}
+		return rc, c, info, nil
+	}
+	//the file was replaced by a smaller or another one
+	rc.Close() //nolint:errcheck,gosec // Nothing was read.
+	rc, info, err = rr.OpenRange(ctx, s.File, 0)
+	return rc, Cursor{ID: info.ID}, info, err
+}
+
+// parquet reads the footer of a Parquet file. The footer of the same file
+// with the same size and version as the one read last is not read again. With a
+// RangeReader only the footer is fetched, otherwise rc is read to its end.
+func (s *FileSource) parquet(ctx context.Context, rc io.Reader, c *Cursor, info FileInfo) (Stats, error) {
+	if info.Size >= 0 && info.Size == c.Offset && info.ID == c.ID && info.Version == c.Version {
+		return Stats{DriftedSamples: c.Records, OldestSample: c.Oldest}, nil
+	}
+
+	var footer []byte
+	var err error
+	if rr, ok := s.Reader.(RangeReader); ok && info.Size >= 8 {
+		footer, err = s.fetchFooter(ctx, rr, info.Size)
+	} else {
+		footer, err = parquetFooter(rc)
+	}
+	if err != nil {
+		return Stats{}, errors.Wrap(err, errCount)
+	}
+	st, err := parquetStats(footer, s.TimestampColumn)
+	if err != nil {
+		return Stats{}, errors.Wrap(err, errCount)
+	}
+	*c = Cursor{Offset: info.Size, ID: info.ID, Version: info.Version, Records: st.DriftedSamples, Oldest: st.OldestSample}
+	return st, nil
+}
+
+// fetchFooter reads the footer at the end of a Parquet file of the supplied
+// size: first its length, then the footer itself.
+func (s *FileSource) fetchFooter(ctx context.Context, rr RangeReader, size int64) ([]byte, error) {
+	read := func(offset int64) ([]byte, error) {
+		rc, _, err := rr.OpenRange(ctx, s.File, offset)
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close() //nolint:errcheck // Nothing was written.
+		return io.ReadAll(io.LimitReader(rc, size-offset))
+	}
+	end, err := read(size - 8)
+	if err != nil {
+		return nil, err
+	}
+	if len(end) != 8 || string(end[4:]) != parquetMagic {
+		return nil, errors.New(errNotParquet)
+	}
+	n := int64(binary.LittleEndian.Uint32(end))
+	if n > maxParquetFooter {
+		return nil, errors.Errorf(errFooterTooLarge, n, maxParquetFooter)
+	}
+	if n+8 > size {
+		return nil, errors.New(errNotParquet)
+	}
+	b, err := read(size - 8 - n)
+	if err != nil {
+		return nil, err
+	}
+	return footerOf(b)
+}
+
+// CountLines returns the number of lines of r that are not blank.
+func CountLines(r io.Reader) (int64, error) {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	n := int64(0)
+	for s.Scan() {
+		if len(bytes.TrimSpace(s.Bytes())) > 0 {
+			n++
+		}
+	}
+	return n, s.Err()
+}
@@ -0,0 +1,683 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driftsource
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func TestCountLines(t *testing.T) {
+	cases := map[string]struct {
+		in   string
+		want int64
+	}{
+		"Empty":          {in: "", want: 0},
+		"Lines":          {in: "a\nb\nc\n", want: 3},
+		"NoNewline":      {in: "a\nb", want: 2},
+		"BlankLines":     {in: "a\n\n  \nb\n\n", want: 2},
+		"CarriageReturn": {in: "a\r\nb\r\n", want: 2},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := CountLines(strings.NewReader(tc.in))
+			if err != nil {
+				t.Fatalf("CountLines(...): %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("CountLines(...): -want, +got:\n%s\n", diff)
+			}
+		})
+	}
+}
+
+func TestLocalFileSource(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "drift_data.csv"), []byte("1,2\n3,4\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		reason string
+		file   string
+		want   Stats
+	}{
+		"Exists": {
+			reason: "Every line of the drift data file should be a drifted sample.",
+			file:   "drift_data.csv",
+			want:   Stats{DriftedSamples: 2},
+		},
+		"Missing": {
+			reason: "A missing drift data file should mean no drift.",
+			file:   "missing.csv",
+			want:   Stats{},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := &FileSource{Reader: &LocalReader{Dir: dir}, File: tc.file}
+			got, err := s.Stats(context.Background())
+			if err != nil {
+				t.Fatalf("\n%s\ns.Stats(...): %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\ns.Stats(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestFileSourceFormats(t *testing.T) {
+	ts := func(s string) time.Time {
+		t, _ := time.Parse(time.RFC3339, s)
+		return t
+	}
+	flat, err := os.ReadFile(filepath.Join("testdata", "flat.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		reason string
+		source FileSource
+		data   []byte
+		want   Stats
+	}{
+		"CSVHeader": {
+			reason: "The header line of a CSV file should not be a drifted sample.",
+			source: FileSource{File: "drift_data.csv", Header: true},
+			data:   []byte("x,y\n1,2\n3,4\n"),
+			want:   Stats{DriftedSamples: 2},
+		},
+		"CSVTimestamp": {
+			reason: "The oldest timestamp of the timestamp column should be reported.",
+			source: FileSource{File: "drift_data.csv", Header: true, TimestampColumn: "time"},
+			data:   []byte("x,time\n1,2024-03-01T12:00:00Z\n2,2024-02-29 08:00:00\n3,not a time\n"),
+			want:   Stats{DriftedSamples: 3, OldestSample: ts("2024-02-29T08:00:00Z")},
+		},
+		"CSVTimestampIndex": {
+			reason: "Without a header the timestamp column should be an index.",
+			source: FileSource{File: "drift_data.csv", TimestampColumn: "1"},
+			data:   []byte("1,1709294400\n2,1709200000.5\n"),
+			want:   Stats{DriftedSamples: 2, OldestSample: time.Unix(1709200000, 5e8).UTC()},
+		},
+		"IncompleteLine": {
+			reason: "A last line that is still being written should be counted.",
+			source: FileSource{File: "drift_data.csv", Header: true},
+			data:   []byte("x,y\n1,2\n3,"),
+			want:   Stats{DriftedSamples: 2},
+		},
+		"JSONLines": {
+			reason: "Every object of a JSON Lines file should be a drifted sample.",
+			source: FileSource{File: "drift_data.jsonl", TimestampColumn: "ts"},
+			data:   []byte(`{"x": 1, "ts": "2024-03-01T12:00:00Z"}` + "\n\n" + `{"x": 2, "ts": 1709200000}` + "\n"),
+			want:   Stats{DriftedSamples: 2, OldestSample: time.Unix(1709200000, 0).UTC()},
+		},
+		"Parquet": {
+			reason: "The rows of a Parquet file should be read from its footer.",
+			source: FileSource{File: "drift_data.parquet"},
+			data:   parquetFile(4200, 0),
+			want:   Stats{DriftedSamples: 4200},
+		},
+		"ParquetTimestamp": {
+			reason: "The oldest timestamp should be the minimum of the statistics of the timestamp column.",
+			source: FileSource{File: "drift_data.parquet", TimestampColumn: "ts"},
+			data:   parquetFile(4200, 1709200000000, 1709294400000),
+			want:   Stats{DriftedSamples: 4200, OldestSample: time.UnixMilli(1709200000000).UTC()},
+		},
+		"ParquetWritten": {
+			reason: "The rows of a Parquet file written by a Parquet library should be read from its footer.",
+			source: FileSource{File: "drift_data.parquet"},
+			data:   flat,
+			want:   Stats{DriftedSamples: 10},
+		},
+		"ParquetWrittenNoTimestamp": {
+			reason: "A column whose statistics are no timestamps should not report the oldest sample.",
+			source: FileSource{File: "drift_data.parquet", TimestampColumn: "name"},
+			data:   flat,
+			want:   Stats{DriftedSamples: 10},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, tc.source.File), tc.data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			for _, reader := range []Reader{&LocalReader{Dir: dir}, openOnly{&LocalReader{Dir: dir}}} {
+				s := tc.source
+				s.Reader = reader
+				got, err := s.Stats(context.Background())
+				if err != nil {
+					t.Fatalf("\n%s\ns.Stats(...): %v", tc.reason, err)
+				}
+				if diff := cmp.Diff(tc.want, got); diff != "" {
+					t.Errorf("\n%s\ns.Stats(...) with %T: -want, +got:\n%s\n", tc.reason, reader, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestFileSourceCursor(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "drift_data.csv")
+	write := func(data string, replace bool) {
+		name := file
+		if replace {
+			name = filepath.Join(dir, "replacement.csv")
+		}
+		if err := os.WriteFile(name, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if replace {
+			if err := os.Rename(name, file); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	cursor := &Cursor{}
+	s := &FileSource{Reader: &LocalReader{Dir: dir}, File: "drift_data.csv", Header: true, Cursor: cursor}
+
+	steps := []struct {
+		reason  string
+		data    string
+		replace bool
+		want    int64
+		cursor  Cursor
+	}{
+		{
+			reason: "The cursor should stop before a line that is still being written.",
+			data:   "x,y\n1,2\n3,",
+			want:   2,
+			cursor: Cursor{Offset: 8, Records: 1, Header: []string{"x", "y"}},
+		},
+		{
+			reason: "The cursor should move past the appended lines.",
+			data:   "x,y\n1,2\n3,4\n5,6\n",
+			want:   3,
+			cursor: Cursor{Offset: 16, Records: 3, Header: []string{"x", "y"}},
+		},
+		{
+			reason: "Only the appended lines should be read, the earlier ones are not read again.",
+			data:   "x,y\nXXXXXXXXXXXX7,8\n",
+			want:   4,
+			cursor: Cursor{Offset: 20, Records: 4, Header: []string{"x", "y"}},
+		},
+		{
+			reason: "A file that shrank should be read again from the start.",
+			data:   "x,y\n1,2\n",
+			want:   1,
+			cursor: Cursor{Offset: 8, Records: 1, Header: []string{"x", "y"}},
+		},
+		{
+			reason:  "A file replaced by a larger one should be read again from the start.",
+			data:    "x,y\n1,2\n3,4\n5,6\n7,8\n9,0\n",
+			replace: true,
+			want:    5,
+			cursor:  Cursor{Offset: 24, Records: 5, Header: []string{"x", "y"}},
+		},
+	}
+
+	for i, step := range steps {
+		write(step.data, step.replace)
+		got, err := s.Stats(context.Background())
+		if err != nil {
+			t.Fatalf("\n%s\nstep %d: s.Stats(...): %v", step.reason, i, err)
+		}
+		if diff := cmp.Diff(step.want, got.DriftedSamples); diff != "" {
+			t.Errorf("\n%s\nstep %d: s.Stats(...): -want, +got:\n%s\n", step.reason, i, diff)
+		}
+		step.cursor.ID = localFileID(t, file)
+		if diff := cmp.Diff(step.cursor, *cursor); diff != "" {
+			t.Errorf("\n%s\nstep %d: s.Cursor: -want, +got:\n%s\n", step.reason, i, diff)
+		}
+	}
+}
+
+// localFileID returns the ID a LocalReader reports for the named file.
+func localFileID(t *testing.T, name string) string {
+	t.Helper()
+	fi, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fileID(fi)
+}
+
+func TestFileSourceParquetCursor(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "drift_data.parquet")
+	cursor := &Cursor{}
+	s := &FileSource{Reader: &LocalReader{Dir: dir}, File: "drift_data.parquet", Cursor: cursor}
+	modified := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		reason   string
+		data     []byte
+		modified time.Time
+		want     int64
+	}{
+		{
+			reason:   "The rows of the file should be read from its footer.",
+			data:     parquetFile(4200),
+			modified: modified,
+			want:     4200,
+		},
+		{
+			reason:   "A file rewritten with the same size should be read again.",
+			data:     parquetFile(4300),
+			modified: modified.Add(time.Second),
+			want:     4300,
+		},
+	}
+
+	for i, step := range steps {
+		if err := os.WriteFile(file, step.data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, step.modified, step.modified); err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.Stats(context.Background())
+		if err != nil {
+			t.Fatalf("\n%s\nstep %d: s.Stats(...): %v", step.reason, i, err)
+		}
+		if diff := cmp.Diff(step.want, got.DriftedSamples); diff != "" {
+			t.Errorf("\n%s\nstep %d: s.Stats(...): -want, +got:\n%s\n", step.reason, i, diff)
+		}
+		want := Cursor{Offset: int64(len(step.data)), ID: localFileID(t, file), Version: step.modified.Format(time.RFC3339Nano), Records: step.want}
+		if diff := cmp.Diff(want, *cursor); diff != "" {
+			t.Errorf("\n%s\nstep %d: s.Cursor: -want, +got:\n%s\n", step.reason, i, diff)
+		}
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	cases := map[string]struct {
+		in   string
+		want time.Time
+		ok   bool
+	}{
+		"RFC3339":     {in: "2024-03-01T12:00:00+01:00", want: time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC), ok: true},
+		"NoTimeZone":  {in: "2024-03-01T12:00:00.250", want: time.Date(2024, 3, 1, 12, 0, 0, 25e7, time.UTC), ok: true},
+		"Space":       {in: "2024-03-01 12:00:00", want: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), ok: true},
+		"UnixSeconds": {in: "1709294400", want: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), ok: true},
+		"Empty":       {in: " "},
+		"Invalid":     {in: "yesterday"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, ok := ParseTimestamp(tc.in)
+			if ok != tc.ok || !got.Equal(tc.want) {
+				t.Errorf("ParseTimestamp(%q): want %s, %t, got %s, %t", tc.in, tc.want, tc.ok, got, ok)
+			}
+		})
+	}
+}
+
+// openOnly hides the OpenRange method of a Reader.
+type openOnly struct{ Reader }
+
+// parquetFile returns a Parquet file with the supplied number of rows and no
+// data. Every timestamp is the minimum of the statistics of a row group of a
+// TIMESTAMP(MILLIS) column ts.
+func parquetFile(rows int64, mins ...int64) []byte {
+	ts := []byte("ts")
+	groups := make([][]byte, 0, len(mins))
+	for _, m := range mins {
+		v := make([]byte, 8)
+		binary.LittleEndian.PutUint64(v, uint64(m))
+		groups = append(groups, compactStruct(
+			compactField{1, 9, compactList(12, compactStruct(
+				compactField{3, 12, compactStruct(
+					compactField{1, 5, compactInt(2)},
+					compactField{3, 9, compactList(8, compactBinary(ts))},
+					compactField{12, 12, compactStruct(compactField{6, 8, compactBinary(v)})},
+				)},
+			))},
+			compactField{3, 6, compactInt(rows / int64(len(mins)))},
+		))
+	}
+	footer := compactStruct(
+		compactField{1, 5, compactInt(1)},
+		compactField{2, 9, compactList(12,
+			compactStruct(compactField{4, 8, compactBinary([]byte("schema"))}, compactField{5, 5, compactInt(1)}),
+			compactStruct(
+				compactField{1, 5, compactInt(2)},
+				compactField{4, 8, compactBinary(ts)},
+				compactField{10, 12, compactStruct(compactField{8, 12, compactStruct(
+					compactField{1, 1, nil},
+					compactField{2, 12, compactStruct(compactField{1, 12, compactStruct()})},
+				)})},
+			),
+		)},
+		compactField{3, 6, compactInt(rows)},
+		compactField{4, 9, compactList(12, groups...)},
+		compactField{6, 8, compactBinary([]byte("test"))},
+	)
+	b := append([]byte("PAR1"), make([]byte, 64)...)
+	b = append(b, footer...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(footer)))
+	return append(b, "PAR1"...)
+}
+
+type compactField struct {
+	id    int16
+	typ   byte
+	value []byte
+}
+
+func compactStruct(fields ...compactField) []byte {
+	b, last := []byte{}, int16(0)
+	for _, f := range fields {
+		b = append(b, byte(f.id-last)<<4|f.typ)
+		b = append(b, f.value...)
+		last = f.id
+	}
+	return append(b, 0)
+}
+
+func compactInt(v int64) []byte {
+	return binary.AppendUvarint(nil, uint64(v<<1)^uint64(v>>63))
+}
+
+func compactBinary(v []byte) []byte {
+	return append(binary.AppendUvarint(nil, uint64(len(v))), v...)
+}
+
+func compactList(typ byte, elems ...[]byte) []byte {
+	b := []byte{byte(len(elems))<<4 | typ}
+	for _, e := range elems {
+		b = append(b, e...)
+	}
+	return b
+}
+
+func TestHTTPSource(t *testing.T) {
+	cases := map[string]struct {
+		reason  string
+		handler http.HandlerFunc
+		want    Stats
+		wantErr bool
+	}{
+		"Stats": {
+			reason: "The statistics served by the detector should be returned.",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = io.WriteString(w, `{"drifted_samples": 120, "total_samples": 4000}`)
+			},
+			want: Stats{DriftedSamples: 120, TotalSamples: 4000},
+		},
+		"Error": {
+			reason: "An error status should be returned as an error.",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			wantErr: true,
+		},
+		"Malformed": {
+			reason: "A body that is not JSON should be returned as an error.",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = io.WriteString(w, "drifted")
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(tc.handler)
+			defer srv.Close()
+
+			got, err := (&HTTPSource{URL: srv.URL + "/stats"}).Stats(context.Background())
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("\n%s\ns.Stats(...): unexpected error %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\ns.Stats(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestS3Reader(t *testing.T) {
+	creds := &S3Credentials{AccessKeyID: "minio", SecretAccessKey: "minio123"}
+	parquet := parquetFile(4200)
+	objects := map[string]string{
+		"/drift/regression/drift_data.csv":     "1,2\n3,4\n5,6\n",
+		"/drift/regression/drift_data.parquet": string(parquet),
+	}
+
+	// A stand-in for MinIO that serves a single object to signed requests.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=minio/") || !strings.Contains(auth, "/"+DefaultS3Region+"/s3/aws4_request") {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = io.WriteString(w, "<Error><Code>AccessDenied</Code></Error>")
+			return
+		}
+		data, ok := objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, "<Error><Code>NoSuchKey</Code></Error>")
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, path.Base(r.URL.Path), time.Time{}, strings.NewReader(data))
+	}))
+	defer srv.Close()
+
+	cases := map[string]struct {
+		reason  string
+		reader  *S3Reader
+		file    string
+		cursor  *Cursor
+		want    Stats
+		wantErr bool
+	}{
+		"Object": {
+			reason: "Every line of the drift data object should be a drifted sample.",
+			reader: &S3Reader{Endpoint: srv.URL, Bucket: "drift", Prefix: "regression/", Credentials: creds},
+			file:   "drift_data.csv",
+			want:   Stats{DriftedSamples: 3},
+		},
+		"Range": {
+			reason: "Only the lines appended since the cursor should be requested.",
+			reader: &S3Reader{Endpoint: srv.URL, Bucket: "drift", Prefix: "regression/", Credentials: creds},
+			file:   "drift_data.csv",
+			cursor: &Cursor{Offset: 8, Records: 10},
+			want:   Stats{DriftedSamples: 11},
+		},
+		"Unchanged": {
+			reason: "An object that did not grow should keep the count of the cursor.",
+			reader: &S3Reader{Endpoint: srv.URL, Bucket: "drift", Prefix: "regression/", Credentials: creds},
+			file:   "drift_data.csv",
+			cursor: &Cursor{Offset: 12, Records: 10},
+			want:   Stats{DriftedSamples: 10},
+		},
+		"Shrunk": {
+			reason: "An object smaller than the cursor should be read again from the start.",
+			reader: &S3Reader{Endpoint: srv.URL, Bucket: "drift", Prefix: "regression/", Credentials: creds},
+			file:   "drift_data.csv",
+			cursor: &Cursor{Offset: 100, Records: 10},
+			want:   Stats{DriftedSamples: 3},
+		},
+		"Replaced": {
+			reason: "An object with another ETag should be read again from the start.",
+			reader: &S3Reader{Endpoint: srv.URL, Bucket: "drift", Prefix: "regression/", Credentials: creds},
+			file:   "drift_data.csv",
+			cursor: &Cursor{Offset: 8, ID: `"v0"`, Records: 10},
+			want:   Stats{DriftedSamples: 3},
+		},
+		"ParquetUnchanged": {
+			reason: "A Parquet object with the same size and ETag should keep the count of the cursor.",
+			reader: &S3Reader{Endpoint: srv.URL, Bucket: "drift", Prefix: "regression/", Credentials: creds},
+			file:   "drift_data.parquet",
+			cursor: &Cursor{Offset: int64(len(parquet)), ID: `"v1"`, Version: `"v1"`, Records: 10},
+			want:   Stats{DriftedSamples: 10},
+		},
+		"ParquetRewritten": {
+			reason: "A Parquet object with the same size but another ETag should be read again.",
+			reader: &S3Reader{Endpoint: srv.URL, Bucket: "drift", Prefix: "regression/", Credentials: creds},
+			file:   "drift_data.parquet",
+			cursor: &Cursor{Offset: int64(len(parquet)), ID: `"v0"`, Version: `"v0"`, Records: 10},
+			want:   Stats{DriftedSamples: 4200},
+		},
+		"NoSuchKey": {
+			reason: "A missing drift data object should mean no drift.",
+			reader: &S3Reader{Endpoint: srv.URL, Bucket: "drift", Credentials: creds},
+			file:   "drift_data.csv",
+			want:   Stats{},
+		},
+		"AccessDenied": {
+			reason:  "An unauthorised request should be returned as an error.",
+			reader:  &S3Reader{Endpoint: srv.URL, Bucket: "drift", Prefix: "regression/"},
+			file:    "drift_data.csv",
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := (&FileSource{Reader: tc.reader, File: tc.file, Cursor: tc.cursor}).Stats(context.Background())
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("\n%s\ns.Stats(...): unexpected error %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\ns.Stats(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestPodReader(t *testing.T) {
+	template := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "regression-drift-reader", Namespace: "default"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "reader", Image: "busybox:1.36"}}},
+	}
+	withPhase := func(phase corev1.PodPhase, exitCode int32) *corev1.Pod {
+		p := template.DeepCopy()
+		p.Status.Phase = phase
+		p.Status.ContainerStatuses = []corev1.ContainerStatus{{
+			Name:  "reader",
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: exitCode}},
+		}}
+		return p
+	}
+	withSize := func(p *corev1.Pod, size string) *corev1.Pod {
+		p.Status.ContainerStatuses[0].State.Terminated.Message = size
+		return p
+	}
+
+	cases := map[string]struct {
+		reason      string
+		existing    []*corev1.Pod
+		want        Stats
+		wantErr     error
+		wantPending bool
+		wantPod     bool
+	}{
+		"Start": {
+			reason:      "The first read should start the reader pod and report the data as pending.",
+			wantPending: true,
+			wantPod:     true,
+		},
+		"Running": {
+			reason:      "A running reader pod should report the data as pending.",
+			existing:    []*corev1.Pod{withPhase(corev1.PodRunning, 0)},
+			wantPending: true,
+			wantPod:     true,
+		},
+		"Succeeded": {
+			reason:   "The log of a completed reader pod should be read and the pod deleted.",
+			existing: []*corev1.Pod{withPhase(corev1.PodSucceeded, 0)},
+			// The fake clientset always serves the log "fake logs".
+			want: Stats{DriftedSamples: 1},
+		},
+		"Logged": {
+			reason:   "A log that holds every byte the reader pod printed should be read.",
+			existing: []*corev1.Pod{withSize(withPhase(corev1.PodSucceeded, 0), "9\n")},
+			want:     Stats{DriftedSamples: 1},
+		},
+		"Truncated": {
+			reason:   "A log that holds fewer bytes than the reader pod printed should be returned as an error.",
+			existing: []*corev1.Pod{withSize(withPhase(corev1.PodSucceeded, 0), "20971520\n")},
+			wantErr:  errors.Wrap(errors.Errorf(errTruncated, "default", "regression-drift-reader", 9, 20971520), errCount),
+		},
+		"Missing": {
+			reason:   "A reader pod that did not find the file should mean no drift.",
+			existing: []*corev1.Pod{withPhase(corev1.PodFailed, missingExitCode)},
+			want:     Stats{},
+		},
+		"Failed": {
+			reason:   "A reader pod that failed otherwise should be returned as an error.",
+			existing: []*corev1.Pod{withPhase(corev1.PodFailed, 1)},
+			wantErr:  errors.Wrap(errors.Errorf(errPodFailed, "default", "regression-drift-reader", ""), errOpen),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			client := kfake.NewSimpleClientset()
+			for _, p := range tc.existing {
+				if _, err := client.CoreV1().Pods(p.Namespace).Create(context.Background(), p, metav1.CreateOptions{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			s := &FileSource{Reader: &PodReader{Client: client, Pod: template, MountPath: "/var/data/"}, File: "drift_data.csv"}
+			got, err := s.Stats(context.Background())
+			if IsPending(err) != tc.wantPending {
+				t.Errorf("\n%s\nIsPending(...): want %t, got error %v", tc.reason, tc.wantPending, err)
+			}
+			if !tc.wantPending {
+				if diff := cmp.Diff(tc.wantErr, err, test.EquateErrors()); diff != "" {
+					t.Errorf("\n%s\ns.Stats(...): -want error, +got error:\n%s\n", tc.reason, diff)
+				}
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\ns.Stats(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+
+			pods, _ := client.CoreV1().Pods("default").List(context.Background(), metav1.ListOptions{})
+			if gotPod := len(pods.Items) > 0; gotPod != tc.wantPod {
+				t.Errorf("\n%s\nreader pod exists: want %t, got %t", tc.reason, tc.wantPod, gotPod)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driftsource
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	errLineTooLong     = "record at byte %d is longer than %d bytes"
+	errReadHeader      = "cannot parse CSV header"
+	errTimestampColumn = "timestamp column %q not found in CSV header"
+
+	// maxLine bounds the length of a record of a CSV or JSON Lines file.
+	maxLine = 16 * 1024 * 1024
+)
+
+// A Format of a drift data file.
+type Format string
+
+// Supported formats.
+const (
+	// FormatCSV files hold one sample per line, optionally preceded by a
+	// header line naming the columns.
+	FormatCSV Format = "CSV"
+
+	// FormatJSONLines files hold one JSON object per line.
+	FormatJSONLines Format = "JSONLines"
+
+	// FormatParquet files are Apache Parquet files. Only their footer is
+	// read.
+	FormatParquet Format = "Parquet"
+)
+
+// FormatOf infers the format of the named file from its extension. Files
+// with an unknown extension are read as CSV.
+func FormatOf(name string) Format {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".jsonl", ".ndjson":
+		return FormatJSONLines
+	case ".parquet":
+		return FormatParquet
+	}
+	return FormatCSV
+}
+
+// A Cursor remembers how far a drift data file was read, so that the next
+// read only reads the records appended since. It only advances past
+// complete records: a last line that is still being written is counted, but
+// read again next time.
+type Cursor struct {
+	// Offset is the byte offset right after the last complete record read.
+	// For a Parquet file it is the size of the file whose footer was read.
+	Offset int64
+
+	// ID identifies the file read, see FileInfo. The cursor is reset when
+	// the file is replaced by another one.
+	ID string
+
+	// Version is the version of the Parquet file whose footer was read, see
+	// FileInfo.
+	Version string
+
+	// Records is the number of records before Offset.
+	Records int64
+
+	// Oldest is the oldest timestamp of the records before Offset. The
+	// zero value means unknown.
+	Oldest time.Time
+
+	// Header is the header line of a CSV file, once read.
+	Header []string
+}
+
+// ParseTimestamp parses the timestamp of a record. RFC 3339 timestamps, with
+// or without a time zone and with a space or a T between the date and the
+// time, and seconds since the Unix epoch are supported.
+func ParseTimestamp(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02 15:04:05.999999999Z07:00", "2006-01-02 15:04:05.999999999"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(frac*1e9)).UTC(), true
+	}
+	return time.Time{}, false
+}
+
+// older returns the older of two timestamps, ignoring zero ones.
+func older(a, b time.Time) time.Time {
+	if a.IsZero() || (!b.IsZero() && b.Before(a)) {
+		return b
+	}
+	return a
+}
+
+// A recordScanner reads the records of a CSV or JSON Lines file, one per
+// line.
+type recordScanner struct {
+	format Format
+	header bool
+
+	// column holds the timestamp of a record. Empty means none.
+	column string
+}
+
+// scan reads the records of r, which starts at the offset of the supplied
+// cursor. It returns the cursor advanced past the complete records of r, and
+// the number and oldest timestamp of the records of an incomplete last line.
+func (s *recordScanner) scan(r io.Reader, c Cursor) (Cursor, int64, time.Time, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+	index := -1
+	for {
+		line, err := br.ReadSlice('\n')
+		for errors.Is(err, bufio.ErrBufferFull) {
+			if len(line) > maxLine {
+				return c, 0, time.Time{}, errors.Errorf(errLineTooLong, c.Offset, maxLine)
+			}
+			var more []byte
+			more, err = br.ReadSlice('\n')
+			line = append(append([]byte{}, line...), more...)
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return c, 0, time.Time{}, err
+		}
+		complete := err == nil
+		if len(line) == 0 {
+			return c, 0, time.Time{}, nil
+		}
+
+		next := c
+		if complete {
+			next.Offset += int64(len(line))
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			if s.format == FormatCSV && s.header && next.Header == nil {
+				header, err := csv.NewReader(bytes.NewReader(line)).Read()
+				if err != nil {
+					return c, 0, time.Time{}, errors.Wrap(err, errReadHeader)
+				}
+				next.Header = header
+			} else {
+				if index < 0 && s.column != "" && s.format == FormatCSV {
+					if index, err = s.index(next.Header); err != nil {
+						return c, 0, time.Time{}, err
+					}
+				}
+				next.Records++
+				next.Oldest = older(next.Oldest, s.timestamp(line, index))
+			}
+		}
+		if !complete {
+			//count the last line, but read it again once it is complete
+			return c, next.Records - c.Records, next.Oldest, nil
+		}
+		c = next
+	}
+}
+
+// index returns the index of the timestamp column of a CSV file. Without a
+// header the column is a zero based index.
+func (s *recordScanner) index(header []string) (int, error) {
+	for i, name := range header {
+		if strings.TrimSpace(name) == s.column {
+			return i, nil
+		}
+	}
+	if i, err := strconv.Atoi(s.column); err == nil && i >= 0 && header == nil {
+		return i, nil
+	}
+	return 0, errors.Errorf(errTimestampColumn, s.column)
+}
+
+// timestamp returns the timestamp of a record, or the zero time when it has
+// none or it cannot be parsed.
+func (s *recordScanner) timestamp(line []byte, index int) time.Time {
+	if s.column == "" {
+		return time.Time{}
+	}
+	var v string
+	switch s.format {
+	case FormatJSONLines:
+		fields := map[string]json.RawMessage{}
+		if err := json.Unmarshal(line, &fields); err != nil {
+			return time.Time{}
+		}
+		raw := fields[s.column]
+		if err := json.Unmarshal(raw, &v); err != nil {
+			v = string(raw)
+		}
+	default:
+		fields, err := csv.NewReader(bytes.NewReader(line)).Read()
+		if err != nil || index >= len(fields) {
+			return time.Time{}
+		}
+		v = fields[index]
+	}
+	t, _ := ParseTimestamp(v)
+	return t
+}
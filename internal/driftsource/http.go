@@ -0,0 +1,92 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driftsource
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	errRequestStats = "cannot request drift statistics"
+	errDecodeStats  = "cannot decode drift statistics"
+	errStatsStatus  = "drift statistics endpoint returned %s"
+
+	errNoDriftedSamples = "drifted_samples is missing"
+)
+
+// DefaultHTTPClient is used by the sources that are not given an HTTP client.
+var DefaultHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// An HTTPSource reads the drift statistics from an endpoint served by the
+// detector. The endpoint answers a GET with a JSON object such as
+// {"drifted_samples": 120, "total_samples": 4000}.
+type HTTPSource struct {
+	URL    string
+	Client *http.Client
+}
+
+// Stats requests the drift statistics from the endpoint.
+func (s *HTTPSource) Stats(ctx context.Context) (Stats, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return Stats{}, errors.Wrap(err, errRequestStats)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client(s.Client).Do(req)
+	if err != nil {
+		return Stats{}, errors.Wrap(err, errRequestStats)
+	}
+	defer resp.Body.Close() //nolint:errcheck // Nothing was written.
+	if resp.StatusCode != http.StatusOK {
+		return Stats{}, errors.Errorf(errStatsStatus, resp.Status)
+	}
+
+	st, err := DecodeStats(io.LimitReader(resp.Body, 1<<20))
+	return st, errors.Wrap(err, errDecodeStats)
+}
+
+type stats struct {
+	DriftedSamples *int64 `json:"drifted_samples"`
+	TotalSamples   int64  `json:"total_samples"`
+}
+
+// DecodeStats decodes drift statistics encoded as a JSON object such as
+// {"drifted_samples": 120, "total_samples": 4000}. The total is optional.
+func DecodeStats(r io.Reader) (Stats, error) {
+	st := stats{}
+	if err := json.NewDecoder(r).Decode(&st); err != nil {
+		return Stats{}, err
+	}
+	if st.DriftedSamples == nil {
+		return Stats{}, errors.New(errNoDriftedSamples)
+	}
+	return Stats{DriftedSamples: *st.DriftedSamples, TotalSamples: st.TotalSamples}, nil
+}
+
+func client(c *http.Client) *http.Client {
+	if c == nil {
+		return DefaultHTTPClient
+	}
+	return c
+}
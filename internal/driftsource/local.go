@@ -0,0 +1,59 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driftsource
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// A LocalReader opens files in a directory of the provider's own pod.
+type LocalReader struct {
+	Dir string
+}
+
+// Open opens the named file in the directory.
+func (r *LocalReader) Open(_ context.Context, name string) (io.ReadCloser, error) {
+	f, err := os.Open(filepath.Join(r.Dir, name))
+	if os.IsNotExist(err) {
+		return nil, NotFound(err)
+	}
+	return f, err
+}
+
+// OpenRange opens the named file in the directory from the supplied offset
+// on. The file is identified by its device and inode, and its version is its
+// modification time.
+func (r *LocalReader) OpenRange(ctx context.Context, name string, offset int64) (io.ReadCloser, FileInfo, error) {
+	rc, err := r.Open(ctx, name)
+	if err != nil {
+		return nil, FileInfo{}, err
+	}
+	f := rc.(*os.File)
+	fi, err := f.Stat()
+	if err == nil && offset > 0 {
+		_, err = f.Seek(min(offset, fi.Size()), io.SeekStart)
+	}
+	if err != nil {
+		f.Close() //nolint:errcheck,gosec // Nothing was read.
+		return nil, FileInfo{}, err
+	}
+	return f, FileInfo{Size: fi.Size(), ID: fileID(fi), Version: fi.ModTime().UTC().Format(time.RFC3339Nano)}, nil
+}
@@ -0,0 +1,388 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driftsource
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	errNotParquet     = "not a Parquet file"
+	errParquetFooter  = "cannot decode Parquet footer"
+	errFooterTooLarge = "Parquet footer of %d bytes is larger than %d bytes"
+	errThrift         = "malformed Thrift data at byte %d"
+
+	parquetMagic = "PAR1"
+
+	// maxParquetFooter bounds the size of the footer of a Parquet file.
+	maxParquetFooter = 16 * 1024 * 1024
+
+	// maxThriftDepth bounds the nesting of the Thrift structures of a
+	// footer.
+	maxThriftDepth = 32
+)
+
+// Field IDs of the Thrift structures of a Parquet footer, see
+// https://github.com/apache/parquet-format/blob/master/src/main/thrift/parquet.thrift
+const (
+	fileMetaDataSchema    = 2
+	fileMetaDataNumRows   = 3
+	fileMetaDataRowGroups = 4
+
+	schemaElementType          = 1
+	schemaElementName          = 4
+	schemaElementConvertedType = 6
+	schemaElementLogicalType   = 10
+
+	logicalTypeTimestamp = 8
+	timestampTypeUnit    = 2
+	timeUnitMillis       = 1
+	timeUnitMicros       = 2
+	timeUnitNanos        = 3
+
+	convertedTypeTimestampMillis = 9
+	convertedTypeTimestampMicros = 10
+
+	physicalTypeInt64     = 2
+	physicalTypeByteArray = 6
+
+	rowGroupColumns          = 1
+	columnChunkMetaData      = 3
+	columnMetaDataPath       = 3
+	columnMetaDataStatistics = 12
+	statisticsMin            = 2
+	statisticsMinValue       = 6
+)
+
+// parquetStats reads the number of rows of a Parquet file and the oldest
+// timestamp of its timestamp column from its footer. The timestamp is the
+// minimum of the statistics of the column, which must be an INT64 timestamp
+// or a string.
+func parquetStats(footer []byte, column string) (Stats, error) {
+	md, err := (&thriftReader{b: footer}).structure(0)
+	if err != nil {
+		return Stats{}, errors.Wrap(err, errParquetFooter)
+	}
+	rows, _ := md[fileMetaDataNumRows].(int64)
+	st := Stats{DriftedSamples: rows}
+	if column == "" {
+		return st, nil
+	}
+
+	physical, unit := int64(-1), time.Duration(0)
+	schema, _ := md[fileMetaDataSchema].([]interface{})
+	for _, e := range schema {
+		el, _ := e.(thriftStruct)
+		if name, _ := el[schemaElementName].([]byte); string(name) != column {
+			continue
+		}
+		physical, _ = el[schemaElementType].(int64)
+		unit = timestampUnit(el)
+	}
+
+	groups, _ := md[fileMetaDataRowGroups].([]interface{})
+	for _, g := range groups {
+		group, _ := g.(thriftStruct)
+		columns, _ := group[rowGroupColumns].([]interface{})
+		for _, c := range columns {
+			chunk, _ := c.(thriftStruct)
+			cmd, _ := chunk[columnChunkMetaData].(thriftStruct)
+			if p, _ := cmd[columnMetaDataPath].([]interface{}); len(p) != 1 || !bytes.Equal(asBytes(p[0]), []byte(column)) {
+				continue
+			}
+			stats, _ := cmd[columnMetaDataStatistics].(thriftStruct)
+			lowest := asBytes(stats[statisticsMinValue])
+			if lowest == nil {
+				lowest = asBytes(stats[statisticsMin])
+			}
+			st.OldestSample = older(st.OldestSample, parquetTimestamp(lowest, physical, unit))
+		}
+	}
+	return st, nil
+}
+
+// timestampUnit returns the unit of an INT64 timestamp column, or zero for a
+// column that is no timestamp.
+func timestampUnit(el thriftStruct) time.Duration {
+	if lt, ok := el[schemaElementLogicalType].(thriftStruct); ok {
+		if ts, ok := lt[logicalTypeTimestamp].(thriftStruct); ok {
+			u, _ := ts[timestampTypeUnit].(thriftStruct)
+			switch {
+			case u[timeUnitMillis] != nil:
+				return time.Millisecond
+			case u[timeUnitMicros] != nil:
+				return time.Microsecond
+			case u[timeUnitNanos] != nil:
+				return time.Nanosecond
+			}
+		}
+	}
+	switch ct, _ := el[schemaElementConvertedType].(int64); ct {
+	case convertedTypeTimestampMillis:
+		return time.Millisecond
+	case convertedTypeTimestampMicros:
+		return time.Microsecond
+	}
+	return 0
+}
+
+// parquetTimestamp decodes a plain encoded statistics value of a timestamp
+// column.
+func parquetTimestamp(v []byte, physical int64, unit time.Duration) time.Time {
+	switch {
+	case physical == physicalTypeInt64 && unit != 0 && len(v) == 8:
+		n := int64(binary.LittleEndian.Uint64(v))
+		if n > math.MaxInt64/int64(unit) || n < math.MinInt64/int64(unit) {
+			return time.Time{}
+		}
+		return time.Unix(0, n*int64(unit)).UTC()
+	case physical == physicalTypeByteArray:
+		t, _ := ParseTimestamp(string(v))
+		return t
+	}
+	return time.Time{}
+}
+
+func asBytes(v interface{}) []byte {
+	b, _ := v.([]byte)
+	return b
+}
+
+// parquetFooter returns the footer of the Parquet file read from r. Only the
+// end of the file is kept in memory.
+func parquetFooter(r io.Reader) ([]byte, error) {
+	tail := &tailBuffer{max: maxParquetFooter + 8}
+	if _, err := io.Copy(tail, r); err != nil {
+		return nil, err
+	}
+	return footerOf(tail.b)
+}
+
+// footerOf returns the footer at the end of b, which holds at least the
+// footer, its length and the magic number.
+func footerOf(b []byte) ([]byte, error) {
+	if len(b) < 8 || string(b[len(b)-4:]) != parquetMagic {
+		return nil, errors.New(errNotParquet)
+	}
+	n := int64(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	if n > maxParquetFooter {
+		return nil, errors.Errorf(errFooterTooLarge, n, maxParquetFooter)
+	}
+	if n > int64(len(b)-8) {
+		return nil, errors.New(errNotParquet)
+	}
+	return b[int64(len(b)-8)-n : len(b)-8], nil
+}
+
+// A tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	b   []byte
+	max int
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.b = append(t.b, p...)
+	if len(t.b) > 2*t.max {
+		t.b = append(t.b[:0], t.b[len(t.b)-t.max:]...)
+	}
+	return len(p), nil
+}
+
+// A thriftStruct is a decoded Thrift structure, by field ID. Integers are
+// decoded as int64, binaries as []byte, lists and sets as []interface{}.
+// Maps and doubles are skipped.
+type thriftStruct map[int16]interface{}
+
+// Thrift compact protocol types.
+const (
+	thriftTypeStop   = 0
+	thriftTypeTrue   = 1
+	thriftTypeFalse  = 2
+	thriftTypeByte   = 3
+	thriftTypeI16    = 4
+	thriftTypeI32    = 5
+	thriftTypeI64    = 6
+	thriftTypeDouble = 7
+	thriftTypeBinary = 8
+	thriftTypeList   = 9
+	thriftTypeSet    = 10
+	thriftTypeMap    = 11
+	thriftTypeStruct = 12
+)
+
+// A thriftReader decodes the Thrift compact protocol, see
+// https://github.com/apache/thrift/blob/master/doc/specs/thrift-compact-protocol.md
+type thriftReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *thriftReader) fail() error {
+	return errors.Errorf(errThrift, r.pos)
+}
+
+func (r *thriftReader) byte() (byte, error) {
+	if r.pos >= len(r.b) {
+		return 0, r.fail()
+	}
+	r.pos++
+	return r.b[r.pos-1], nil
+}
+
+func (r *thriftReader) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(r.b[r.pos:])
+	if n <= 0 {
+		return 0, r.fail()
+	}
+	r.pos += n
+	return v, nil
+}
+
+func (r *thriftReader) varint() (int64, error) {
+	v, err := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1), err
+}
+
+func (r *thriftReader) skip(n uint64) ([]byte, error) {
+	if n > uint64(len(r.b)-r.pos) {
+		return nil, r.fail()
+	}
+	r.pos += int(n)
+	return r.b[r.pos-int(n) : r.pos], nil
+}
+
+func (r *thriftReader) structure(depth int) (thriftStruct, error) {
+	if depth > maxThriftDepth {
+		return nil, r.fail()
+	}
+	s := thriftStruct{}
+	id := int16(0)
+	for {
+		h, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		t := h & 0x0f
+		if t == thriftTypeStop {
+			return s, nil
+		}
+		if delta := int16(h >> 4); delta != 0 {
+			id += delta
+		} else {
+			v, err := r.varint()
+			if err != nil {
+				return nil, err
+			}
+			id = int16(v)
+		}
+		switch t {
+		case thriftTypeTrue, thriftTypeFalse:
+			s[id] = t == thriftTypeTrue
+			continue
+		}
+		if s[id], err = r.value(t, depth); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (r *thriftReader) value(t byte, depth int) (interface{}, error) {
+	switch t {
+	case thriftTypeTrue, thriftTypeFalse, thriftTypeByte:
+		b, err := r.byte()
+		return int64(int8(b)), err
+	case thriftTypeI16, thriftTypeI32, thriftTypeI64:
+		return r.varint()
+	case thriftTypeDouble:
+		_, err := r.skip(8)
+		return nil, err
+	case thriftTypeBinary:
+		n, err := r.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		return r.skip(n)
+	case thriftTypeList, thriftTypeSet:
+		return r.list(depth + 1)
+	case thriftTypeMap:
+		return nil, r.skipMap(depth + 1)
+	case thriftTypeStruct:
+		return r.structure(depth + 1)
+	}
+	return nil, r.fail()
+}
+
+func (r *thriftReader) list(depth int) ([]interface{}, error) {
+	if depth > maxThriftDepth {
+		return nil, r.fail()
+	}
+	h, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	n := uint64(h >> 4)
+	if n == 15 {
+		if n, err = r.uvarint(); err != nil {
+			return nil, err
+		}
+	}
+	//every element takes at least a byte
+	if n > uint64(len(r.b)-r.pos) {
+		return nil, r.fail()
+	}
+	l := make([]interface{}, 0, n)
+	for i := uint64(0); i < n; i++ {
+		v, err := r.value(h&0x0f, depth)
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, v)
+	}
+	return l, nil
+}
+
+func (r *thriftReader) skipMap(depth int) error {
+	if depth > maxThriftDepth {
+		return r.fail()
+	}
+	n, err := r.uvarint()
+	if err != nil || n == 0 {
+		return err
+	}
+	if n > uint64(len(r.b)-r.pos) {
+		return r.fail()
+	}
+	types, err := r.byte()
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < n; i++ {
+		if _, err := r.value(types>>4, depth); err != nil {
+			return err
+		}
+		if _, err := r.value(types&0x0f, depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driftsource
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	errGetPod    = "cannot get reader pod"
+	errCreatePod = "cannot create reader pod"
+	errDeletePod = "cannot delete reader pod"
+	errPodLogs   = "cannot stream reader pod logs"
+	errPodFailed = "reader pod %s/%s failed: %s"
+	errTruncated = "log of reader pod %s/%s holds %d of %d bytes: the kubelet truncates logs larger than its containerLogMaxSize"
+
+	msgPodStarted = "started reader pod %s/%s"
+	msgPodRunning = "reader pod %s/%s is %s"
+
+	// missingExitCode is the exit code of the reader container when the
+	// file does not exist.
+	missingExitCode = 3
+)
+
+// A PodReader opens files of a volume through a short lived pod that mounts
+// the volume and prints the file to its log. A file cannot be read by the
+// first Open: it starts the pod and returns a pending error. Once the pod
+// completed Open streams its log, and closing the file deletes the pod.
+//
+// The kubelet rotates container logs larger than its containerLogMaxSize,
+// 10Mi by default, and only the last of them is streamed. A file larger than
+// that cannot be read: reading its log fails once fewer bytes were read than
+// the size the pod reported.
+type PodReader struct {
+	Client kubernetes.Interface
+
+	// Pod is the reader pod. Its first container must mount the volume at
+	// MountPath and provide a POSIX shell. Its command is set by Open.
+	Pod *corev1.Pod
+
+	MountPath string
+
+	// Script prints the file at "$0" and exits with status 3 when it does
+	// not exist. It may write the number of bytes it prints to
+	// /dev/termination-log, so that a truncated log is detected. It
+	// defaults to printing the file as is.
+	Script string
+}
+
+// Open opens the named file of the volume.
+func (r *PodReader) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	pods := r.Client.CoreV1().Pods(r.Pod.Namespace)
+
+	pod, err := pods.Get(ctx, r.Pod.Name, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		if _, err := pods.Create(ctx, r.pod(name), metav1.CreateOptions{}); err != nil && !kerrors.IsAlreadyExists(err) {
+			return nil, errors.Wrap(err, errCreatePod)
+		}
+		return nil, Pending(errors.Errorf(msgPodStarted, r.Pod.Namespace, r.Pod.Name))
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, errGetPod)
+	}
+
+	switch pod.Status.Phase {
+	case corev1.PodSucceeded:
+		logs, err := pods.GetLogs(pod.Name, &corev1.PodLogOptions{}).Stream(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, errPodLogs)
+		}
+		return &podLog{ReadCloser: logs, delete: func() error { return r.delete(ctx) }, pod: pod, size: loggedSize(pod)}, nil
+	case corev1.PodFailed:
+		missing := false
+		for _, s := range pod.Status.ContainerStatuses {
+			if t := s.State.Terminated; t != nil && t.ExitCode == missingExitCode {
+				missing = true
+			}
+		}
+		if err := r.delete(ctx); err != nil {
+			return nil, err
+		}
+		err := errors.Errorf(errPodFailed, pod.Namespace, pod.Name, strings.TrimSpace(pod.Status.Message))
+		if missing {
+			return nil, NotFound(err)
+		}
+		return nil, err
+	default:
+		return nil, Pending(errors.Errorf(msgPodRunning, pod.Namespace, pod.Name, pod.Status.Phase))
+	}
+}
+
+// pod returns the reader pod that prints the named file.
+func (r *PodReader) pod(name string) *corev1.Pod {
+	pod := r.Pod.DeepCopy()
+	pod.Spec.RestartPolicy = corev1.RestartPolicyNever
+	if len(pod.Spec.Containers) > 0 {
+		script := r.Script
+		if script == "" {
+			script = fmt.Sprintf(`test -f "$0" || exit %d; wc -c < "$0" > /dev/termination-log; cat "$0"`, missingExitCode)
+		}
+		pod.Spec.Containers[0].Command = []string{"sh", "-c", script, path.Join(r.MountPath, name)}
+	}
+	return pod
+}
+
+func (r *PodReader) delete(ctx context.Context) error {
+	err := r.Client.CoreV1().Pods(r.Pod.Namespace).Delete(ctx, r.Pod.Name, metav1.DeleteOptions{})
+	if err != nil && !kerrors.IsNotFound(err) {
+		return errors.Wrap(err, errDeletePod)
+	}
+	return nil
+}
+
+// loggedSize returns the number of bytes the reader container of a completed
+// pod reported to print in its termination message, or -1.
+func loggedSize(pod *corev1.Pod) int64 {
+	for _, s := range pod.Status.ContainerStatuses {
+		if t := s.State.Terminated; t != nil && len(pod.Spec.Containers) > 0 && s.Name == pod.Spec.Containers[0].Name {
+			if n, err := strconv.ParseInt(strings.TrimSpace(t.Message), 10, 64); err == nil {
+				return n
+			}
+		}
+	}
+	return -1
+}
+
+// A podLog is the log of a reader pod. Closing it deletes the pod, so that
+// the next Open reads the file again.
+type podLog struct {
+	io.ReadCloser
+	delete func() error
+
+	// size is the number of bytes pod printed, -1 when unknown.
+	pod  *corev1.Pod
+	size int64
+	read int64
+}
+
+// Read fails at the end of a log that holds fewer bytes than the pod
+// printed.
+func (l *podLog) Read(p []byte) (int, error) {
+	n, err := l.ReadCloser.Read(p)
+	l.read += int64(n)
+	if errors.Is(err, io.EOF) && l.read < l.size {
+		return n, errors.Errorf(errTruncated, l.pod.Namespace, l.pod.Name, l.read, l.size)
+	}
+	return n, err
+}
+
+func (l *podLog) Close() error {
+	err := l.ReadCloser.Close()
+	if derr := l.delete(); derr != nil {
+		return derr
+	}
+	return err
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driftsource
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/minio/minio-go/v7/pkg/signer"
+	"github.com/pkg/errors"
+)
+
+const (
+	errRequestObject = "cannot request object"
+	errObjectStatus  = "object %s/%s: %s: %s"
+	errContentRange  = "cannot parse Content-Range %q"
+
+	// DefaultS3Region is the region requests are signed for when a bucket
+	// does not configure one. MinIO accepts it unless configured otherwise.
+	DefaultS3Region = "us-east-1"
+
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// S3Credentials are the static credentials requests to a bucket are signed
+// with.
+type S3Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// An S3Reader opens objects of an S3 compatible bucket, such as MinIO. The
+// bucket is addressed path style, as http(s)://<endpoint>/<bucket>/<key>.
+type S3Reader struct {
+	Endpoint string
+	Bucket   string
+	Prefix   string
+	Region   string
+
+	// Credentials sign the requests with AWS Signature Version 4. Requests
+	// are anonymous when nil.
+	Credentials *S3Credentials
+
+	Client *http.Client
+}
+
+// Open gets the object Prefix+name from the bucket.
+func (r *S3Reader) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	rc, _, err := r.OpenRange(ctx, name, 0)
+	return rc, err
+}
+
+// OpenRange gets the object Prefix+name from the bucket from the supplied
+// offset on. The size of an object that is streamed without a known length
+// is -1. Objects are replaced rather than appended to, so both the ID and the
+// version of an object are its ETag. A range starts a byte before the offset,
+// so that the response to a request for an object that did not grow still
+// holds its ETag.
+func (r *S3Reader) OpenRange(ctx context.Context, name string, offset int64) (io.ReadCloser, FileInfo, error) {
+	key := r.Prefix + name
+	u, err := url.Parse(strings.TrimSuffix(r.Endpoint, "/") + "/" + r.Bucket + "/" + key)
+	if err != nil {
+		return nil, FileInfo{}, errors.Wrap(err, errRequestObject)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, FileInfo{}, errors.Wrap(err, errRequestObject)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset-1))
+	}
+	if r.Credentials != nil {
+		region := r.Region
+		if region == "" {
+			region = DefaultS3Region
+		}
+		req.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+		req = signer.SignV4(*req, r.Credentials.AccessKeyID, r.Credentials.SecretAccessKey, "", region)
+	}
+
+	resp, err := client(r.Client).Do(req)
+	if err != nil {
+		return nil, FileInfo{}, errors.Wrap(err, errRequestObject)
+	}
+	etag := resp.Header.Get("ETag")
+	info := FileInfo{Size: resp.ContentLength, ID: etag, Version: etag}
+	skip := int64(0)
+	switch resp.StatusCode {
+	case http.StatusOK:
+		//the whole object is returned when the range is ignored
+		skip = offset
+	case http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable:
+		if info.Size, err = rangeSize(resp.Header.Get("Content-Range")); err != nil {
+			resp.Body.Close() //nolint:errcheck,gosec // Nothing was written.
+			return nil, FileInfo{}, err
+		}
+		if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+			resp.Body.Close() //nolint:errcheck,gosec // Nothing was written.
+			return io.NopCloser(strings.NewReader("")), info, nil
+		}
+		skip = 1
+	default:
+		defer resp.Body.Close() //nolint:errcheck // Nothing was written.
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		err = errors.Errorf(errObjectStatus, r.Bucket, key, resp.Status, strings.TrimSpace(string(body)))
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, FileInfo{}, NotFound(err)
+		}
+		return nil, FileInfo{}, err
+	}
+	if _, err := io.CopyN(io.Discard, resp.Body, skip); err != nil && !errors.Is(err, io.EOF) {
+		resp.Body.Close() //nolint:errcheck,gosec // Nothing was written.
+		return nil, FileInfo{}, errors.Wrap(err, errRequestObject)
+	}
+	return resp.Body, info, nil
+}
+
+// rangeSize returns the size of the whole object from the Content-Range of a
+// response to a range request, e.g. "bytes 100-199/200" or "bytes */200".
+func rangeSize(contentRange string) (int64, error) {
+	i := strings.LastIndexByte(contentRange, '/')
+	if i < 0 {
+		return 0, errors.Errorf(errContentRange, contentRange)
+	}
+	size, err := strconv.ParseInt(contentRange[i+1:], 10, 64)
+	if err != nil {
+		return 0, errors.Errorf(errContentRange, contentRange)
+	}
+	return size, nil
+}
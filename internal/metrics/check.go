@@ -0,0 +1,126 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"github.com/crossplane/provider-driftprovider/apis/mlops/v1alpha1"
+)
+
+const (
+	errParseThreshold = "cannot parse %s of threshold on metric %q"
+	errNotFinite      = "%q is not a finite number"
+)
+
+// A Verdict is the outcome of checking the metrics of a model against the
+// thresholds of an evaluation.
+type Verdict struct {
+	// Accepted is true when every threshold is met.
+	Accepted bool
+
+	// Unmet explains every threshold that is not met.
+	Unmet []string
+}
+
+// Check checks the metrics of a candidate model against the supplied
+// thresholds. Active are the metrics of the model currently served, nil when
+// it was not evaluated.
+func Check(thresholds []v1alpha1.MetricThreshold, candidate, active map[string]string) (Verdict, error) {
+	v := Verdict{Accepted: true}
+	unmet := func(format string, args ...any) {
+		v.Accepted = false
+		v.Unmet = append(v.Unmet, fmt.Sprintf(format, args...))
+	}
+
+	for _, t := range thresholds {
+		raw, ok := candidate[t.Metric]
+		if !ok {
+			unmet("%s was not reported", t.Metric)
+			continue
+		}
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			unmet("%s is %q, not a number", t.Metric, raw)
+			continue
+		}
+		// NaN meets every bound, since it compares false to any number.
+		if !finite(value) {
+			unmet("%s is %s, not a finite number", t.Metric, raw)
+			continue
+		}
+
+		if t.Max != nil {
+			limit, err := parseFinite(*t.Max)
+			if err != nil {
+				return Verdict{}, errors.Wrapf(err, errParseThreshold, "max", t.Metric)
+			}
+			if value > limit {
+				unmet("%s %s is above the maximum %s", t.Metric, raw, *t.Max)
+			}
+		}
+		if t.Min != nil {
+			limit, err := parseFinite(*t.Min)
+			if err != nil {
+				return Verdict{}, errors.Wrapf(err, errParseThreshold, "min", t.Metric)
+			}
+			if value < limit {
+				unmet("%s %s is below the minimum %s", t.Metric, raw, *t.Min)
+			}
+		}
+
+		if !t.NoWorseThanActive || t.Goal == nil {
+			continue
+		}
+		// A metric the served model was not evaluated on cannot get worse.
+		baseline, err := parseFinite(active[t.Metric])
+		if err != nil {
+			continue
+		}
+		switch *t.Goal {
+		case v1alpha1.MetricGoalMinimize:
+			if value > baseline {
+				unmet("%s %s is worse than %s of the active model", t.Metric, raw, active[t.Metric])
+			}
+		case v1alpha1.MetricGoalMaximize:
+			if value < baseline {
+				unmet("%s %s is worse than %s of the active model", t.Metric, raw, active[t.Metric])
+			}
+		}
+	}
+	return v, nil
+}
+
+func finite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// parseFinite parses a number, rejecting NaN and infinities.
+func parseFinite(s string) (float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if !finite(v) {
+		return 0, errors.Errorf(errNotFinite, s)
+	}
+	return v, nil
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/provider-driftprovider/apis/mlops/v1alpha1"
+)
+
+func TestCheck(t *testing.T) {
+	str := func(s string) *string { return &s }
+	goal := func(g v1alpha1.MetricGoal) *v1alpha1.MetricGoal { return &g }
+
+	type args struct {
+		thresholds []v1alpha1.MetricThreshold
+		candidate  map[string]string
+		active     map[string]string
+	}
+
+	cases := map[string]struct {
+		reason  string
+		args    args
+		want    Verdict
+		wantErr error
+	}{
+		"NoThresholds": {
+			reason: "A model should be accepted when no threshold is configured.",
+			args:   args{candidate: map[string]string{"rmse": "0.3"}},
+			want:   Verdict{Accepted: true},
+		},
+		"WithinBounds": {
+			reason: "A model within every bound should be accepted.",
+			args: args{
+				thresholds: []v1alpha1.MetricThreshold{{Metric: "rmse", Max: str("0.2")}, {Metric: "r2", Min: str("0.8")}},
+				candidate:  map[string]string{"rmse": "0.12", "r2": "0.9"},
+			},
+			want: Verdict{Accepted: true},
+		},
+		"OutOfBounds": {
+			reason: "Every bound a model misses should be reported.",
+			args: args{
+				thresholds: []v1alpha1.MetricThreshold{{Metric: "rmse", Max: str("0.2")}, {Metric: "r2", Min: str("0.8")}},
+				candidate:  map[string]string{"rmse": "0.25", "r2": "0.5"},
+			},
+			want: Verdict{Unmet: []string{"rmse 0.25 is above the maximum 0.2", "r2 0.5 is below the minimum 0.8"}},
+		},
+		"NotReported": {
+			reason: "A metric the evaluation did not report should not meet its threshold.",
+			args: args{
+				thresholds: []v1alpha1.MetricThreshold{{Metric: "rmse", Max: str("0.2")}},
+				candidate:  map[string]string{"mae": "0.1"},
+			},
+			want: Verdict{Unmet: []string{"rmse was not reported"}},
+		},
+		"NotANumber": {
+			reason: "A metric that is not a number should not meet its threshold.",
+			args: args{
+				thresholds: []v1alpha1.MetricThreshold{{Metric: "rmse", Max: str("0.2")}},
+				candidate:  map[string]string{"rmse": "nan?"},
+			},
+			want: Verdict{Unmet: []string{`rmse is "nan?", not a number`}},
+		},
+		"NaN": {
+			reason: "A metric that is NaN should not meet its threshold.",
+			args: args{
+				thresholds: []v1alpha1.MetricThreshold{{Metric: "rmse", Max: str("0.2")}, {Metric: "r2", Min: str("0.8")}},
+				candidate:  map[string]string{"rmse": "NaN", "r2": "nan"},
+			},
+			want: Verdict{Unmet: []string{"rmse is NaN, not a finite number", "r2 is nan, not a finite number"}},
+		},
+		"Infinite": {
+			reason: "A metric that is infinite should not meet its threshold, whichever bound it has.",
+			args: args{
+				thresholds: []v1alpha1.MetricThreshold{{Metric: "rmse", Min: str("0")}, {Metric: "r2", Max: str("1")}},
+				candidate:  map[string]string{"rmse": "+Inf", "r2": "-Inf"},
+			},
+			want: Verdict{Unmet: []string{"rmse is +Inf, not a finite number", "r2 is -Inf, not a finite number"}},
+		},
+		"NaNWithoutBounds": {
+			reason: "A metric that is NaN should not be accepted as no worse than the active model.",
+			args: args{
+				thresholds: []v1alpha1.MetricThreshold{{Metric: "rmse", Goal: goal(v1alpha1.MetricGoalMinimize), NoWorseThanActive: true}},
+				candidate:  map[string]string{"rmse": "NaN"},
+				active:     map[string]string{"rmse": "0.12"},
+			},
+			want: Verdict{Unmet: []string{"rmse is NaN, not a finite number"}},
+		},
+		"ActiveNaN": {
+			reason: "A model should be accepted when the active model reported NaN, as if it was not evaluated.",
+			args: args{
+				thresholds: []v1alpha1.MetricThreshold{{Metric: "rmse", Goal: goal(v1alpha1.MetricGoalMinimize), NoWorseThanActive: true}},
+				candidate:  map[string]string{"rmse": "0.15"},
+				active:     map[string]string{"rmse": "NaN"},
+			},
+			want: Verdict{Accepted: true},
+		},
+		"WorseThanActive": {
+			reason: "A model worse than the active model should be rejected.",
+			args: args{
+				thresholds: []v1alpha1.MetricThreshold{{Metric: "rmse", Goal: goal(v1alpha1.MetricGoalMinimize), NoWorseThanActive: true}},
+				candidate:  map[string]string{"rmse": "0.15"},
+				active:     map[string]string{"rmse": "0.12"},
+			},
+			want: Verdict{Unmet: []string{"rmse 0.15 is worse than 0.12 of the active model"}},
+		},
+		"BetterThanActive": {
+			reason: "A model better than the active model should be accepted.",
+			args: args{
+				thresholds: []v1alpha1.MetricThreshold{{Metric: "r2", Goal: goal(v1alpha1.MetricGoalMaximize), NoWorseThanActive: true}},
+				candidate:  map[string]string{"r2": "0.9"},
+				active:     map[string]string{"r2": "0.85"},
+			},
+			want: Verdict{Accepted: true},
+		},
+		"ActiveNotEvaluated": {
+			reason: "A model should be accepted when the active model was not evaluated.",
+			args: args{
+				thresholds: []v1alpha1.MetricThreshold{{Metric: "rmse", Goal: goal(v1alpha1.MetricGoalMinimize), NoWorseThanActive: true}},
+				candidate:  map[string]string{"rmse": "0.15"},
+			},
+			want: Verdict{Accepted: true},
+		},
+		"InvalidThreshold": {
+			reason: "A threshold that is not a number should be returned as an error.",
+			args: args{
+				thresholds: []v1alpha1.MetricThreshold{{Metric: "rmse", Max: str("low")}},
+				candidate:  map[string]string{"rmse": "0.1"},
+			},
+			wantErr: errors.Wrapf(errors.New(`strconv.ParseFloat: parsing "low": invalid syntax`), errParseThreshold, "max", "rmse"),
+		},
+		"NaNThreshold": {
+			reason: "A threshold that is NaN should be returned as an error, since every metric would meet it.",
+			args: args{
+				thresholds: []v1alpha1.MetricThreshold{{Metric: "rmse", Max: str("NaN")}},
+				candidate:  map[string]string{"rmse": "0.1"},
+			},
+			wantErr: errors.Wrapf(errors.Errorf(errNotFinite, "NaN"), errParseThreshold, "max", "rmse"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := Check(tc.args.thresholds, tc.args.candidate, tc.args.active)
+			if diff := cmp.Diff(tc.wantErr, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nCheck(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nCheck(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics reads the metrics training and evaluation runs report for
+// a model, and checks them against the thresholds of an evaluation.
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	errDecode      = "cannot decode metrics"
+	errMetricValue = "metric %q is not a number, string or boolean"
+)
+
+// File is the name of the metrics file a training run writes next to the
+// model it trained.
+const File = "metrics.json"
+
+// Parse reads a flat JSON object of metrics, e.g. {"rmse": 0.12}. Values are
+// returned as strings, numbers in their shortest representation.
+func Parse(r io.Reader) (map[string]string, error) {
+	d := json.NewDecoder(r)
+	d.UseNumber()
+	m, err := decode(d)
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	return m, err
+}
+
+// ParseStream reads consecutive JSON objects of metrics, as written by
+// concatenating metrics files. A null value stands for a missing file and is
+// returned as nil.
+func ParseStream(r io.Reader) ([]map[string]string, error) {
+	d := json.NewDecoder(r)
+	d.UseNumber()
+	all := []map[string]string{}
+	for {
+		m, err := decode(d)
+		if errors.Is(err, io.EOF) {
+			return all, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, m)
+	}
+}
+
+func decode(d *json.Decoder) (map[string]string, error) {
+	var raw map[string]any
+	if err := d.Decode(&raw); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		return nil, errors.Wrap(err, errDecode)
+	}
+	if raw == nil {
+		return nil, nil
+	}
+
+	m := make(map[string]string, len(raw))
+	for name, v := range raw {
+		switch v := v.(type) {
+		case json.Number:
+			f, err := v.Float64()
+			if err != nil {
+				return nil, errors.Wrap(err, errDecode)
+			}
+			m[name] = strconv.FormatFloat(f, 'g', -1, 64)
+		case string:
+			m[name] = v
+		case bool:
+			m[name] = strconv.FormatBool(v)
+		default:
+			return nil, errors.Errorf(errMetricValue, name)
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func TestParse(t *testing.T) {
+	cases := map[string]struct {
+		reason  string
+		in      string
+		want    map[string]string
+		wantErr error
+	}{
+		"Values": {
+			reason: "Numbers, strings and booleans should be returned as strings.",
+			in:     `{"rmse": 0.120, "samples": 3000, "loss": 1e-3, "optimizer": "adam", "early_stopped": true}`,
+			want:   map[string]string{"rmse": "0.12", "samples": "3000", "loss": "0.001", "optimizer": "adam", "early_stopped": "true"},
+		},
+		"Empty": {
+			reason: "An empty file should report no metrics.",
+			in:     "",
+		},
+		"Nested": {
+			reason:  "Nested values should be rejected.",
+			in:      `{"history": [0.3, 0.2]}`,
+			wantErr: errors.Errorf(errMetricValue, "history"),
+		},
+		"Invalid": {
+			reason:  "A file that is no JSON object should be rejected.",
+			in:      `[1, 2]`,
+			wantErr: errors.Wrap(errors.New("json: cannot unmarshal array into Go value of type map[string]interface {}"), errDecode),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tc.in))
+			if diff := cmp.Diff(tc.wantErr, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nParse(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nParse(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestParseStream(t *testing.T) {
+	cases := map[string]struct {
+		reason  string
+		in      string
+		want    []map[string]string
+		wantErr error
+	}{
+		"Files": {
+			reason: "Every object should be returned in order, null as nil.",
+			in:     "{\"rmse\": 0.12}\nnull\n{\n  \"rmse\": 0.2\n}\n",
+			want:   []map[string]string{{"rmse": "0.12"}, nil, {"rmse": "0.2"}},
+		},
+		"Empty": {
+			reason: "Empty input should report no objects.",
+			in:     "",
+			want:   []map[string]string{},
+		},
+		"Invalid": {
+			reason:  "A value that is no metric should be rejected.",
+			in:      `{"rmse": 0.12} {"history": [0.3]}`,
+			wantErr: errors.Errorf(errMetricValue, "history"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseStream(strings.NewReader(tc.in))
+			if diff := cmp.Diff(tc.wantErr, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nParseStream(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nParseStream(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,490 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package pipeline implements the drift → train → convert → rollout state
+// machine of a CtrlDrift.
+//
+// The state machine is pure: Next only looks at the phase persisted in the
+// CtrlDrift status and at what currently exists in the cluster, and returns
+// the next phase together with the actions the controller must run to get
+// there. Every action is safe to repeat, so a controller that crashes or
+// loses leadership between running the actions and persisting the new phase
+// simply computes the same transition again when it resumes.
+package pipeline
+
+import (
+	"fmt"
+
+	batchv1 "k8s.io/api/batch/v1"
+
+	"github.com/crossplane/provider-driftprovider/apis/mlops/v1alpha1"
+)
+
+// A JobState summarises the state of one of the Jobs of the pipeline.
+type JobState string
+
+// Job states.
+const (
+	JobAbsent    JobState = "Absent"
+	JobRunning   JobState = "Running"
+	JobSucceeded JobState = "Succeeded"
+	JobFailed    JobState = "Failed"
+)
+
+// JobStateOf returns the state of the supplied Job. A nil Job is absent.
+func JobStateOf(j *batchv1.Job) JobState {
+	switch {
+	case j == nil:
+		return JobAbsent
+	case j.Status.Failed > 0:
+		return JobFailed
+	case j.Status.Succeeded > 0:
+		return JobSucceeded
+	default:
+		return JobRunning
+	}
+}
+
+// An Action is a side effect the controller must run to make a transition.
+// Every action must be idempotent: creating something that already exists or
+// deleting something that is already gone is not an error.
+type Action string
+
+// Actions.
+const (
+	ActionStartTraining    Action = "StartTraining"
+	ActionDeleteTraining   Action = "DeleteTraining"
+	ActionStartEvaluation  Action = "StartEvaluation"
+	ActionDeleteEvaluation Action = "DeleteEvaluation"
+	ActionStartConversion  Action = "StartConversion"
+	ActionDeleteConversion Action = "DeleteConversion"
+	ActionStartCandidate   Action = "StartCandidate"
+	ActionDeleteCandidate  Action = "DeleteCandidate"
+	ActionRollout          Action = "Rollout"
+
+	// ActionCancelRun deletes the Jobs and the candidate Deployment of the
+	// run in flight, running or not.
+	ActionCancelRun Action = "CancelRun"
+)
+
+// A Verdict is the outcome of checking the metrics of an evaluation against
+// its thresholds.
+type Verdict string
+
+// Verdicts.
+const (
+	// VerdictUnknown means the metrics have not been checked yet.
+	VerdictUnknown  Verdict = ""
+	VerdictAccepted Verdict = "Accepted"
+	VerdictRejected Verdict = "Rejected"
+)
+
+// An Observation is everything the state machine needs to know to compute
+// the next transition.
+type Observation struct {
+	// Phase is the phase persisted in the CtrlDrift status. The empty phase
+	// is treated as Monitoring.
+	Phase v1alpha1.Phase
+
+	// DriftedSamples is the number of drifted samples currently collected.
+	DriftedSamples int64
+
+	// Retrain is true when the retrain policy asks for a new training run.
+	Retrain bool
+
+	// Training is the state of the training Job.
+	Training JobState
+
+	// Evaluate is true when a trained model must be evaluated before it is
+	// converted.
+	Evaluate bool
+
+	// Evaluating is the state of the evaluation Job.
+	Evaluating JobState
+
+	// Verdict is the outcome of the evaluation of the trained model. It is
+	// only looked at once the evaluation Job succeeded.
+	Verdict Verdict
+
+	// Converting is the state of the conversion Job.
+	Converting JobState
+
+	// RetriesLeft is true when a failed Job may be retried.
+	RetriesLeft bool
+
+	// BackingOff is true while a failed Job must not be started again yet.
+	BackingOff bool
+
+	// Bake is true when a converted model must run next to the served one
+	// in a candidate Deployment before it is promoted.
+	Bake bool
+
+	// Candidate is true when the candidate Deployment exists.
+	Candidate bool
+
+	// Bakeoff is the outcome of comparing the candidate with the served
+	// model. It is unknown while the candidate bakes.
+	Bakeoff Verdict
+
+	// RolledOut is true when every Deployment serves the model produced by
+	// the last training run, or the pinned model while one is pinned.
+	RolledOut bool
+
+	// Pinned is true while a pinned model is served. The models of new runs
+	// are still baked and judged, but not promoted.
+	Pinned bool
+
+	// Paused is true while no Job may be started and no model may be
+	// rolled out.
+	Paused bool
+
+	// Cancel is true when the run in flight must be aborted.
+	Cancel bool
+
+	// Restart is true when a failed run must make way for a new one: a
+	// retrain was requested, or the spec changed since the run started. It
+	// is only looked at in the Failed phase.
+	Restart bool
+}
+
+// A Transition is the outcome of a step of the state machine.
+type Transition struct {
+	// Phase is the phase to persist in the CtrlDrift status.
+	Phase v1alpha1.Phase
+
+	// Actions are the side effects to run, in order.
+	Actions []Action
+
+	// Message is a human readable explanation of the transition.
+	Message string
+}
+
+// Next computes the transition that follows the supplied observation.
+func Next(o Observation) Transition {
+	if o.Cancel {
+		return cancel(o)
+	}
+	t := next(o)
+	if o.Paused {
+		return hold(o, t)
+	}
+	return t
+}
+
+func next(o Observation) Transition {
+	switch o.Phase {
+	case v1alpha1.PhaseTraining:
+		return training(o)
+	case v1alpha1.PhaseEvaluating:
+		return evaluating(o)
+	case v1alpha1.PhaseConverting:
+		return converting(o)
+	case v1alpha1.PhaseBaking:
+		return baking(o)
+	case v1alpha1.PhaseRollingOut:
+		return rollingOut(o)
+	case v1alpha1.PhaseRejected:
+		return rejected(o)
+	case v1alpha1.PhaseFailed:
+		return failed(o)
+	case v1alpha1.PhaseMonitoring, v1alpha1.PhaseDrifting, "":
+		return monitoring(o)
+	}
+	return monitoring(o)
+}
+
+// held are the actions a paused pipeline does not run.
+var held = map[Action]bool{
+	ActionStartTraining:   true,
+	ActionStartEvaluation: true,
+	ActionStartConversion: true,
+	ActionStartCandidate:  true,
+	ActionRollout:         true,
+}
+
+// hold keeps a paused pipeline in its phase instead of starting a Job or
+// rolling out a model. The Jobs the run no longer needs are still deleted.
+func hold(o Observation, t Transition) Transition {
+	var actions []Action
+	holding := false
+	for _, a := range t.Actions {
+		if held[a] {
+			holding = true
+			continue
+		}
+		actions = append(actions, a)
+	}
+	if !holding {
+		return t
+	}
+	phase := o.Phase
+	switch phase {
+	case v1alpha1.PhaseMonitoring, v1alpha1.PhaseDrifting, "":
+		phase = idle(o).Phase
+	}
+	return Transition{Phase: phase, Actions: actions, Message: "pipeline paused, held back: " + t.Message}
+}
+
+// cancel aborts the run in flight and starts monitoring again.
+func cancel(o Observation) Transition {
+	t := idle(o)
+	t.Actions = []Action{ActionCancelRun}
+	t.Message = "run cancelled, " + t.Message
+	return t
+}
+
+func idle(o Observation) Transition {
+	if o.DriftedSamples > 0 {
+		return Transition{Phase: v1alpha1.PhaseDrifting, Message: fmt.Sprintf("%d drifted samples collected", o.DriftedSamples)}
+	}
+	return Transition{Phase: v1alpha1.PhaseMonitoring, Message: "no drift detected"}
+}
+
+func monitoring(o Observation) Transition {
+	// Jobs only exist while a run is in flight. Finding one here means the
+	// phase that started it was never persisted, so adopt the run.
+	if o.Converting != JobAbsent {
+		return converting(o)
+	}
+	if o.Evaluating != JobAbsent {
+		return evaluating(o)
+	}
+	if o.Training != JobAbsent {
+		return training(o)
+	}
+	if o.Retrain {
+		return Transition{
+			Phase:   v1alpha1.PhaseTraining,
+			Actions: []Action{ActionStartTraining},
+			Message: "retrain policy triggered, starting training",
+		}
+	}
+	return idle(o)
+}
+
+func training(o Observation) Transition {
+	// The evaluation and conversion Jobs are only created once training
+	// succeeded.
+	if o.Converting != JobAbsent {
+		return converting(o)
+	}
+	if o.Evaluating != JobAbsent {
+		return evaluating(o)
+	}
+	switch o.Training {
+	case JobAbsent:
+		if o.BackingOff {
+			return Transition{Phase: v1alpha1.PhaseTraining, Message: "waiting to retry training"}
+		}
+		return Transition{
+			Phase:   v1alpha1.PhaseTraining,
+			Actions: []Action{ActionStartTraining},
+			Message: "training job is missing, starting training",
+		}
+	case JobFailed:
+		return retry(o, v1alpha1.PhaseTraining, nil, ActionDeleteTraining, "training")
+	case JobSucceeded:
+		if o.Evaluate {
+			return Transition{
+				Phase:   v1alpha1.PhaseEvaluating,
+				Actions: []Action{ActionStartEvaluation},
+				Message: "training finished, starting evaluation",
+			}
+		}
+		return Transition{
+			Phase:   v1alpha1.PhaseConverting,
+			Actions: []Action{ActionStartConversion},
+			Message: "training finished, starting conversion",
+		}
+	case JobRunning:
+	}
+	return Transition{Phase: v1alpha1.PhaseTraining, Message: "training job is running"}
+}
+
+func evaluating(o Observation) Transition {
+	// The conversion Job is only created once the model was accepted.
+	if o.Converting != JobAbsent {
+		return converting(o)
+	}
+	switch o.Evaluating {
+	case JobAbsent:
+		if o.BackingOff {
+			return Transition{Phase: v1alpha1.PhaseEvaluating, Message: "waiting to retry evaluation"}
+		}
+		return Transition{
+			Phase:   v1alpha1.PhaseEvaluating,
+			Actions: []Action{ActionStartEvaluation},
+			Message: "evaluation job is missing, starting evaluation",
+		}
+	case JobFailed:
+		return retry(o, v1alpha1.PhaseEvaluating, nil, ActionDeleteEvaluation, "evaluation")
+	case JobSucceeded:
+		switch o.Verdict {
+		case VerdictAccepted:
+			return Transition{
+				Phase:   v1alpha1.PhaseConverting,
+				Actions: []Action{ActionStartConversion},
+				Message: "model accepted by its evaluation, starting conversion",
+			}
+		case VerdictRejected:
+			return rejected(o)
+		case VerdictUnknown:
+		}
+		return Transition{Phase: v1alpha1.PhaseEvaluating, Message: "checking evaluation metrics"}
+	case JobRunning:
+	}
+	return Transition{Phase: v1alpha1.PhaseEvaluating, Message: "evaluation job is running"}
+}
+
+// retry deletes a failed Job so that it is started again once the backoff
+// elapsed, or fails the pipeline when no retries are left.
+func retry(o Observation, phase v1alpha1.Phase, actions []Action, remove Action, step string) Transition {
+	if !o.RetriesLeft {
+		return Transition{Phase: v1alpha1.PhaseFailed, Actions: actions, Message: step + " job failed"}
+	}
+	return Transition{Phase: phase, Actions: append(actions, remove), Message: step + " job failed, retrying"}
+}
+
+// cleanup returns the actions deleting the Jobs a run no longer needs once
+// it moved past training and evaluation.
+func cleanup(o Observation) []Action {
+	var actions []Action
+	if o.Training != JobAbsent {
+		actions = append(actions, ActionDeleteTraining)
+	}
+	if o.Evaluating != JobAbsent {
+		actions = append(actions, ActionDeleteEvaluation)
+	}
+	return actions
+}
+
+func converting(o Observation) Transition {
+	actions := cleanup(o)
+	switch o.Converting {
+	case JobAbsent:
+		if o.BackingOff {
+			return Transition{Phase: v1alpha1.PhaseConverting, Actions: actions, Message: "waiting to retry conversion"}
+		}
+		return Transition{
+			Phase:   v1alpha1.PhaseConverting,
+			Actions: append(actions, ActionStartConversion),
+			Message: "conversion job is missing, starting conversion",
+		}
+	case JobFailed:
+		return retry(o, v1alpha1.PhaseConverting, actions, ActionDeleteConversion, "conversion")
+	case JobSucceeded:
+		if o.Bake {
+			return baking(o)
+		}
+		return rollingOut(o)
+	case JobRunning:
+	}
+	return Transition{Phase: v1alpha1.PhaseConverting, Actions: actions, Message: "conversion job is running"}
+}
+
+func baking(o Observation) Transition {
+	// The Deployments already serve the model, unless it is only the pinned
+	// one: a pin does not skip judging the model of the run.
+	if o.RolledOut && !o.Pinned {
+		return rollingOut(o)
+	}
+	actions := cleanup(o)
+	switch o.Bakeoff {
+	case VerdictAccepted:
+		return Transition{
+			Phase:   v1alpha1.PhaseRollingOut,
+			Actions: append(actions, ActionRollout),
+			Message: "candidate promoted, rolling out the new model",
+		}
+	case VerdictRejected:
+		return rejected(o)
+	case VerdictUnknown:
+	}
+	if !o.Candidate {
+		return Transition{
+			Phase:   v1alpha1.PhaseBaking,
+			Actions: append(actions, ActionStartCandidate),
+			Message: "starting the candidate deployment",
+		}
+	}
+	return Transition{Phase: v1alpha1.PhaseBaking, Actions: actions, Message: "candidate deployment is baking"}
+}
+
+func rollingOut(o Observation) Transition {
+	actions := cleanup(o)
+	if !o.RolledOut {
+		return Transition{
+			Phase:   v1alpha1.PhaseRollingOut,
+			Actions: append(actions, ActionRollout),
+			Message: "rolling out the new model",
+		}
+	}
+	if o.Candidate {
+		actions = append(actions, ActionDeleteCandidate)
+	}
+	if o.Converting != JobAbsent {
+		actions = append(actions, ActionDeleteConversion)
+	}
+	t := idle(o)
+	t.Actions = actions
+	return t
+}
+
+// rejected discards a model its evaluation or its candidate Deployment
+// rejected. The pipeline stays rejected until the retrain policy starts the
+// next run.
+func rejected(o Observation) Transition {
+	actions := cleanup(o)
+	if o.Candidate {
+		actions = append(actions, ActionDeleteCandidate)
+	}
+	if o.Converting != JobAbsent {
+		actions = append(actions, ActionDeleteConversion)
+	}
+	if len(actions) > 0 {
+		return Transition{Phase: v1alpha1.PhaseRejected, Actions: actions, Message: "model rejected"}
+	}
+	if o.Retrain {
+		return Transition{
+			Phase:   v1alpha1.PhaseTraining,
+			Actions: []Action{ActionStartTraining},
+			Message: "retrain policy triggered, starting training",
+		}
+	}
+	return Transition{Phase: v1alpha1.PhaseRejected, Message: "model rejected"}
+}
+
+func failed(o Observation) Transition {
+	// A failed run is kept around for inspection. Once its Jobs are gone
+	// the pipeline starts monitoring again.
+	if o.Training == JobAbsent && o.Evaluating == JobAbsent && o.Converting == JobAbsent {
+		return idle(o)
+	}
+	// It is retired when a new run was asked for, or may succeed with the
+	// changed spec.
+	if o.Restart {
+		actions := cleanup(o)
+		if o.Converting != JobAbsent {
+			actions = append(actions, ActionDeleteConversion)
+		}
+		return Transition{
+			Phase:   v1alpha1.PhaseTraining,
+			Actions: append(actions, ActionStartTraining),
+			Message: "failed run retired, starting training",
+		}
+	}
+	return Transition{Phase: v1alpha1.PhaseFailed, Message: "a pipeline job failed"}
+}
@@ -0,0 +1,432 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	batchv1 "k8s.io/api/batch/v1"
+
+	"github.com/crossplane/provider-driftprovider/apis/mlops/v1alpha1"
+)
+
+func TestJobStateOf(t *testing.T) {
+	cases := map[string]struct {
+		job  *batchv1.Job
+		want JobState
+	}{
+		"Nil":       {job: nil, want: JobAbsent},
+		"Running":   {job: &batchv1.Job{}, want: JobRunning},
+		"Succeeded": {job: &batchv1.Job{Status: batchv1.JobStatus{Succeeded: 1}}, want: JobSucceeded},
+		"Failed":    {job: &batchv1.Job{Status: batchv1.JobStatus{Failed: 1}}, want: JobFailed},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, JobStateOf(tc.job)); diff != "" {
+				t.Errorf("JobStateOf(...): -want, +got:\n%s\n", diff)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	idle := Observation{Training: JobAbsent, Evaluating: JobAbsent, Converting: JobAbsent, RolledOut: true}
+	with := func(fn func(o *Observation)) Observation {
+		o := idle
+		fn(&o)
+		return o
+	}
+
+	cases := map[string]struct {
+		reason string
+		o      Observation
+		want   Transition
+	}{
+		"Monitoring": {
+			reason: "Without drift the pipeline should keep monitoring.",
+			o:      with(func(o *Observation) { o.Phase = v1alpha1.PhaseMonitoring }),
+			want:   Transition{Phase: v1alpha1.PhaseMonitoring},
+		},
+		"EmptyPhase": {
+			reason: "A CtrlDrift that was never observed should start monitoring.",
+			o:      idle,
+			want:   Transition{Phase: v1alpha1.PhaseMonitoring},
+		},
+		"Drifting": {
+			reason: "Drifted samples below the retrain policy should be reported as drifting.",
+			o:      with(func(o *Observation) { o.Phase = v1alpha1.PhaseMonitoring; o.DriftedSamples = 10 }),
+			want:   Transition{Phase: v1alpha1.PhaseDrifting},
+		},
+		"StartTraining": {
+			reason: "A triggered retrain policy should start training.",
+			o:      with(func(o *Observation) { o.Phase = v1alpha1.PhaseDrifting; o.DriftedSamples = 10; o.Retrain = true }),
+			want:   Transition{Phase: v1alpha1.PhaseTraining, Actions: []Action{ActionStartTraining}},
+		},
+		"AdoptTraining": {
+			reason: "A training job found while monitoring should be adopted instead of starting a second one.",
+			o:      with(func(o *Observation) { o.Phase = v1alpha1.PhaseDrifting; o.Retrain = true; o.Training = JobRunning }),
+			want:   Transition{Phase: v1alpha1.PhaseTraining},
+		},
+		"TrainingRunning": {
+			reason: "A running training job should be left alone.",
+			o:      with(func(o *Observation) { o.Phase = v1alpha1.PhaseTraining; o.Training = JobRunning }),
+			want:   Transition{Phase: v1alpha1.PhaseTraining},
+		},
+		"TrainingMissing": {
+			reason: "A training job that disappeared should be started again.",
+			o:      with(func(o *Observation) { o.Phase = v1alpha1.PhaseTraining }),
+			want:   Transition{Phase: v1alpha1.PhaseTraining, Actions: []Action{ActionStartTraining}},
+		},
+		"TrainingFailed": {
+			reason: "A failed training job should fail the pipeline when no retries are left.",
+			o:      with(func(o *Observation) { o.Phase = v1alpha1.PhaseTraining; o.Training = JobFailed }),
+			want:   Transition{Phase: v1alpha1.PhaseFailed},
+		},
+		"TrainingFailedRetry": {
+			reason: "A failed training job should be deleted to be retried while retries are left.",
+			o:      with(func(o *Observation) { o.Phase = v1alpha1.PhaseTraining; o.Training = JobFailed; o.RetriesLeft = true }),
+			want:   Transition{Phase: v1alpha1.PhaseTraining, Actions: []Action{ActionDeleteTraining}},
+		},
+		"TrainingBackingOff": {
+			reason: "A failed training job should not be started again before the backoff elapsed.",
+			o:      with(func(o *Observation) { o.Phase = v1alpha1.PhaseTraining; o.RetriesLeft = true; o.BackingOff = true }),
+			want:   Transition{Phase: v1alpha1.PhaseTraining},
+		},
+		"TrainingRetried": {
+			reason: "A failed training job should be started again once the backoff elapsed.",
+			o:      with(func(o *Observation) { o.Phase = v1alpha1.PhaseTraining; o.RetriesLeft = true }),
+			want:   Transition{Phase: v1alpha1.PhaseTraining, Actions: []Action{ActionStartTraining}},
+		},
+		"TrainingSucceeded": {
+			reason: "A successful training job should start the conversion.",
+			o:      with(func(o *Observation) { o.Phase = v1alpha1.PhaseTraining; o.Training = JobSucceeded }),
+			want:   Transition{Phase: v1alpha1.PhaseConverting, Actions: []Action{ActionStartConversion}},
+		},
+		"TrainingSucceededConversionStarted": {
+			reason: "Resuming after the conversion was started but the phase was not persisted should not start a second conversion.",
+			o: with(func(o *Observation) {
+				o.Phase = v1alpha1.PhaseTraining
+				o.Training = JobSucceeded
+				o.Converting = JobRunning
+			}),
+			want: Transition{Phase: v1alpha1.PhaseConverting, Actions: []Action{ActionDeleteTraining}},
+		},
+		"TrainingSucceededEvaluate": {
+			reason: "A successful training job should start the evaluation when one is configured.",
+			o:      with(func(o *Observation) { o.Phase = v1alpha1.PhaseTraining; o.Training = JobSucceeded; o.Evaluate = true }),
+			want:   Transition{Phase: v1alpha1.PhaseEvaluating, Actions: []Action{ActionStartEvaluation}},
+		},
+		"AdoptEvaluation": {
+			reason: "An evaluation job found while monitoring should be adopted.",
+			o: with(func(o *Observation) {
+				o.Phase = v1alpha1.PhaseMonitoring
+				o.Evaluate = true
+				o.Training = JobSucceeded
+				o.Evaluating = JobRunning
+			}),
+			want: Transition{Phase: v1alpha1.PhaseEvaluating},
+		},
+		"EvaluatingMissing": {
+			reason: "An evaluation job that disappeared should be started again.",
+			o:      with(func(o *Observation) { o.Phase = v1alpha1.PhaseEvaluating; o.Evaluate = true; o.Training = JobSucceeded }),
+			want:   Transition{Phase: v1alpha1.PhaseEvaluating, Actions: []Action{ActionStartEvaluation}},
+		},
+		"EvaluatingFailed": {
+			reason: "A failed evaluation job should fail the pipeline when no retries are left.",
+			o: with(func(o *Observation) {
+				o.Phase = v1alpha1.PhaseEvaluating
+				o.Training = JobSucceeded
+				o.Evaluating = JobFailed
+			}),
+			want: Transition{Phase: v1alpha1.PhaseFailed},
+		},
+		"EvaluatingFailedRetry": {
+			reason: "A failed evaluation job should be deleted to be retried while retries are left.",
+			o: with(func(o *Observation) {
+				o.Phase = v1alpha1.PhaseEvaluating
+				o.Training = JobSucceeded
+				o.Evaluating = JobFailed
+				o.RetriesLeft = true
+			}),
+			want: Transition{Phase: v1alpha1.PhaseEvaluating, Actions: []Action{ActionDeleteEvaluation}},
+		},
+		"EvaluationUnchecked": {
+			reason: "The pipeline should wait until the metrics of a successful evaluation were checked.",
+			o: with(func(o *Observation) {
+				o.Phase = v1alpha1.PhaseEvaluating
+				o.Training = JobSucceeded
+				o.Evaluating = JobSucceeded
+			}),
+			want: Transition{Phase: v1alpha1.PhaseEvaluating},
+		},
+		"EvaluationAccepted": {
+			reason: "An accepted model should be converted.",
+			o: with(func(o *Observation) {
+				o.Phase = v1alpha1.PhaseEvaluating
+				o.Training = JobSucceeded
+				o.Evaluating = JobSucceeded
+				o.Verdict = VerdictAccepted
+			}),
+			want: Transition{Phase: v1alpha1.PhaseConverting, Actions: []Action{ActionStartConversion}},
+		},
+		"EvaluationRejected": {
+			reason: "A rejected model should be discarded together with its jobs.",
+			o: with(func(o *Observation) {
+				o.Phase = v1alpha1.PhaseEvaluating
+				o.Training = JobSucceeded
+				o.Evaluating = JobSucceeded
+				o.Verdict = VerdictRejected
+			}),
+			want: Transition{Phase: v1alpha1.PhaseRejected, Actions: []Action{ActionDeleteTraining, ActionDeleteEvaluation}},
+		},
+		"Rejected": {
+			reason: "A rejected pipeline should stay rejected until the next retrain.",
+			o:      with(func(o *Observation) { o.Phase = v1alpha1.PhaseRejected; o.DriftedSamples = 5 }),
+			want:   Transition{Phase: v1alpha1.PhaseRejected},
+		},
+		"RejectedRetrain": {
+			reason: "A rejected pipeline should start the next run when the retrain policy triggers.",
+			o:      with(func(o *Observation) { o.Phase = v1alpha1.PhaseRejected; o.DriftedSamples = 5; o.Retrain = true }),
+			want:   Transition{Phase: v1alpha1.PhaseTraining, Actions: []Action{ActionStartTraining}},
+		},
+		"ConvertingAfterEvaluation": {
+			reason: "The training and evaluation jobs should be cleaned up once the conversion runs.",
+			o: with(func(o *Observation) {
+				o.Phase = v1alpha1.PhaseConverting
+				o.Training = JobSucceeded
+				o.Evaluating = JobSucceeded
+				o.Converting = JobRunning
+			}),
+			want: Transition{Phase: v1alpha1.PhaseConverting, Actions: []Action{ActionDeleteTraining, ActionDeleteEvaluation}},
+		},
+		"ConvertingMissing": {
+			reason: "A conversion job that disappeared should be started again.",
+			o:      with(func(o *Observation) { o.Phase = v1alpha1.PhaseConverting }),
+			want:   Transition{Phase: v1alpha1.PhaseConverting, Actions: []Action{ActionStartConversion}},
+		},
+		"ConvertingFailed": {
+			reason: "A failed conversion job should fail the pipeline when no retries are left.",
+			o:      with(func(o *Observation) { o.Phase = v1alpha1.PhaseConverting; o.Converting = JobFailed }),
+			want:   Transition{Phase: v1alpha1.PhaseFailed},
+		},
+		"ConvertingFailedRetry": {
+			reason: "A failed conversion job should be deleted to be retried while retries are left.",
+			o: with(func(o *Observation) {
+				o.Phase = v1alpha1.PhaseConverting
+				o.Training = JobSucceeded
+				o.Converting = JobFailed
+				o.RetriesLeft = true
+			}),
+			want: Transition{Phase: v1alpha1.PhaseConverting, Actions: []Action{ActionDeleteTraining, ActionDeleteConversion}},
+		},
+		"ConvertingBackingOff": {
+			reason: "A failed conversion job should not be started again before the backoff elapsed.",
+			o:      with(func(o *Observation) { o.Phase = v1alpha1.PhaseConverting; o.RetriesLeft = true; o.BackingOff = true }),
+			want:   Transition{Phase: v1alpha1.PhaseConverting},
+		},
+		"ConvertingSucceeded": {
+			reason: "A successful conversion job should roll out the new model.",
+			o: with(func(o *Observation) {
+				o.Phase = v1alpha1.PhaseConverting
+				o.Converting = JobSucceeded
+				o.RolledOut = false
+			}),
+			want: Transition{Phase: v1alpha1.PhaseRollingOut, Actions: []Action{ActionRollout}},
+		},
+		"RollingOut": {
+			reason: "The rollout should be repeated until every Deployment serves the new model.",
+			o: with(func(o *Observation) {
+				o.Phase = v1alpha1.PhaseRollingOut
+				o.Converting = JobSucceeded
+				o.RolledOut = false
+			}),
+			want: Transition{Phase: v1alpha1.PhaseRollingOut, Actions: []Action{ActionRollout}},
+		},
+		"ConvertingSucceededBake": {
+			reason: "A successful conversion job should start the candidate when the model must bake.",
+			o: with(func(o *Observation) {
+				o.Phase = v1alpha1.PhaseConverting
+				o.Converting = JobSucceeded
+				o.Bake = true
+				o.RolledOut = false
+			}),
+			want: Transition{Phase: v1alpha1.PhaseBaking, Actions: []Action{ActionStartCandidate}},
+		},
+		"Baking": {
+			reason: "A baking candidate should be left alone.",
+			o: with(func(o *Observation) {
+				o.Phase = v1alpha1.PhaseBaking
+				o.Converting = JobSucceeded
+				o.Bake = true
+				o.Candidate = true
+				o.RolledOut = false
+			}),
+			want: Transition{Phase: v1alpha1.PhaseBaking},
+		},
+		"BakingPromoted": {
+			reason: "A promoted candidate should be rolled out.",
+			o: with(func(o *Observation) {
+				o.Phase = v1alpha1.PhaseBaking
+				o.Converting = JobSucceeded
+				o.Bake = true
+				o.Candidate = true
+				o.Bakeoff = VerdictAccepted
+				o.RolledOut = false
+			}),
+			want: Transition{Phase: v1alpha1.PhaseRollingOut, Actions: []Action{ActionRollout}},
+		},
+		"BakingRolledBack": {
+			reason: "A rolled back candidate should be removed together with the conversion job.",
+			o: with(func(o *Observation) {
+				o.Phase = v1alpha1.PhaseBaking
+				o.Converting = JobSucceeded
+				o.Bake = true
+				o.Candidate = true
+				o.Bakeoff = VerdictRejected
+				o.RolledOut = false
+			}),
+			want: Transition{Phase: v1alpha1.PhaseRejected, Actions: []Action{ActionDeleteCandidate, ActionDeleteConversion}},
+		},
+		"BakingPinned": {
+			reason: "A model that is not promoted because of a pin should still bake.",
+			o: with(func(o *Observation) {
+				o.Phase = v1alpha1.PhaseConverting
+				o.Converting = JobSucceeded
+				o.Bake = true
+				o.Pinned = true
+			}),
+			want: Transition{Phase: v1alpha1.PhaseBaking, Actions: []Action{ActionStartCandidate}},
+		},
+		"BakedPinned": {
+			reason: "A model its candidate accepted while a model is pinned should go through the rollout, which does not promote it.",
+			o: with(func(o *Observation) {
+				o.Phase = v1alpha1.PhaseBaking
+				o.Converting = JobSucceeded
+				o.Bake = true
+				o.Candidate = true
+				o.Bakeoff = VerdictAccepted
+				o.Pinned = true
+			}),
+			want: Transition{Phase: v1alpha1.PhaseRollingOut, Actions: []Action{ActionRollout}},
+		},
+		"BakingPinnedRejected": {
+			reason: "A model its candidate rejected while a model is pinned should be rejected.",
+			o: with(func(o *Observation) {
+				o.Phase = v1alpha1.PhaseBaking
+				o.Converting = JobSucceeded
+				o.Bake = true
+				o.Candidate = true
+				o.Bakeoff = VerdictRejected
+				o.Pinned = true
+			}),
+			want: Transition{Phase: v1alpha1.PhaseRejected, Actions: []Action{ActionDeleteCandidate, ActionDeleteConversion}},
+		},
+		"RolledOutAfterBaking": {
+			reason: "Once rolled out the candidate should be removed with the conversion job.",
+			o: with(func(o *Observation) {
+				o.Phase = v1alpha1.PhaseRollingOut
+				o.Converting = JobSucceeded
+				o.Candidate = true
+			}),
+			want: Transition{Phase: v1alpha1.PhaseMonitoring, Actions: []Action{ActionDeleteCandidate, ActionDeleteConversion}},
+		},
+		"RolledOut": {
+			reason: "Once rolled out the conversion job should be cleaned up and monitoring resumed.",
+			o:      with(func(o *Observation) { o.Phase = v1alpha1.PhaseRollingOut; o.Converting = JobSucceeded }),
+			want:   Transition{Phase: v1alpha1.PhaseMonitoring, Actions: []Action{ActionDeleteConversion}},
+		},
+		"RolledOutCleanedUp": {
+			reason: "Resuming after the conversion job was deleted should simply resume monitoring.",
+			o:      with(func(o *Observation) { o.Phase = v1alpha1.PhaseRollingOut }),
+			want:   Transition{Phase: v1alpha1.PhaseMonitoring},
+		},
+		"FailedKept": {
+			reason: "A failed pipeline should stay failed while the failed job exists.",
+			o:      with(func(o *Observation) { o.Phase = v1alpha1.PhaseFailed; o.Training = JobFailed; o.Retrain = true }),
+			want:   Transition{Phase: v1alpha1.PhaseFailed},
+		},
+		"FailedRestarted": {
+			reason: "A failed run should be retired and a new one started when a retrain was requested or the spec changed.",
+			o: with(func(o *Observation) {
+				o.Phase = v1alpha1.PhaseFailed
+				o.Training = JobSucceeded
+				o.Evaluating = JobFailed
+				o.Restart = true
+			}),
+			want: Transition{Phase: v1alpha1.PhaseTraining, Actions: []Action{ActionDeleteTraining, ActionDeleteEvaluation, ActionStartTraining}},
+		},
+		"FailedCleanedUp": {
+			reason: "A failed pipeline should resume monitoring once the failed job was removed.",
+			o:      with(func(o *Observation) { o.Phase = v1alpha1.PhaseFailed; o.DriftedSamples = 5 }),
+			want:   Transition{Phase: v1alpha1.PhaseDrifting},
+		},
+		"PausedBeforeTraining": {
+			reason: "A paused pipeline should not start training when the retrain policy triggers.",
+			o: with(func(o *Observation) {
+				o.Phase = v1alpha1.PhaseDrifting
+				o.DriftedSamples = 10
+				o.Retrain = true
+				o.Paused = true
+			}),
+			want: Transition{Phase: v1alpha1.PhaseDrifting},
+		},
+		"PausedAfterTraining": {
+			reason: "A paused pipeline should stay in its phase instead of starting the next job.",
+			o:      with(func(o *Observation) { o.Phase = v1alpha1.PhaseTraining; o.Training = JobSucceeded; o.Paused = true }),
+			want:   Transition{Phase: v1alpha1.PhaseTraining},
+		},
+		"PausedCleansUp": {
+			reason: "A paused pipeline should still delete the jobs it no longer needs, but not roll out.",
+			o: with(func(o *Observation) {
+				o.Phase = v1alpha1.PhaseConverting
+				o.Training = JobSucceeded
+				o.Converting = JobSucceeded
+				o.RolledOut = false
+				o.Paused = true
+			}),
+			want: Transition{Phase: v1alpha1.PhaseConverting, Actions: []Action{ActionDeleteTraining}},
+		},
+		"PausedRunning": {
+			reason: "A paused pipeline should keep observing a job that is already running.",
+			o:      with(func(o *Observation) { o.Phase = v1alpha1.PhaseTraining; o.Training = JobRunning; o.Paused = true }),
+			want:   Transition{Phase: v1alpha1.PhaseTraining},
+		},
+		"Cancel": {
+			reason: "A cancelled run should be aborted and monitoring resumed.",
+			o: with(func(o *Observation) {
+				o.Phase = v1alpha1.PhaseEvaluating
+				o.Training = JobSucceeded
+				o.Evaluating = JobRunning
+				o.DriftedSamples = 5
+				o.Cancel = true
+			}),
+			want: Transition{Phase: v1alpha1.PhaseDrifting, Actions: []Action{ActionCancelRun}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := Next(tc.o)
+			if diff := cmp.Diff(tc.want, got, cmpopts.IgnoreFields(Transition{}, "Message"), cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("\n%s\nNext(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package retrain evaluates a CtrlDrift RetrainPolicy against the drift
+// observed by the provider.
+package retrain
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/crossplane/provider-driftprovider/apis/mlops/v1alpha1"
+)
+
+// DefaultMinDriftedSamples is the number of drifted samples that triggers a
+// retrain when a policy configures no trigger at all.
+const DefaultMinDriftedSamples int64 = 3000
+
+const (
+	errParseRatio = "cannot parse minDriftRatio"
+)
+
+// Reasons reported in a Decision.
+const (
+	ReasonNoDrift           = "NoDrift"
+	ReasonCooldown          = "CooldownActive"
+	ReasonBelowThresholds   = "BelowThresholds"
+	ReasonMinDriftedSamples = "MinDriftedSamples"
+	ReasonMinDriftRatio     = "MinDriftRatio"
+	ReasonMaxDriftAge       = "MaxDriftAge"
+)
+
+// An Observation is the drift state a policy is evaluated against.
+type Observation struct {
+	// DriftedSamples is the number of drifted samples collected so far.
+	DriftedSamples int64
+
+	// TotalSamples is the number of samples the detector inspected. Zero
+	// means unknown, in which case ratio triggers are skipped.
+	TotalSamples int64
+
+	// OldestDriftedSample is when the oldest drifted sample was collected.
+	// The zero value means unknown.
+	OldestDriftedSample time.Time
+
+	// LastRetrain is when the previous retrain was started. The zero value
+	// means there was none.
+	LastRetrain time.Time
+}
+
+// A Decision is the outcome of evaluating a policy.
+type Decision struct {
+	// Retrain is true when a new training run should be started.
+	Retrain bool
+
+	// Reason is a short, CamelCase explanation of the decision.
+	Reason string
+
+	// Message is a human readable explanation of the decision.
+	Message string
+}
+
+// Evaluate decides whether the supplied observation should trigger a
+// retrain at time now. A nil policy behaves like an empty one.
+func Evaluate(p *v1alpha1.RetrainPolicy, o Observation, now time.Time) (Decision, error) {
+	if p == nil {
+		p = &v1alpha1.RetrainPolicy{}
+	}
+
+	if o.DriftedSamples <= 0 {
+		return Decision{Reason: ReasonNoDrift, Message: "no drifted samples collected"}, nil
+	}
+
+	if p.Cooldown != nil && !o.LastRetrain.IsZero() {
+		if next := o.LastRetrain.Add(p.Cooldown.Duration); now.Before(next) {
+			return Decision{
+				Reason:  ReasonCooldown,
+				Message: fmt.Sprintf("previous retrain started at %s, cooldown ends at %s", o.LastRetrain.Format(time.RFC3339), next.Format(time.RFC3339)),
+			}, nil
+		}
+	}
+
+	minSamples := p.MinDriftedSamples
+	if minSamples == nil && p.MinDriftRatio == nil && p.MaxDriftAge == nil {
+		d := DefaultMinDriftedSamples
+		minSamples = &d
+	}
+
+	if minSamples != nil && o.DriftedSamples >= *minSamples {
+		return Decision{
+			Retrain: true,
+			Reason:  ReasonMinDriftedSamples,
+			Message: fmt.Sprintf("%d drifted samples collected, minimum is %d", o.DriftedSamples, *minSamples),
+		}, nil
+	}
+
+	if p.MinDriftRatio != nil && o.TotalSamples > 0 {
+		threshold, err := strconv.ParseFloat(*p.MinDriftRatio, 64)
+		if err != nil {
+			return Decision{}, errors.Wrap(err, errParseRatio)
+		}
+		if ratio := float64(o.DriftedSamples) / float64(o.TotalSamples); ratio >= threshold {
+			return Decision{
+				Retrain: true,
+				Reason:  ReasonMinDriftRatio,
+				Message: fmt.Sprintf("drift ratio is %.4f, minimum is %s", ratio, *p.MinDriftRatio),
+			}, nil
+		}
+	}
+
+	if p.MaxDriftAge != nil && !o.OldestDriftedSample.IsZero() {
+		if age := now.Sub(o.OldestDriftedSample); age >= p.MaxDriftAge.Duration {
+			return Decision{
+				Retrain: true,
+				Reason:  ReasonMaxDriftAge,
+				Message: fmt.Sprintf("oldest drifted sample is %s old, maximum is %s", age.Round(time.Second), p.MaxDriftAge.Duration),
+			}, nil
+		}
+	}
+
+	return Decision{Reason: ReasonBelowThresholds, Message: fmt.Sprintf("%d drifted samples collected, no retrain trigger met", o.DriftedSamples)}, nil
+}
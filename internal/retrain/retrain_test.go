@@ -0,0 +1,154 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package retrain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane/provider-driftprovider/apis/mlops/v1alpha1"
+)
+
+func TestEvaluate(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	samples := func(i int64) *int64 { return &i }
+	ratio := func(s string) *string { return &s }
+	duration := func(d time.Duration) *metav1.Duration { return &metav1.Duration{Duration: d} }
+
+	type args struct {
+		p *v1alpha1.RetrainPolicy
+		o Observation
+	}
+
+	type want struct {
+		retrain bool
+		reason  string
+		err     error
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoDrift": {
+			reason: "Without drifted samples no retrain should be started.",
+			args: args{
+				p: &v1alpha1.RetrainPolicy{MinDriftedSamples: samples(1)},
+				o: Observation{},
+			},
+			want: want{reason: ReasonNoDrift},
+		},
+		"DefaultBelowThreshold": {
+			reason: "A nil policy should not retrain below the default sample count.",
+			args: args{
+				o: Observation{DriftedSamples: DefaultMinDriftedSamples - 1},
+			},
+			want: want{reason: ReasonBelowThresholds},
+		},
+		"DefaultThreshold": {
+			reason: "A nil policy should retrain at the default sample count.",
+			args: args{
+				o: Observation{DriftedSamples: DefaultMinDriftedSamples},
+			},
+			want: want{retrain: true, reason: ReasonMinDriftedSamples},
+		},
+		"NoDefaultWhenOtherTriggerSet": {
+			reason: "The default sample count should not apply when another trigger is configured.",
+			args: args{
+				p: &v1alpha1.RetrainPolicy{MaxDriftAge: duration(time.Hour)},
+				o: Observation{DriftedSamples: DefaultMinDriftedSamples, OldestDriftedSample: now.Add(-time.Minute)},
+			},
+			want: want{reason: ReasonBelowThresholds},
+		},
+		"MinDriftedSamples": {
+			reason: "Collecting the configured number of samples should trigger a retrain.",
+			args: args{
+				p: &v1alpha1.RetrainPolicy{MinDriftedSamples: samples(100)},
+				o: Observation{DriftedSamples: 100},
+			},
+			want: want{retrain: true, reason: ReasonMinDriftedSamples},
+		},
+		"MinDriftRatio": {
+			reason: "Reaching the configured drift ratio should trigger a retrain.",
+			args: args{
+				p: &v1alpha1.RetrainPolicy{MinDriftedSamples: samples(1000), MinDriftRatio: ratio("0.25")},
+				o: Observation{DriftedSamples: 250, TotalSamples: 1000},
+			},
+			want: want{retrain: true, reason: ReasonMinDriftRatio},
+		},
+		"MinDriftRatioUnknownTotal": {
+			reason: "The drift ratio should be skipped when the total sample count is unknown.",
+			args: args{
+				p: &v1alpha1.RetrainPolicy{MinDriftRatio: ratio("0.25")},
+				o: Observation{DriftedSamples: 250},
+			},
+			want: want{reason: ReasonBelowThresholds},
+		},
+		"InvalidMinDriftRatio": {
+			reason: "An unparseable drift ratio should return an error.",
+			args: args{
+				p: &v1alpha1.RetrainPolicy{MinDriftRatio: ratio("a lot")},
+				o: Observation{DriftedSamples: 250, TotalSamples: 1000},
+			},
+			want: want{err: cmpopts.AnyError},
+		},
+		"MaxDriftAge": {
+			reason: "Drift older than the configured maximum age should trigger a retrain.",
+			args: args{
+				p: &v1alpha1.RetrainPolicy{MaxDriftAge: duration(24 * time.Hour)},
+				o: Observation{DriftedSamples: 1, OldestDriftedSample: now.Add(-25 * time.Hour)},
+			},
+			want: want{retrain: true, reason: ReasonMaxDriftAge},
+		},
+		"CooldownActive": {
+			reason: "No retrain should be started before the cooldown elapsed.",
+			args: args{
+				p: &v1alpha1.RetrainPolicy{MinDriftedSamples: samples(1), Cooldown: duration(time.Hour)},
+				o: Observation{DriftedSamples: 10, LastRetrain: now.Add(-30 * time.Minute)},
+			},
+			want: want{reason: ReasonCooldown},
+		},
+		"CooldownElapsed": {
+			reason: "A retrain should be started once the cooldown elapsed.",
+			args: args{
+				p: &v1alpha1.RetrainPolicy{MinDriftedSamples: samples(1), Cooldown: duration(time.Hour)},
+				o: Observation{DriftedSamples: 10, LastRetrain: now.Add(-2 * time.Hour)},
+			},
+			want: want{retrain: true, reason: ReasonMinDriftedSamples},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := Evaluate(tc.args.p, tc.args.o, now)
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nEvaluate(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.retrain, got.Retrain); diff != "" {
+				t.Errorf("\n%s\nEvaluate(...).Retrain: -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.reason, got.Reason); diff != "" {
+				t.Errorf("\n%s\nEvaluate(...).Reason: -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package retrain
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+
+	"github.com/crossplane/provider-driftprovider/apis/mlops/v1alpha1"
+)
+
+const (
+	errParseCron    = "cannot parse cron schedule"
+	errCronNeverDue = "cron schedule %q is never due"
+
+	// maxMissed bounds the number of missed runs that are counted.
+	maxMissed = 100
+)
+
+// Reasons reported in a ScheduleDecision.
+const (
+	ReasonNotScheduled = "NotScheduled"
+	ReasonScheduled    = "Scheduled"
+	ReasonMaxInterval  = "MaxInterval"
+)
+
+// A ScheduleObservation is the state a RetrainSchedule is evaluated against.
+type ScheduleObservation struct {
+	// LastSchedule is when the last scheduled run was due. The zero value
+	// means there was none.
+	LastSchedule time.Time
+
+	// LastRetrain is when the previous retrain was started. The zero value
+	// means there was none.
+	LastRetrain time.Time
+
+	// Created is when the CtrlDrift was created. The schedule starts
+	// counting from it until the first run.
+	Created time.Time
+}
+
+// A ScheduleDecision is the outcome of evaluating a schedule.
+type ScheduleDecision struct {
+	// Due is true when a scheduled run should be started.
+	Due bool
+
+	// Reason is a short, CamelCase explanation of the decision.
+	Reason string
+
+	// Message is a human readable explanation of the decision.
+	Message string
+
+	// Time is when the due run was scheduled for.
+	Time time.Time
+
+	// Missed is the number of runs of the cron schedule that were due
+	// before the one at Time and are not started.
+	Missed int
+
+	// Next is when the following run is due. The zero value means never.
+	Next time.Time
+}
+
+// ParseCron parses a cron schedule in the standard five field format in the
+// supplied time zone. An empty time zone means UTC.
+func ParseCron(spec string, tz string) (cron.Schedule, error) {
+	if tz == "" {
+		tz = "UTC"
+	}
+	s, err := cron.ParseStandard(fmt.Sprintf("CRON_TZ=%s %s", tz, spec))
+	return s, errors.Wrap(err, errParseCron)
+}
+
+// EvaluateSchedule decides whether the supplied schedule has a run due at
+// time now. A nil schedule never has.
+func EvaluateSchedule(s *v1alpha1.RetrainSchedule, o ScheduleObservation, now time.Time) (ScheduleDecision, error) {
+	d := ScheduleDecision{Reason: ReasonNotScheduled, Message: "no retrain schedule"}
+	if s == nil {
+		return d, nil
+	}
+
+	if s.Cron != nil {
+		tz := ""
+		if s.TimeZone != nil {
+			tz = *s.TimeZone
+		}
+		sched, err := ParseCron(*s.Cron, tz)
+		if err != nil {
+			return ScheduleDecision{}, err
+		}
+
+		since := o.LastSchedule
+		if since.IsZero() {
+			since = o.Created
+		}
+		next := sched.Next(since)
+		if next.IsZero() {
+			return ScheduleDecision{}, errors.Errorf(errCronNeverDue, *s.Cron)
+		}
+		for i := 0; !next.IsZero() && !next.After(now); i++ {
+			if i > maxMissed {
+				//skip to the last run instead of stepping through every missed one
+				next = latest(sched, next, now)
+				d.Missed = maxMissed
+			}
+			if !d.Time.IsZero() && d.Missed < maxMissed {
+				d.Missed++
+			}
+			d.Due, d.Time = true, next
+			next = sched.Next(next)
+		}
+		d.Next = next
+		if d.Due {
+			d.Reason = ReasonScheduled
+			d.Message = fmt.Sprintf("run scheduled at %s by %q", d.Time.UTC().Format(time.RFC3339), *s.Cron)
+		} else {
+			d.Message = fmt.Sprintf("next run scheduled at %s", next.UTC().Format(time.RFC3339))
+		}
+	}
+
+	if s.MaxIntervalDays != nil {
+		since := o.LastRetrain
+		if since.IsZero() {
+			since = o.Created
+		}
+		interval := time.Duration(*s.MaxIntervalDays) * 24 * time.Hour
+		due := since.Add(interval)
+		if !d.Due && !due.After(now) {
+			d.Due, d.Time = true, due
+			d.Reason = ReasonMaxInterval
+			d.Message = fmt.Sprintf("last run started at %s, at least one run every %d days", since.UTC().Format(time.RFC3339), *s.MaxIntervalDays)
+		}
+		if due.After(now) && (d.Next.IsZero() || due.Before(d.Next)) {
+			d.Next = due
+			if !d.Due {
+				d.Message = fmt.Sprintf("next run scheduled at %s", due.UTC().Format(time.RFC3339))
+			}
+		}
+	}
+	return d, nil
+}
+
+// latest returns the last time of the supplied schedule that is not after
+// now, from is a time of the schedule before it. It looks back from now over
+// a doubling window, so that it takes about as many steps as the schedule has
+// times within twice its interval.
+func latest(sched cron.Schedule, from time.Time, now time.Time) time.Time {
+	t := from
+	for w := time.Minute; now.Add(-w).After(from); w *= 2 {
+		if next := sched.Next(now.Add(-w)); !next.IsZero() && !next.After(now) {
+			t = next
+			break
+		}
+	}
+	for i := 0; i < maxMissed; i++ {
+		next := sched.Next(t)
+		if next.IsZero() || next.After(now) {
+			break
+		}
+		t = next
+	}
+	return t
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package retrain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/crossplane/provider-driftprovider/apis/mlops/v1alpha1"
+)
+
+func TestEvaluateSchedule(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	str := func(s string) *string { return &s }
+	days := func(i int32) *int32 { return &i }
+
+	type args struct {
+		s *v1alpha1.RetrainSchedule
+		o ScheduleObservation
+	}
+
+	type want struct {
+		due    bool
+		reason string
+		time   time.Time
+		missed int
+		next   time.Time
+		err    error
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoSchedule": {
+			reason: "A nil schedule should never be due.",
+			args: args{
+				o: ScheduleObservation{Created: now.Add(-time.Hour)},
+			},
+			want: want{reason: ReasonNotScheduled},
+		},
+		"CronNotDue": {
+			reason: "A cron schedule should not be due before its next time.",
+			args: args{
+				s: &v1alpha1.RetrainSchedule{Cron: str("0 3 * * *")},
+				o: ScheduleObservation{LastSchedule: time.Date(2024, 3, 1, 3, 0, 0, 0, time.UTC)},
+			},
+			want: want{reason: ReasonNotScheduled, next: time.Date(2024, 3, 2, 3, 0, 0, 0, time.UTC)},
+		},
+		"CronDue": {
+			reason: "A cron schedule should be due once its next time passed.",
+			args: args{
+				s: &v1alpha1.RetrainSchedule{Cron: str("0 3 * * *")},
+				o: ScheduleObservation{LastSchedule: time.Date(2024, 2, 29, 3, 0, 0, 0, time.UTC)},
+			},
+			want: want{
+				due:    true,
+				reason: ReasonScheduled,
+				time:   time.Date(2024, 3, 1, 3, 0, 0, 0, time.UTC),
+				next:   time.Date(2024, 3, 2, 3, 0, 0, 0, time.UTC),
+			},
+		},
+		"CronMissed": {
+			reason: "Only the last of several passed times should be due, the others are missed.",
+			args: args{
+				s: &v1alpha1.RetrainSchedule{Cron: str("@daily")},
+				o: ScheduleObservation{Created: time.Date(2024, 2, 26, 12, 0, 0, 0, time.UTC)},
+			},
+			want: want{
+				due:    true,
+				reason: ReasonScheduled,
+				time:   time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+				missed: 3,
+				next:   time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		"CronTimeZone": {
+			reason: "A cron schedule should be evaluated in its time zone.",
+			args: args{
+				s: &v1alpha1.RetrainSchedule{Cron: str("0 3 * * *"), TimeZone: str("America/New_York")},
+				o: ScheduleObservation{LastSchedule: time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)},
+			},
+			want: want{reason: ReasonNotScheduled, next: time.Date(2024, 3, 2, 8, 0, 0, 0, time.UTC)},
+		},
+		"InvalidCron": {
+			reason: "An unparseable cron schedule should return an error.",
+			args: args{
+				s: &v1alpha1.RetrainSchedule{Cron: str("every day")},
+				o: ScheduleObservation{Created: now},
+			},
+			want: want{err: cmpopts.AnyError},
+		},
+		"CronNeverDue": {
+			reason: "A cron schedule that parses but is never due should return an error instead of spinning.",
+			args: args{
+				s: &v1alpha1.RetrainSchedule{Cron: str("0 0 30 2 *")},
+				o: ScheduleObservation{Created: now.Add(-time.Hour)},
+			},
+			want: want{err: cmpopts.AnyError},
+		},
+		"CronLongMissed": {
+			reason: "A frequent schedule missed for a long time should skip to its last run without stepping through every missed one.",
+			args: args{
+				s: &v1alpha1.RetrainSchedule{Cron: str("* * * * *")},
+				o: ScheduleObservation{LastSchedule: now.Add(-365 * 24 * time.Hour)},
+			},
+			want: want{
+				due:    true,
+				reason: ReasonScheduled,
+				time:   now,
+				missed: maxMissed,
+				next:   now.Add(time.Minute),
+			},
+		},
+		"MaxIntervalNotDue": {
+			reason: "A run should not be due before the interval since the last run elapsed.",
+			args: args{
+				s: &v1alpha1.RetrainSchedule{MaxIntervalDays: days(7)},
+				o: ScheduleObservation{LastRetrain: now.Add(-6 * 24 * time.Hour)},
+			},
+			want: want{reason: ReasonNotScheduled, next: now.Add(24 * time.Hour)},
+		},
+		"MaxIntervalDue": {
+			reason: "A run should be due once the interval since the last run elapsed.",
+			args: args{
+				s: &v1alpha1.RetrainSchedule{MaxIntervalDays: days(7)},
+				o: ScheduleObservation{LastRetrain: now.Add(-8 * 24 * time.Hour)},
+			},
+			want: want{due: true, reason: ReasonMaxInterval, time: now.Add(-24 * time.Hour)},
+		},
+		"MaxIntervalSinceCreation": {
+			reason: "Without a previous run the interval should count from the creation of the CtrlDrift.",
+			args: args{
+				s: &v1alpha1.RetrainSchedule{MaxIntervalDays: days(1)},
+				o: ScheduleObservation{Created: now.Add(-2 * time.Hour)},
+			},
+			want: want{reason: ReasonNotScheduled, next: now.Add(22 * time.Hour)},
+		},
+		"EarliestNext": {
+			reason: "The next run should be the earlier of the cron schedule and the interval.",
+			args: args{
+				s: &v1alpha1.RetrainSchedule{Cron: str("0 0 * * 0"), MaxIntervalDays: days(1)},
+				o: ScheduleObservation{LastSchedule: now.Add(-time.Hour), LastRetrain: now.Add(-time.Hour)},
+			},
+			want: want{reason: ReasonNotScheduled, next: now.Add(23 * time.Hour)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := EvaluateSchedule(tc.args.s, tc.args.o, now)
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nEvaluateSchedule(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.want.due, got.Due); diff != "" {
+				t.Errorf("\n%s\nEvaluateSchedule(...).Due: -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.reason, got.Reason); diff != "" {
+				t.Errorf("\n%s\nEvaluateSchedule(...).Reason: -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if !got.Time.Equal(tc.want.time) {
+				t.Errorf("\n%s\nEvaluateSchedule(...).Time: want %s, got %s\n", tc.reason, tc.want.time, got.Time)
+			}
+			if diff := cmp.Diff(tc.want.missed, got.Missed); diff != "" {
+				t.Errorf("\n%s\nEvaluateSchedule(...).Missed: -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if !got.Next.Equal(tc.want.next) {
+				t.Errorf("\n%s\nEvaluateSchedule(...).Next: want %s, got %s\n", tc.reason, tc.want.next, got.Next)
+			}
+		})
+	}
+}